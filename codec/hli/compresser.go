@@ -12,38 +12,34 @@ type compresserWriter interface {
 	Flush() error
 }
 
-func newCompresserWriter(w io.Writer, h *Header) (c compresserWriter) {
-	var err error
-
+func newCompresserWriter(w io.Writer, h *Header) (compresserWriter, error) {
 	switch h.Compression {
 	case CompressionGzip:
-		c = gzip.NewWriter(w)
+		return gzip.NewWriter(w), nil
 	case CompressionZstd:
-		c, err = zstd.NewWriter(w)
-	}
-
-	if err != nil {
-		panic(err) // Should never occurred
+		return zstd.NewWriter(w)
+	default:
+		return nil, UnsupportedError("compression")
 	}
-
-	return
 }
 
-func newCompresserReader(r io.Reader, h *Header) (c io.ReadCloser) {
-	var err error
-
+func newCompresserReader(r io.Reader, h *Header) (io.ReadCloser, error) {
 	switch h.Compression {
 	case CompressionGzip:
-		c, err = gzip.NewReader(r)
+		c, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, FormatError("invalid gzip stream: " + err.Error())
+		}
+		return c, nil
 	case CompressionZstd:
-		c, err = newzstdreader(r)
-	}
-
-	if err != nil {
-		panic(err) // Should never occurred
+		c, err := newzstdreader(r)
+		if err != nil {
+			return nil, FormatError("invalid zstd stream: " + err.Error())
+		}
+		return c, nil
+	default:
+		return nil, UnsupportedError("compression")
 	}
-
-	return
 }
 
 type zstdreader struct {
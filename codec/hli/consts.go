@@ -2,6 +2,8 @@ package hli
 
 const (
 	header = "HLi.v1"
+	// maxHeaderSize is the maximum size of the CBOR header (the metadata are usually small).
+	maxHeaderSize = 16 * 1024 * 1024
 )
 
 const (
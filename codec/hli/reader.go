@@ -4,12 +4,14 @@ import (
 	"bufio"
 	"encoding/binary"
 	"image"
+	"image/color"
 	"io"
 
 	"github.com/fxamacker/cbor/v2"
 	"github.com/mdouchement/hdr"
 	"github.com/mdouchement/hdr/format"
 	"github.com/mdouchement/hdr/hdrcolor"
+	"github.com/mdouchement/hdr/parallel"
 )
 
 type decoder struct {
@@ -20,6 +22,7 @@ type decoder struct {
 	convert     func(pixel []byte) (float64, float64, float64)
 	nbOfchannel int
 	channelSize int
+	executor    *parallel.Executor
 }
 
 func newDecoder(r io.Reader) (*decoder, error) {
@@ -48,11 +51,17 @@ func (d *decoder) parseHeader() error {
 	if err != nil {
 		return err
 	}
+	if l[0] == 0 || l[0] > 4 {
+		return FormatError("invalid header-size length")
+	}
 
 	size, err := readN(d.r, int(l[0])) // variable-length header-size
 	if err != nil {
 		return err
 	}
+	if bytesToLength(size) > maxHeaderSize {
+		return FormatError("header too large")
+	}
 
 	header, err := readN(d.r, bytesToLength(size))
 	if err != nil {
@@ -60,9 +69,11 @@ func (d *decoder) parseHeader() error {
 	}
 
 	if err := cbor.Unmarshal(header, d.h); err != nil {
+		return FormatError("invalid header: " + err.Error())
+	}
+	if d.cr, err = newCompresserReader(d.r, d.h); err != nil {
 		return err
 	}
-	d.cr = newCompresserReader(d.r, d.h)
 
 	switch d.h.Format {
 	case FormatRGBE:
@@ -111,53 +122,26 @@ func (d *decoder) parseHeader() error {
 // Pixels parser                        //
 //--------------------------------------//
 
-func (d *decoder) decode(dst image.Image, y int, scanline []byte) {
+// decode converts the scanline to the row of pixels in the color space of the file.
+func (d *decoder) decode(row []float64, scanline []byte) {
 	size := d.nbOfchannel * d.channelSize
 
 	for x := 0; x < d.config.Width; x++ {
-		b0, b1, b2 := d.convert(scanline[x*size : x*size+size])
-
-		switch d.h.Format {
-		case FormatRGBE:
-			fallthrough
-		case FormatRGB:
-			img := dst.(*hdr.RGB)
-			img.SetRGB(x, y, hdrcolor.RGB{R: b0, G: b1, B: b2})
-		case FormatXYZE:
-			fallthrough
-		case FormatLogLuv:
-			fallthrough
-		case FormatXYZ:
-			img := dst.(*hdr.XYZ)
-			img.SetXYZ(x, y, hdrcolor.XYZ{X: b0, Y: b1, Z: b2})
-		}
+		row[3*x+0], row[3*x+1], row[3*x+2] = d.convert(scanline[x*size : x*size+size])
 	}
 }
 
-func (d *decoder) decodeSeparately(dst image.Image, y int, scanline []byte) {
+// decodeSeparately converts the scanline with separated channels to the row of pixels in the color space of the file.
+func (d *decoder) decodeSeparately(row []float64, scanline []byte) {
+	pixel := make([]byte, d.nbOfchannel*d.channelSize)
 	for x := 0; x < d.config.Width; x++ {
-		pixel := make([]byte, 0, d.nbOfchannel*d.channelSize)
+		pixel = pixel[:0]
 		for c := 0; c < d.nbOfchannel; c++ {
 			pos := x*d.channelSize + c*d.channelSize*d.config.Width
 			pixel = append(pixel, scanline[pos:pos+d.channelSize]...)
 		}
 
-		b0, b1, b2 := d.convert(pixel)
-
-		switch d.h.Format {
-		case FormatRGBE:
-			fallthrough
-		case FormatRGB:
-			img := dst.(*hdr.RGB)
-			img.SetRGB(x, y, hdrcolor.RGB{R: b0, G: b1, B: b2})
-		case FormatXYZE:
-			fallthrough
-		case FormatLogLuv:
-			fallthrough
-		case FormatXYZ:
-			img := dst.(*hdr.XYZ)
-			img.SetXYZ(x, y, hdrcolor.XYZ{X: b0, Y: b1, Z: b2})
-		}
+		row[3*x+0], row[3*x+1], row[3*x+2] = d.convert(pixel)
 	}
 }
 
@@ -184,33 +168,100 @@ func DecodeConfig(r io.Reader) (image.Config, error) {
 	return d.config, nil
 }
 
+// DefaultMaxPixels is the maximum number of pixels of the images decoded by Decode and DecodeWithExecutor.
+// It protects against the forged headers declaring huge images, DecodeWithOptions allows larger images.
+const DefaultMaxPixels = 1 << 28
+
+// DecodeOptions are the options used by DecodeWithOptions.
+type DecodeOptions struct {
+	// MaxPixels is the maximum number of pixels (width x height) of the decoded image, 0 means no limit.
+	MaxPixels int
+	// MaxMemory is the maximum number of bytes allocated to decode the image, 0 means no limit.
+	MaxMemory int64
+	// Executor cancels the decoding and reports its progress, one unit per scanline (optional).
+	Executor *parallel.Executor
+	// Precision is the precision of the decoded image, hdr.Float32 (*hdr.RGB or *hdr.XYZ) by default.
+	Precision hdr.Precision
+	// ColorModel is the color model of the decoded image (hdrcolor.RGBModel or hdrcolor.XYZModel),
+	// the pixels are converted during the decoding. nil keeps the color model of the file format.
+	ColorModel color.Model
+}
+
 // Decode reads a HDR image from r and returns an image.Image.
+// The image is limited to DefaultMaxPixels pixels.
 func Decode(r io.Reader) (img image.Image, err error) {
+	return DecodeWithOptions(r, nil)
+}
+
+// DecodeWithExecutor reads a HDR image from r and returns an image.Image.
+// The executor cancels the decoding and reports its progress (one unit per scanline).
+// The image is limited to DefaultMaxPixels pixels.
+func DecodeWithExecutor(executor *parallel.Executor, r io.Reader) (img image.Image, err error) {
+	return DecodeWithOptions(r, &DecodeOptions{MaxPixels: DefaultMaxPixels, Executor: executor})
+}
+
+// DecodeWithOptions reads a HDR image from r and returns an image.Image of the type chosen by the options.
+// The image size declared by the header is checked against the limits of the options before any allocation,
+// a LimitError is returned when it exceeds them. A nil o uses the default options (DefaultMaxPixels).
+func DecodeWithOptions(r io.Reader, o *DecodeOptions) (img image.Image, err error) {
+	if o == nil {
+		o = &DecodeOptions{MaxPixels: DefaultMaxPixels}
+	}
+
 	d, err := newDecoder(r)
 	if err != nil {
 		return nil, err
 	}
+	d.executor = o.Executor
+
+	if err = o.check(d.config.Width, d.config.Height, o.Precision.Size(), d.config.Width*(d.nbOfchannel*d.channelSize+3*8)); err != nil {
+		return nil, err
+	}
+	if d.h.RasterMode != RasterModeNormal && d.h.RasterMode != RasterModeSeparately {
+		return nil, UnsupportedError("raster mode")
+	}
 
-	imgRect := image.Rect(0, 0, d.config.Width, d.config.Height)
+	var space hdr.ColorSpace
 	switch d.h.Format {
 	case FormatRGBE:
 		fallthrough
 	case FormatRGB:
-		img = hdr.NewRGB(imgRect)
+		space = hdr.RGBSpace
 	case FormatXYZE:
 		fallthrough
 	case FormatLogLuv:
 		fallthrough
 	case FormatXYZ:
-		img = hdr.NewXYZ(imgRect)
+		space = hdr.XYZSpace
 	default:
 		err = UnsupportedError("image mode")
 		return
 	}
 
+	dstSpace := space
+	switch o.ColorModel {
+	case nil:
+	case hdrcolor.RGBModel:
+		dstSpace = hdr.RGBSpace
+	case hdrcolor.XYZModel:
+		dstSpace = hdr.XYZSpace
+	default:
+		err = UnsupportedError("color model")
+		return
+	}
+
+	m := hdr.NewBuffer(image.Rect(0, 0, d.config.Width, d.config.Height), dstSpace, o.Precision)
+	img = m
+
 	scanline := make([]byte, d.config.Width*d.nbOfchannel*d.channelSize)
+	row := make([]float64, 3*d.config.Width) // Decoded pixels
 
+	d.executor.Begin(d.config.Height)
 	for y := 0; y < d.config.Height; y++ {
+		if err = d.executor.Err(); err != nil {
+			return nil, err
+		}
+
 		_, err = io.ReadFull(d.cr, scanline)
 		if err != nil {
 			return
@@ -218,10 +269,12 @@ func Decode(r io.Reader) (img image.Image, err error) {
 
 		switch d.h.RasterMode {
 		case RasterModeNormal:
-			d.decode(img, y, scanline)
+			d.decode(row, scanline)
 		case RasterModeSeparately:
-			d.decodeSeparately(img, y, scanline)
+			d.decodeSeparately(row, scanline)
 		}
+		m.WriteRow(row, 0, d.config.Width, y, space)
+		d.executor.Advance(1)
 	}
 
 	return img, nil
@@ -0,0 +1,140 @@
+package hli
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"testing"
+
+	"github.com/mdouchement/hdr"
+	"github.com/mdouchement/hdr/hdrcolor"
+)
+
+// fuzzMaxPixels keeps the fuzzed images small.
+const fuzzMaxPixels = 1 << 16
+
+func FuzzDecode(f *testing.F) {
+	m := hdr.NewRGB(image.Rect(0, 0, 5, 3))
+	for y := 0; y < 3; y++ {
+		for x := 0; x < 5; x++ {
+			m.Set(x, y, hdrcolor.RGB{R: float64(x) / 4, G: float64(y) * 16, B: 0.001})
+		}
+	}
+
+	// Valid images: every mode, plus the normal raster mode
+	for _, mode := range []*Header{Mode1, Mode2, Mode3, Mode4, Mode5, Mode6} {
+		for _, raster := range []string{RasterModeSeparately, RasterModeNormal} {
+			h := *mode
+			h.RasterMode = raster
+
+			var buf bytes.Buffer
+			if err := EncodeWithOptions(&buf, m, &h); err != nil {
+				f.Fatal(err)
+			}
+			f.Add(buf.Bytes())
+		}
+	}
+
+	// Malformed headers
+	f.Add([]byte(header))
+	f.Add([]byte(header + "\x00"))
+	f.Add([]byte(header + "\x05\xff\xff\xff\xff\xff"))
+	f.Add([]byte(header + "\x01\x10\xa2\x65width\x1b\x7f\xff\xff\xff\xff\xff\xff\xff\x66height\x01"))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		if _, err := DecodeConfig(bytes.NewReader(data)); err != nil {
+			return
+		}
+
+		m, err := DecodeWithOptions(bytes.NewReader(data), &DecodeOptions{MaxPixels: fuzzMaxPixels})
+		if err != nil {
+			return
+		}
+		if d := m.Bounds(); d.Dx()*d.Dy() > fuzzMaxPixels {
+			t.Fatalf("decoded %v exceeds the limit", d)
+		}
+	})
+}
+
+// near reports whether the channels a are equal to e up to the tolerance relative to the largest channel (absolute below 1),
+// the color conversions spread the rounding errors over the channels.
+func near(a, e [3]float64, tolerance float64) bool {
+	scale := math.Max(1, math.Max(math.Abs(e[0]), math.Max(math.Abs(e[1]), math.Abs(e[2]))))
+	for c := range a {
+		if math.Abs(a[c]-e[c]) > tolerance*scale {
+			return false
+		}
+	}
+	return true
+}
+
+func TestDecodeOptions(t *testing.T) {
+	src := hdr.NewRGB(image.Rect(0, 0, 9, 4))
+	for y := 0; y < 4; y++ {
+		for x := 0; x < 9; x++ {
+			src.Set(x, y, hdrcolor.RGB{R: float64(x) / 4, G: float64(y)*16 + 0.5, B: 0.001 * float64(x+1)})
+		}
+	}
+
+	models := map[string]color.Model{"file": nil, "RGB": hdrcolor.RGBModel, "XYZ": hdrcolor.XYZModel}
+	precisions := map[string]hdr.Precision{"Float32": hdr.Float32, "Float64": hdr.Float64, "Float16": hdr.Float16}
+
+	for i, mode := range []*Header{Mode1, Mode2, Mode3, Mode4, Mode5, Mode6} {
+		h := *mode
+		var buf bytes.Buffer
+		if err := EncodeWithOptions(&buf, src, &h); err != nil {
+			t.Fatal(err)
+		}
+		data := buf.Bytes()
+
+		img, err := Decode(bytes.NewReader(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		base := img.(hdr.Image)
+
+		for mname, model := range models {
+			for pname, precision := range precisions {
+				name := fmt.Sprintf("mode %d, %s model, %s", i+1, mname, pname)
+
+				img, err := DecodeWithOptions(bytes.NewReader(data), &DecodeOptions{Precision: precision, ColorModel: model})
+				if err != nil {
+					t.Fatalf("%s: %v", name, err)
+				}
+				m := img.(hdr.Image)
+
+				space := hdr.ModelSpace(base.ColorModel())
+				if model != nil {
+					space = hdr.ModelSpace(model)
+				}
+				if expected := hdr.NewBuffer(m.Bounds(), space, precision); fmt.Sprintf("%T", m) != fmt.Sprintf("%T", expected) {
+					t.Fatalf("%s: got %T, want %T", name, m, expected)
+				}
+				if m.Bounds() != base.Bounds() {
+					t.Fatalf("%s: got bounds %v, want %v", name, m.Bounds(), base.Bounds())
+				}
+
+				tolerance := 1e-6 // float32
+				if precision == hdr.Float16 {
+					tolerance = 1e-3
+				}
+				for y := 0; y < 4; y++ {
+					for x := 0; x < 9; x++ {
+						var a, e [3]float64
+						a[0], a[1], a[2] = space.Channels(m.HDRAt(x, y))
+						e[0], e[1], e[2] = space.Channels(base.HDRAt(x, y))
+						if !near(a, e, tolerance) {
+							t.Fatalf("%s: pixel (%d, %d): got %v, want %v", name, x, y, a, e)
+						}
+					}
+				}
+			}
+		}
+
+		if _, err := DecodeWithOptions(bytes.NewReader(data), &DecodeOptions{ColorModel: hdrcolor.RAWModel}); err != UnsupportedError("color model") {
+			t.Errorf("mode %d: raw model: got error %v", i+1, err)
+		}
+	}
+}
@@ -1,7 +1,9 @@
 package hli
 
 import (
+	"fmt"
 	"io"
+	"math"
 )
 
 // func csRGBToYCoCg(r, g, b float64) (y, co, cg float64) {
@@ -43,6 +45,13 @@ func (e UnsupportedError) Error() string {
 	return "crad: unsupported feature: " + string(e)
 }
 
+// A LimitError reports that the image exceeds a limit of the DecodeOptions.
+type LimitError string
+
+func (e LimitError) Error() string {
+	return "crad: limit exceeded: " + string(e)
+}
+
 // An InternalError reports that an internal error was encountered.
 type InternalError string
 
@@ -85,9 +94,10 @@ func lengthToBytes(l int) []byte {
 }
 
 // converts the given BigEndian representation to its integer value.
+// An empty representation is a zero length.
 func bytesToLength(b []byte) int {
 	if len(b) == 0 {
-		panic("bad size")
+		return 0
 	}
 
 	if len(b) == 1 { // uint8
@@ -105,3 +115,25 @@ func bytesToLength(b []byte) int {
 	// uint32
 	return int(b[3]) | int(b[2])<<8 | int(b[1])<<16 | int(b[0])<<24
 }
+
+// check returns an error when the image size declared by the header is invalid or exceeds the limits.
+// pixelSize is the number of bytes of a decoded pixel and bufferSize the number of bytes of the decoding buffers.
+func (o *DecodeOptions) check(width, height, pixelSize, bufferSize int) error {
+	if width <= 0 || height <= 0 {
+		return FormatError("invalid image size")
+	}
+
+	pixels := float64(width) * float64(height)
+	if o.MaxPixels > 0 && (width > o.MaxPixels || height > o.MaxPixels || pixels > float64(o.MaxPixels)) {
+		return LimitError(fmt.Sprintf("%dx%d pixels", width, height))
+	}
+
+	memory := pixels*float64(pixelSize) + float64(bufferSize)
+	if o.MaxMemory > 0 && memory > float64(o.MaxMemory) {
+		return LimitError(fmt.Sprintf("%.0f bytes", memory))
+	}
+	if memory > math.MaxInt {
+		return LimitError("image too large")
+	}
+	return nil
+}
@@ -9,6 +9,7 @@ import (
 	"github.com/mdouchement/hdr"
 	"github.com/mdouchement/hdr/format"
 	"github.com/mdouchement/hdr/hdrcolor"
+	"github.com/mdouchement/hdr/parallel"
 )
 
 type encoder struct {
@@ -18,6 +19,7 @@ type encoder struct {
 	bytesAt     func(x, y int) []byte
 	nbOfchannel int
 	channelSize int
+	executor    *parallel.Executor
 }
 
 func newEncoder(w io.Writer, m hdr.Image, h *Header) *encoder {
@@ -43,6 +45,9 @@ func (e *encoder) configureHeader() error {
 	if e.h.RasterMode == "" {
 		e.h.RasterMode = RasterModeNormal
 	}
+	if e.h.Compression != CompressionGzip && e.h.Compression != CompressionZstd {
+		return UnsupportedError("compression")
+	}
 	if e.h.Format == "" {
 		switch e.m.ColorModel() {
 		case hdrcolor.RGBModel:
@@ -91,6 +96,8 @@ func (e *encoder) configureHeader() error {
 			xx, yy, zz, _ := e.m.HDRAt(x, y).HDRXYZA()
 			return format.XYZToLogLuv(xx, yy, zz)
 		}
+	default:
+		return UnsupportedError("format")
 	}
 
 	// Header - Size
@@ -102,7 +109,7 @@ func (e *encoder) configureHeader() error {
 }
 
 func (e *encoder) writeHeader() error {
-	_, err := e.w.Write(append([]byte(header))) // magic number
+	_, err := e.w.Write([]byte(header)) // magic number
 	if err != nil {
 		return err
 	}
@@ -128,7 +135,12 @@ func (e *encoder) encode(w compresserWriter) error {
 	d := e.m.Bounds().Size()
 
 	var err error
+	e.executor.Begin(d.Y)
 	for y := 0; y < d.Y; y++ {
+		if err = e.executor.Err(); err != nil {
+			return err
+		}
+
 		for x := 0; x < d.X; x++ {
 			pixel := e.bytesAt(x, y)
 			_, err = w.Write(pixel)
@@ -137,6 +149,7 @@ func (e *encoder) encode(w compresserWriter) error {
 				return err
 			}
 		}
+		e.executor.Advance(1)
 	}
 
 	return w.Flush()
@@ -147,7 +160,12 @@ func (e *encoder) encodeSeparately(w compresserWriter) error {
 	writeline := make([]byte, d.X*e.nbOfchannel*e.channelSize)
 
 	var err error
+	e.executor.Begin(d.Y)
 	for y := 0; y < d.Y; y++ {
+		if err = e.executor.Err(); err != nil {
+			return err
+		}
+
 		for x := 0; x < d.X; x++ {
 			// Separate colors
 			pixel := e.bytesAt(x, y)
@@ -164,6 +182,7 @@ func (e *encoder) encodeSeparately(w compresserWriter) error {
 		if err != nil {
 			return err
 		}
+		e.executor.Advance(1)
 	}
 
 	return w.Flush()
@@ -176,7 +195,14 @@ func Encode(w io.Writer, m hdr.Image) error {
 
 // EncodeWithOptions writes the Image m to w in CRAD format.
 func EncodeWithOptions(w io.Writer, m hdr.Image, h *Header) error {
+	return EncodeWithExecutor(nil, w, m, h)
+}
+
+// EncodeWithExecutor writes the Image m to w in CRAD format.
+// The executor cancels the encoding and reports its progress (one unit per scanline).
+func EncodeWithExecutor(executor *parallel.Executor, w io.Writer, m hdr.Image, h *Header) error {
 	e := newEncoder(w, m, h)
+	e.executor = executor
 
 	if err := e.configureHeader(); err != nil {
 		return err
@@ -187,7 +213,10 @@ func EncodeWithOptions(w io.Writer, m hdr.Image, h *Header) error {
 	}
 
 	wb := bufio.NewWriter(e.w)
-	wc := newCompresserWriter(wb, e.h)
+	wc, err := newCompresserWriter(wb, e.h)
+	if err != nil {
+		return err
+	}
 	defer wc.Close()
 
 	// Write raster
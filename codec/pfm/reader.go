@@ -9,6 +9,7 @@ import (
 	"encoding/binary"
 	"fmt"
 	"image"
+	"image/color"
 	"io"
 	"math"
 	"strconv"
@@ -17,6 +18,7 @@ import (
 	"github.com/mdouchement/hdr"
 	"github.com/mdouchement/hdr/format"
 	"github.com/mdouchement/hdr/hdrcolor"
+	"github.com/mdouchement/hdr/parallel"
 )
 
 type decoder struct {
@@ -25,6 +27,7 @@ type decoder struct {
 	scale      float64 // Scale Factor
 	mode       imageMode
 	endianness binary.ByteOrder
+	executor   *parallel.Executor
 }
 
 func newDecoder(r io.Reader) (*decoder, error) {
@@ -96,44 +99,117 @@ func DecodeConfig(r io.Reader) (image.Config, error) {
 	return d.config, nil
 }
 
+// DecodeScale returns the Scale Factor of a PFM image header without decoding the entire image.
+// The pixel values stored in the file are multiplied by this scale.
+func DecodeScale(r io.Reader) (float64, error) {
+	d, err := newDecoder(r)
+	if err != nil {
+		return 0, err
+	}
+	return d.scale, nil
+}
+
+// DefaultMaxPixels is the maximum number of pixels of the images decoded by Decode and DecodeWithExecutor.
+// It protects against the forged headers declaring huge images, DecodeWithOptions allows larger images.
+const DefaultMaxPixels = 1 << 28
+
+// DecodeOptions are the options used by DecodeWithOptions.
+type DecodeOptions struct {
+	// MaxPixels is the maximum number of pixels (width x height) of the decoded image, 0 means no limit.
+	MaxPixels int
+	// MaxMemory is the maximum number of bytes allocated to decode the image, 0 means no limit.
+	MaxMemory int64
+	// Executor cancels the decoding and reports its progress, one unit per scanline (optional).
+	Executor *parallel.Executor
+	// Precision is the precision of the decoded image, hdr.Float32 (*hdr.RGB) by default.
+	Precision hdr.Precision
+	// ColorModel is the color model of the decoded image (hdrcolor.RGBModel or hdrcolor.XYZModel),
+	// the pixels are converted during the decoding. nil keeps the color model of the file.
+	ColorModel color.Model
+	// KeepScale keeps the pixel values as stored in the file, the Scale Factor of the header is not divided out.
+	KeepScale bool
+}
+
 // Decode reads a HDR image from r and returns an image.Image.
+// The image is limited to DefaultMaxPixels pixels.
 func Decode(r io.Reader) (img image.Image, err error) {
+	return DecodeWithOptions(r, nil)
+}
+
+// DecodeWithExecutor reads a HDR image from r and returns an image.Image.
+// The executor cancels the decoding and reports its progress (one unit per scanline).
+// The image is limited to DefaultMaxPixels pixels.
+func DecodeWithExecutor(executor *parallel.Executor, r io.Reader) (img image.Image, err error) {
+	return DecodeWithOptions(r, &DecodeOptions{MaxPixels: DefaultMaxPixels, Executor: executor})
+}
+
+// DecodeWithOptions reads a HDR image from r and returns an image.Image of the type chosen by the options.
+// The image size declared by the header is checked against the limits of the options before any allocation,
+// a LimitError is returned when it exceeds them. A nil o uses the default options (DefaultMaxPixels).
+func DecodeWithOptions(r io.Reader, o *DecodeOptions) (img image.Image, err error) {
+	if o == nil {
+		o = &DecodeOptions{MaxPixels: DefaultMaxPixels}
+	}
+
 	d, err := newDecoder(r)
 	if err != nil {
 		return nil, err
 	}
+	d.executor = o.Executor
+
+	if err = o.check(d.config.Width, d.config.Height, o.Precision.Size(), (4*3+3*8)*d.config.Width); err != nil {
+		return nil, err
+	}
 
-	imgRect := image.Rect(0, 0, d.config.Width, d.config.Height)
 	switch d.mode {
 	case mColor:
-		img = hdr.NewRGB(imgRect)
 	case mGrayscale:
 		err = UnsupportedError("image mode Grayscale")
+		return
 	default:
 		err = UnsupportedError("image mode")
 		return
 	}
 
-	m := img.(*hdr.RGB) // Only RGB format is supported
+	space := hdr.RGBSpace // Only RGB format is supported
+	switch o.ColorModel {
+	case nil, hdrcolor.RGBModel:
+	case hdrcolor.XYZModel:
+		space = hdr.XYZSpace
+	default:
+		err = UnsupportedError("color model")
+		return
+	}
+
+	m := hdr.NewBuffer(image.Rect(0, 0, d.config.Width, d.config.Height), space, o.Precision)
+	img = m
 
-	pixel := make([]byte, 4*3) // RGB pixel (4 Bytes per channel)
-	var R, G, B float64
+	scanline := make([]byte, 4*3*d.config.Width) // RGB pixels (4 Bytes per channel)
+	row := make([]float64, 3*d.config.Width)     // Decoded pixels
 	invScale := 1 / d.scale
+	if o.KeepScale {
+		invScale = 1
+	}
 
 	// The pixels in each row ordered left to right and the rows ordered bottom to top
+	d.executor.Begin(d.config.Height)
 	for y := d.config.Height - 1; y >= 0; y-- {
-		for x := 0; x < d.config.Width; x++ {
-			if _, err = io.ReadFull(d.r, pixel); err != nil {
-				return
-			}
+		if err = d.executor.Err(); err != nil {
+			return nil, err
+		}
 
-			R, G, B = format.FromBytes(d.endianness, pixel)
-			m.Set(x, y, hdrcolor.RGB{
-				R: R * invScale,
-				G: G * invScale,
-				B: B * invScale,
-			})
+		if _, err = io.ReadFull(d.r, scanline); err != nil {
+			return
+		}
+
+		for x := 0; x < d.config.Width; x++ {
+			R, G, B := format.FromBytes(d.endianness, scanline[12*x:12*x+12])
+			row[3*x+0] = R * invScale
+			row[3*x+1] = G * invScale
+			row[3*x+2] = B * invScale
 		}
+		m.WriteRow(row, 0, d.config.Width, y, hdr.RGBSpace)
+		d.executor.Advance(1)
 	}
 
 	return img, nil
@@ -0,0 +1,180 @@
+package pfm
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"testing"
+
+	"github.com/mdouchement/hdr"
+	"github.com/mdouchement/hdr/filter"
+	"github.com/mdouchement/hdr/hdrcolor"
+)
+
+// fuzzMaxPixels keeps the fuzzed images small.
+const fuzzMaxPixels = 1 << 16
+
+func FuzzDecode(f *testing.F) {
+	// Valid images (little-endian)
+	for _, size := range []image.Point{{1, 1}, {4, 3}, {16, 4}} {
+		m := hdr.NewRGB(image.Rect(0, 0, size.X, size.Y))
+		for y := 0; y < size.Y; y++ {
+			for x := 0; x < size.X; x++ {
+				m.Set(x, y, hdrcolor.RGB{R: float64(x) / 4, G: float64(y) * 16, B: 0.001})
+			}
+		}
+
+		var buf bytes.Buffer
+		if err := Encode(&buf, m); err != nil {
+			f.Fatal(err)
+		}
+		f.Add(buf.Bytes())
+	}
+
+	// Big-endian image with a scale factor
+	be := []byte("PF\n2 1\n4.0\n")
+	for _, v := range []float32{1, 0.5, 0.25, 2, 4, float32(math.Inf(1))} {
+		b := make([]byte, 4)
+		binary.BigEndian.PutUint32(b, math.Float32bits(v))
+		be = append(be, b...)
+	}
+	f.Add(be)
+
+	// Malformed headers
+	f.Add([]byte("PF\n0 9000000000000000000\n-1\n"))
+	f.Add([]byte("PF\n1000000 1000000\n-1.0\n"))
+	f.Add([]byte("PF\n-1 1\n-1.0\n"))
+	f.Add([]byte("PF\n1 1\n0\n"))
+	f.Add([]byte("Pf\n1 1\n-1.0\n\x00\x00\x80\x3f"))
+	f.Add([]byte("PF\n1 1\n-1.0\n\x00\x00"))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		if _, err := DecodeConfig(bytes.NewReader(data)); err != nil {
+			return
+		}
+
+		m, err := DecodeWithOptions(bytes.NewReader(data), &DecodeOptions{MaxPixels: fuzzMaxPixels})
+		if err != nil {
+			return
+		}
+		if d := m.Bounds(); d.Dx()*d.Dy() > fuzzMaxPixels {
+			t.Fatalf("decoded %v exceeds the limit", d)
+		}
+	})
+}
+
+// encoded returns a PFM file of a small image.
+func encoded(t *testing.T) []byte {
+	m := hdr.NewRGB(image.Rect(0, 0, 9, 4))
+	for y := 0; y < 4; y++ {
+		for x := 0; x < 9; x++ {
+			m.Set(x, y, hdrcolor.RGB{R: float64(x) / 4, G: float64(y)*16 + 0.5, B: 0.001 * float64(x+1)})
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := Encode(&buf, m); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// near reports whether a is equal to e up to the relative tolerance (absolute below 1).
+func near(a, e, tolerance float64) bool {
+	return math.Abs(a-e) <= tolerance*math.Max(1, math.Abs(e))
+}
+
+// checkPixels checks the channels of actual against the ones of expected in the given color space.
+func checkPixels(t *testing.T, name string, actual, expected hdr.Image, space hdr.ColorSpace, tolerance float64) {
+	t.Helper()
+
+	if actual.Bounds() != expected.Bounds() {
+		t.Fatalf("%s: got bounds %v, want %v", name, actual.Bounds(), expected.Bounds())
+	}
+
+	d := expected.Bounds()
+	for y := d.Min.Y; y < d.Max.Y; y++ {
+		for x := d.Min.X; x < d.Max.X; x++ {
+			a1, a2, a3 := space.Channels(actual.HDRAt(x, y))
+			e1, e2, e3 := space.Channels(expected.HDRAt(x, y))
+			if !near(a1, e1, tolerance) || !near(a2, e2, tolerance) || !near(a3, e3, tolerance) {
+				t.Fatalf("%s: pixel (%d, %d): got %v, want %v", name, x, y, []float64{a1, a2, a3}, []float64{e1, e2, e3})
+			}
+		}
+	}
+}
+
+func TestDecodeOptions(t *testing.T) {
+	models := map[string]color.Model{"file": nil, "RGB": hdrcolor.RGBModel, "XYZ": hdrcolor.XYZModel}
+	precisions := map[string]hdr.Precision{"Float32": hdr.Float32, "Float64": hdr.Float64, "Float16": hdr.Float16}
+
+	data := encoded(t)
+	img, err := Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	base := img.(hdr.Image)
+
+	for mname, model := range models {
+		for pname, precision := range precisions {
+			name := mname + " model, " + pname
+
+			img, err := DecodeWithOptions(bytes.NewReader(data), &DecodeOptions{Precision: precision, ColorModel: model})
+			if err != nil {
+				t.Fatalf("%s: %v", name, err)
+			}
+			m := img.(hdr.Image)
+
+			space := hdr.RGBSpace
+			if model != nil {
+				space = hdr.ModelSpace(model)
+			}
+			if expected := hdr.NewBuffer(m.Bounds(), space, precision); fmt.Sprintf("%T", m) != fmt.Sprintf("%T", expected) {
+				t.Fatalf("%s: got %T, want %T", name, m, expected)
+			}
+
+			tolerance := 1e-6 // float32
+			if precision == hdr.Float16 {
+				tolerance = 1e-3
+			}
+			checkPixels(t, name, m, base, space, tolerance)
+		}
+	}
+
+	if _, err := DecodeWithOptions(bytes.NewReader(data), &DecodeOptions{ColorModel: hdrcolor.RAWModel}); err != UnsupportedError("color model") {
+		t.Errorf("raw model: got error %v", err)
+	}
+}
+
+func TestDecodeScale(t *testing.T) {
+	data := encoded(t)
+	base, err := Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The stored values are multiplied by the scale, its sign is the endianness
+	scaled := bytes.Replace(data, []byte("\n-1.0\n"), []byte("\n-4.0\n"), 1)
+	if scale, err := DecodeScale(bytes.NewReader(scaled)); err != nil || scale != 4 {
+		t.Fatalf("got scale %v (%v), want 4", scale, err)
+	}
+
+	m, err := Decode(bytes.NewReader(scaled))
+	if err != nil {
+		t.Fatal(err)
+	}
+	quarter := filter.NewApply1(base.(hdr.Image), func(c, _ hdrcolor.Color) hdrcolor.Color {
+		r, g, b, _ := c.HDRRGBA()
+		return hdrcolor.RGB{R: r / 4, G: g / 4, B: b / 4}
+	})
+	checkPixels(t, "scale", m.(hdr.Image), quarter, hdr.RGBSpace, 0)
+
+	kept, err := DecodeWithOptions(bytes.NewReader(scaled), &DecodeOptions{KeepScale: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkPixels(t, "kept scale", kept.(hdr.Image), base.(hdr.Image), hdr.RGBSpace, 0)
+}
@@ -9,12 +9,14 @@ import (
 	"github.com/mdouchement/hdr"
 	"github.com/mdouchement/hdr/format"
 	"github.com/mdouchement/hdr/hdrcolor"
+	"github.com/mdouchement/hdr/parallel"
 )
 
 type encoder struct {
-	w    io.Writer
-	m    hdr.Image
-	mode imageMode
+	w        io.Writer
+	m        hdr.Image
+	mode     imageMode
+	executor *parallel.Executor
 }
 
 func newEncoder(w io.Writer, m hdr.Image) *encoder {
@@ -47,7 +49,14 @@ func (e *encoder) writeHeader() error {
 
 // Encode writes the Image m to w in PFM format.
 func Encode(w io.Writer, m hdr.Image) error {
+	return EncodeWithExecutor(nil, w, m)
+}
+
+// EncodeWithExecutor writes the Image m to w in PFM format.
+// The executor cancels the encoding and reports its progress (one unit per scanline).
+func EncodeWithExecutor(executor *parallel.Executor, w io.Writer, m hdr.Image) error {
 	e := newEncoder(w, m)
+	e.executor = executor
 
 	switch m.ColorModel() {
 	case hdrcolor.RGBModel:
@@ -63,7 +72,12 @@ func Encode(w io.Writer, m hdr.Image) error {
 	buff := bufio.NewWriter(w)
 
 	// The pixels in each row ordered left to right and the rows ordered bottom to top
+	e.executor.Begin(m.Bounds().Dy())
 	for y := m.Bounds().Dy() - 1; y >= 0; y-- {
+		if err := e.executor.Err(); err != nil {
+			return err
+		}
+
 		for x := 0; x < m.Bounds().Dx(); x++ {
 			r, g, b, _ := m.HDRAt(x, y).HDRRGBA()
 
@@ -71,6 +85,7 @@ func Encode(w io.Writer, m hdr.Image) error {
 				return err
 			}
 		}
+		e.executor.Advance(1)
 	}
 
 	return buff.Flush()
@@ -9,12 +9,14 @@ import (
 	"bufio"
 	"fmt"
 	"image"
+	"image/color"
 	"io"
 	"strings"
 
 	"github.com/mdouchement/hdr"
 	"github.com/mdouchement/hdr/format"
 	"github.com/mdouchement/hdr/hdrcolor"
+	"github.com/mdouchement/hdr/parallel"
 )
 
 type decoder struct {
@@ -22,6 +24,7 @@ type decoder struct {
 	config   image.Config
 	exposure float64
 	mode     imageMode
+	executor *parallel.Executor
 }
 
 func newDecoder(r io.Reader) (*decoder, error) {
@@ -117,43 +120,27 @@ func (d *decoder) appendHeaderAttributes(token string) error {
 // Pixels parser                        //
 //--------------------------------------//
 
-func (d *decoder) decode(dst image.Image, y int, scanline []byte) {
+// decode converts the scanline to the row of pixels in the color space of the file.
+func (d *decoder) decode(row []float64, scanline []byte) {
 	for x := 0; x < d.config.Width; x++ {
-		b0, b1, b2 := format.FromRadianceBytes(
+		row[3*x+0], row[3*x+1], row[3*x+2] = format.FromRadianceBytes(
 			scanline[4*x],
 			scanline[4*x+1],
 			scanline[4*x+2],
 			scanline[4*x+3],
 			d.exposure)
-
-		switch d.mode {
-		case mRGBE:
-			img := dst.(*hdr.RGB)
-			img.SetRGB(x, y, hdrcolor.RGB{R: b0, G: b1, B: b2})
-		case mXYZE:
-			img := dst.(*hdr.XYZ)
-			img.SetXYZ(x, y, hdrcolor.XYZ{X: b0, Y: b1, Z: b2})
-		}
 	}
 }
 
-func (d *decoder) decodeRLE(dst image.Image, y int, scanline []byte) {
+// decodeRLE converts the RLE scanline to the row of pixels in the color space of the file.
+func (d *decoder) decodeRLE(row []float64, scanline []byte) {
 	for x := 0; x < d.config.Width; x++ {
-		b0, b1, b2 := format.FromRadianceBytes(
+		row[3*x+0], row[3*x+1], row[3*x+2] = format.FromRadianceBytes(
 			scanline[x],
 			scanline[x+d.config.Width],
 			scanline[x+d.config.Width*2],
 			scanline[x+d.config.Width*3],
 			d.exposure)
-
-		switch d.mode {
-		case mRGBE:
-			img := dst.(*hdr.RGB)
-			img.SetRGB(x, y, hdrcolor.RGB{R: b0, G: b1, B: b2})
-		case mXYZE:
-			img := dst.(*hdr.XYZ)
-			img.SetXYZ(x, y, hdrcolor.XYZ{X: b0, Y: b1, Z: b2})
-		}
 	}
 }
 
@@ -177,6 +164,10 @@ func (d *decoder) readRLE(scanline []byte) (err error) {
 			if buf[0] > 128 {
 				// a run of the same value
 				runLength := int(buf[0]) - 128
+				if peek+runLength > d.config.Width {
+					err = FormatError("RLE run overflows the scanline")
+					return
+				}
 				for ; runLength > 0; runLength-- {
 					scanline[index+peek] = buf[1]
 					peek++
@@ -187,6 +178,10 @@ func (d *decoder) readRLE(scanline []byte) (err error) {
 				peek++
 
 				nonrunLength := int(buf[0]) - 1
+				if peek+nonrunLength > d.config.Width {
+					err = FormatError("RLE run overflows the scanline")
+					return
+				}
 				if nonrunLength > 0 {
 					if _, err = io.ReadFull(d.r, scanline[index+peek:index+peek+nonrunLength]); err != nil {
 						if err == io.EOF {
@@ -223,28 +218,106 @@ func DecodeConfig(r io.Reader) (image.Config, error) {
 	return d.config, nil
 }
 
+// DecodeExposure returns the EXPOSURE of a RGBE image header (1 when it is missing) without
+// decoding the entire image. The pixel values stored in the file are multiplied by this exposure.
+func DecodeExposure(r io.Reader) (float64, error) {
+	d, err := newDecoder(r)
+	if err != nil {
+		return 0, err
+	}
+	return d.exposure, nil
+}
+
+// DefaultMaxPixels is the maximum number of pixels of the images decoded by Decode and DecodeWithExecutor.
+// It protects against the forged headers declaring huge images, DecodeWithOptions allows larger images.
+const DefaultMaxPixels = 1 << 28
+
+// DecodeOptions are the options used by DecodeWithOptions.
+type DecodeOptions struct {
+	// MaxPixels is the maximum number of pixels (width x height) of the decoded image, 0 means no limit.
+	MaxPixels int
+	// MaxMemory is the maximum number of bytes allocated to decode the image, 0 means no limit.
+	MaxMemory int64
+	// Executor cancels the decoding and reports its progress, one unit per scanline (optional).
+	Executor *parallel.Executor
+	// Precision is the precision of the decoded image, hdr.Float32 (*hdr.RGB or *hdr.XYZ) by default.
+	Precision hdr.Precision
+	// ColorModel is the color model of the decoded image (hdrcolor.RGBModel or hdrcolor.XYZModel),
+	// the pixels are converted during the decoding. nil keeps the color model of the file.
+	ColorModel color.Model
+	// KeepExposure keeps the pixel values as stored in the file, the EXPOSURE of the header is not divided out.
+	KeepExposure bool
+}
+
 // Decode reads a HDR image from r and returns an image.Image.
+// The image is limited to DefaultMaxPixels pixels.
 func Decode(r io.Reader) (img image.Image, err error) {
+	return DecodeWithOptions(r, nil)
+}
+
+// DecodeWithExecutor reads a HDR image from r and returns an image.Image.
+// The executor cancels the decoding and reports its progress (one unit per scanline).
+// The image is limited to DefaultMaxPixels pixels.
+func DecodeWithExecutor(executor *parallel.Executor, r io.Reader) (img image.Image, err error) {
+	return DecodeWithOptions(r, &DecodeOptions{MaxPixels: DefaultMaxPixels, Executor: executor})
+}
+
+// DecodeWithOptions reads a HDR image from r and returns an image.Image of the type chosen by the options.
+// The image size declared by the header is checked against the limits of the options before any allocation,
+// a LimitError is returned when it exceeds them. A nil o uses the default options (DefaultMaxPixels).
+func DecodeWithOptions(r io.Reader, o *DecodeOptions) (img image.Image, err error) {
+	if o == nil {
+		o = &DecodeOptions{MaxPixels: DefaultMaxPixels}
+	}
+
 	d, err := newDecoder(r)
 	if err != nil {
 		return nil, err
 	}
+	d.executor = o.Executor
+	if o.KeepExposure {
+		d.exposure = 1
+	}
+
+	if err = o.check(d.config.Width, d.config.Height, o.Precision.Size(), (4+3*8)*d.config.Width); err != nil {
+		return nil, err
+	}
 
-	imgRect := image.Rect(0, 0, d.config.Width, d.config.Height)
+	var space hdr.ColorSpace
 	switch d.mode {
 	case mRGBE:
-		img = hdr.NewRGB(imgRect)
+		space = hdr.RGBSpace
 	case mXYZE:
-		img = hdr.NewXYZ(imgRect)
+		space = hdr.XYZSpace
 	default:
 		err = UnsupportedError("image mode")
 		return
 	}
 
+	dstSpace := space
+	switch o.ColorModel {
+	case nil:
+	case hdrcolor.RGBModel:
+		dstSpace = hdr.RGBSpace
+	case hdrcolor.XYZModel:
+		dstSpace = hdr.XYZSpace
+	default:
+		err = UnsupportedError("color model")
+		return
+	}
+
+	m := hdr.NewBuffer(image.Rect(0, 0, d.config.Width, d.config.Height), dstSpace, o.Precision)
+	img = m
+
 	scanline := make([]byte, d.config.Width*4) // 4 bytes for one pixel
 	pixel := make([]byte, 4)                   // RGBE pixel
+	row := make([]float64, d.config.Width*3)   // Decoded pixels
 
+	d.executor.Begin(d.config.Height)
 	for y := 0; y < d.config.Height; y++ {
+		if err = d.executor.Err(); err != nil {
+			return nil, err
+		}
 
 		// Read rle header
 		if _, err = io.ReadFull(d.r, pixel); err != nil {
@@ -268,7 +341,7 @@ func Decode(r io.Reader) (img image.Image, err error) {
 			// Restore first read pixel
 			scanline[0], scanline[1], scanline[2], scanline[3] = pixel[0], pixel[1], pixel[2], pixel[3]
 
-			d.decode(img, y, scanline)
+			d.decode(row, scanline)
 		} else {
 			// --- rle scanline
 
@@ -276,8 +349,10 @@ func Decode(r io.Reader) (img image.Image, err error) {
 				return
 			}
 
-			d.decodeRLE(img, y, scanline)
+			d.decodeRLE(row, scanline)
 		}
+		m.WriteRow(row, 0, d.config.Width, y, space)
+		d.executor.Advance(1)
 	}
 
 	return img, nil
@@ -0,0 +1,182 @@
+package rgbe
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/mdouchement/hdr"
+	"github.com/mdouchement/hdr/filter"
+	"github.com/mdouchement/hdr/hdrcolor"
+)
+
+// fuzzMaxPixels keeps the fuzzed images small.
+const fuzzMaxPixels = 1 << 16
+
+func FuzzDecode(f *testing.F) {
+	// Valid images: flat and RLE scanlines (RLE is used for widths in [8, 32767]), RGBE and XYZE
+	for _, size := range []image.Point{{1, 1}, {4, 3}, {16, 4}, {40, 2}} {
+		for _, m := range []hdr.ImageSet{hdr.NewRGB(image.Rect(0, 0, size.X, size.Y)), hdr.NewXYZ(image.Rect(0, 0, size.X, size.Y))} {
+			for y := 0; y < size.Y; y++ {
+				for x := 0; x < size.X; x++ {
+					m.Set(x, y, hdrcolor.RGB{R: float64(x) / 4, G: float64(y) * 16, B: 0.001})
+				}
+			}
+
+			for _, rle := range []bool{true, false} {
+				RLEWrites = rle
+
+				var buf bytes.Buffer
+				if err := Encode(&buf, m.(hdr.Image)); err != nil {
+					f.Fatal(err)
+				}
+				f.Add(buf.Bytes())
+			}
+		}
+	}
+	RLEWrites = true
+
+	// Malformed headers
+	f.Add([]byte("#?RADIANCE\nFORMAT=32-bit_rle_rgbe\n\n-Y 1000000 +X 1000000\n"))
+	f.Add([]byte("#?RADIANCE\nFORMAT=32-bit_rle_rgbe\n\n-Y 9000000000000000000 +X 0\n"))
+	f.Add([]byte("#?RADIANCE\nFORMAT=32-bit_rle_rgbe\nEXPOSURE=0\n\n-Y 1 +X 1\n\x80\x80\x80\x80"))
+	f.Add([]byte("#?RADIANCE\nFORMAT=32-bit_rle_xyze\n\n+X 8 -Y 1\n\x02\x02\x00\x08\xff"))
+	f.Add([]byte("#?RGBE\n"))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		if _, err := DecodeConfig(bytes.NewReader(data)); err != nil {
+			return
+		}
+
+		m, err := DecodeWithOptions(bytes.NewReader(data), &DecodeOptions{MaxPixels: fuzzMaxPixels})
+		if err != nil {
+			return
+		}
+		if d := m.Bounds(); d.Dx()*d.Dy() > fuzzMaxPixels {
+			t.Fatalf("decoded %v exceeds the limit", d)
+		}
+	})
+}
+
+// encoded returns a RGBE and a XYZE file of the same small image.
+func encoded(t *testing.T) map[string][]byte {
+	files := map[string][]byte{}
+	for name, m := range map[string]hdr.ImageSet{"RGBE": hdr.NewRGB(image.Rect(0, 0, 9, 4)), "XYZE": hdr.NewXYZ(image.Rect(0, 0, 9, 4))} {
+		for y := 0; y < 4; y++ {
+			for x := 0; x < 9; x++ {
+				m.Set(x, y, hdrcolor.RGB{R: float64(x) / 4, G: float64(y)*16 + 0.5, B: 0.001 * float64(x+1)})
+			}
+		}
+
+		var buf bytes.Buffer
+		if err := Encode(&buf, m.(hdr.Image)); err != nil {
+			t.Fatal(err)
+		}
+		files[name] = buf.Bytes()
+	}
+	return files
+}
+
+// checkPixels checks the channels of actual against the ones of expected in the given color space.
+func checkPixels(t *testing.T, name string, actual, expected hdr.Image, space hdr.ColorSpace, tolerance float64) {
+	t.Helper()
+
+	if actual.Bounds() != expected.Bounds() {
+		t.Fatalf("%s: got bounds %v, want %v", name, actual.Bounds(), expected.Bounds())
+	}
+
+	d := expected.Bounds()
+	for y := d.Min.Y; y < d.Max.Y; y++ {
+		for x := d.Min.X; x < d.Max.X; x++ {
+			a1, a2, a3 := space.Channels(actual.HDRAt(x, y))
+			e1, e2, e3 := space.Channels(expected.HDRAt(x, y))
+			if !near(a1, e1, tolerance) || !near(a2, e2, tolerance) || !near(a3, e3, tolerance) {
+				t.Fatalf("%s: pixel (%d, %d): got %v, want %v", name, x, y, []float64{a1, a2, a3}, []float64{e1, e2, e3})
+			}
+		}
+	}
+}
+
+// near reports whether a is equal to e up to the relative tolerance (absolute below 1).
+func near(a, e, tolerance float64) bool {
+	return math.Abs(a-e) <= tolerance*math.Max(1, math.Abs(e))
+}
+
+func TestDecodeOptions(t *testing.T) {
+	models := map[string]color.Model{"file": nil, "RGB": hdrcolor.RGBModel, "XYZ": hdrcolor.XYZModel}
+	precisions := map[string]hdr.Precision{"Float32": hdr.Float32, "Float64": hdr.Float64, "Float16": hdr.Float16}
+
+	for format, data := range encoded(t) {
+		img, err := Decode(bytes.NewReader(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		base := img.(hdr.Image)
+
+		for mname, model := range models {
+			for pname, precision := range precisions {
+				name := format + ", " + mname + " model, " + pname
+
+				img, err := DecodeWithOptions(bytes.NewReader(data), &DecodeOptions{Precision: precision, ColorModel: model})
+				if err != nil {
+					t.Fatalf("%s: %v", name, err)
+				}
+				m := img.(hdr.Image)
+
+				space := hdr.ModelSpace(base.ColorModel())
+				if model != nil {
+					space = hdr.ModelSpace(model)
+				}
+				if expected := hdr.NewBuffer(m.Bounds(), space, precision); fmt.Sprintf("%T", m) != fmt.Sprintf("%T", expected) {
+					t.Fatalf("%s: got %T, want %T", name, m, expected)
+				}
+
+				tolerance := 1e-6 // float32
+				if precision == hdr.Float16 {
+					tolerance = 1e-3
+				}
+				checkPixels(t, name, m, base, space, tolerance)
+			}
+		}
+
+		if _, err := DecodeWithOptions(bytes.NewReader(data), &DecodeOptions{ColorModel: hdrcolor.RAWModel}); err != UnsupportedError("color model") {
+			t.Errorf("%s: raw model: got error %v", format, err)
+		}
+	}
+}
+
+func TestDecodeExposure(t *testing.T) {
+	for format, data := range encoded(t) {
+		base, err := Decode(bytes.NewReader(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// The stored values are multiplied by the exposure
+		format := strings.ToLower(format)
+		exposed := bytes.Replace(data, []byte("_"+format+"\n"), []byte("_"+format+"\nEXPOSURE=4\n"), 1)
+		if exposure, err := DecodeExposure(bytes.NewReader(exposed)); err != nil || exposure != 4 {
+			t.Fatalf("%s: got exposure %v (%v), want 4", format, exposure, err)
+		}
+
+		m, err := Decode(bytes.NewReader(exposed))
+		if err != nil {
+			t.Fatal(err)
+		}
+		quarter := filter.NewApply1(base.(hdr.Image), func(c, _ hdrcolor.Color) hdrcolor.Color {
+			r, g, b, _ := c.HDRRGBA()
+			return hdrcolor.RGB{R: r / 4, G: g / 4, B: b / 4}
+		})
+		checkPixels(t, format+" exposure", m.(hdr.Image), quarter, hdr.RGBSpace, 1e-6)
+
+		kept, err := DecodeWithOptions(bytes.NewReader(exposed), &DecodeOptions{KeepExposure: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkPixels(t, format+" kept exposure", kept.(hdr.Image), base.(hdr.Image), hdr.RGBSpace, 0)
+	}
+}
@@ -2,7 +2,9 @@ package rgbe
 
 import (
 	"bytes"
+	"fmt"
 	"io"
+	"math"
 
 	"github.com/mdouchement/hdr"
 	"github.com/mdouchement/hdr/hdrcolor"
@@ -56,6 +58,9 @@ const (
 // 	return pixel
 // }
 
+// maxLineSize is the maximum length of a header line.
+const maxLineSize = 64 * 1024
+
 func readUntil(r io.Reader, delimiter byte) (string, error) {
 	buf := &bytes.Buffer{}
 	p := make([]byte, 1)
@@ -64,6 +69,9 @@ func readUntil(r io.Reader, delimiter byte) (string, error) {
 		if _, err := r.Read(p); err != nil {
 			return "", err
 		}
+		if buf.Len() >= maxLineSize {
+			return "", FormatError("header line too long")
+		}
 
 		if p[0] != delimiter {
 			buf.Write(p)
@@ -111,9 +119,38 @@ func (e UnsupportedError) Error() string {
 	return "rgbe: unsupported feature: " + string(e)
 }
 
+// A LimitError reports that the image exceeds a limit of the DecodeOptions.
+type LimitError string
+
+func (e LimitError) Error() string {
+	return "rgbe: limit exceeded: " + string(e)
+}
+
 // An InternalError reports that an internal error was encountered.
 type InternalError string
 
 func (e InternalError) Error() string {
 	return "rgbe: internal error: " + string(e)
 }
+
+// check returns an error when the image size declared by the header is invalid or exceeds the limits.
+// pixelSize is the number of bytes of a decoded pixel and bufferSize the number of bytes of the decoding buffers.
+func (o *DecodeOptions) check(width, height, pixelSize, bufferSize int) error {
+	if width <= 0 || height <= 0 {
+		return FormatError("invalid image size")
+	}
+
+	pixels := float64(width) * float64(height)
+	if o.MaxPixels > 0 && (width > o.MaxPixels || height > o.MaxPixels || pixels > float64(o.MaxPixels)) {
+		return LimitError(fmt.Sprintf("%dx%d pixels", width, height))
+	}
+
+	memory := pixels*float64(pixelSize) + float64(bufferSize)
+	if o.MaxMemory > 0 && memory > float64(o.MaxMemory) {
+		return LimitError(fmt.Sprintf("%.0f bytes", memory))
+	}
+	if memory > math.MaxInt {
+		return LimitError("image too large")
+	}
+	return nil
+}
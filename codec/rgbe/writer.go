@@ -8,15 +8,17 @@ import (
 	"github.com/mdouchement/hdr"
 	"github.com/mdouchement/hdr/format"
 	"github.com/mdouchement/hdr/hdrcolor"
+	"github.com/mdouchement/hdr/parallel"
 )
 
 // RLEWrites allows to write image file with run-length encoding.
 var RLEWrites = true
 
 type encoder struct {
-	w    io.Writer
-	m    hdr.Image
-	mode imageMode
+	w        io.Writer
+	m        hdr.Image
+	mode     imageMode
+	executor *parallel.Executor
 }
 
 func newEncoder(w io.Writer, m hdr.Image) *encoder {
@@ -64,7 +66,12 @@ func (e *encoder) encode() error {
 	d := e.m.Bounds().Size()
 
 	var err error
+	e.executor.Begin(d.Y)
 	for y := 0; y < d.Y; y++ {
+		if err = e.executor.Err(); err != nil {
+			return err
+		}
+
 		for x := 0; x < d.X; x++ {
 			_, err = w.Write(format.ToRadianceBytes(ar.at(x, y)))
 
@@ -72,6 +79,7 @@ func (e *encoder) encode() error {
 				return err
 			}
 		}
+		e.executor.Advance(1)
 	}
 
 	return w.Flush()
@@ -92,7 +100,12 @@ func (e *encoder) encodeRLE() error {
 	scanline := make([]byte, d.X*4)
 
 	var err error
+	e.executor.Begin(d.Y)
 	for y := 0; y < d.Y; y++ {
+		if err = e.executor.Err(); err != nil {
+			return err
+		}
+
 		// Prepare RLE treatment for each channel.
 		for x := 0; x < d.X; x++ {
 			pixel := format.ToRadianceBytes(ar.at(x, y))
@@ -116,6 +129,7 @@ func (e *encoder) encodeRLE() error {
 				return err
 			}
 		}
+		e.executor.Advance(1)
 	}
 
 	return w.Flush()
@@ -194,7 +208,14 @@ func (e *encoder) writeNonRun(w *bufio.Writer, scanline []byte) error {
 
 // Encode writes the Image m to w in RGBE format.
 func Encode(w io.Writer, m hdr.Image) error {
+	return EncodeWithExecutor(nil, w, m)
+}
+
+// EncodeWithExecutor writes the Image m to w in RGBE format.
+// The executor cancels the encoding and reports its progress (one unit per scanline).
+func EncodeWithExecutor(executor *parallel.Executor, w io.Writer, m hdr.Image) error {
 	e := newEncoder(w, m)
+	e.executor = executor
 
 	switch m.ColorModel() {
 	case hdrcolor.RGBModel:
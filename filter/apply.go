@@ -10,67 +10,67 @@ import (
 )
 
 // An Apply filter let's you apply any function on two colors.
+// The apply function receives the colors of the images and its result is converted to the model of the working color space.
 type Apply struct {
 	HDRImage1 hdr.Image
 	HDRImage2 hdr.Image
-	hdrat     func(x, y int) hdrcolor.Color
+	space     hdr.ColorSpace
 	apply     func(c1, c2 hdrcolor.Color) hdrcolor.Color
 }
 
-// NewApply1 instanciates a new Apply filter.
+// NewApply1 instanciates a new Apply filter working in the color space of m1
+// (hdr.RAWSpace for the color models other than RGB and XYZ).
+// It panics if m1 is nil, NewApply1WithSpace returns an error instead.
 func NewApply1(m1 hdr.Image, apply func(c1, c2 hdrcolor.Color) hdrcolor.Color) *Apply {
-	f := &Apply{
+	return &Apply{
 		HDRImage1: m1,
+		space:     hdr.ModelSpace(m1.ColorModel()),
 		apply:     apply,
 	}
+}
 
-	switch m1.ColorModel() {
-	case hdrcolor.XYZModel:
-		f.hdrat = func(x, y int) hdrcolor.Color {
-			c := f.apply(f.HDRImage1.HDRAt(x, y), nil)
-			return hdrcolor.XYZModel.Convert(c.(color.Color)).(hdrcolor.Color)
-		}
-	case hdrcolor.RGBModel:
-		f.hdrat = func(x, y int) hdrcolor.Color {
-			c := f.apply(f.HDRImage1.HDRAt(x, y), nil)
-			return hdrcolor.RGBModel.Convert(c.(color.Color)).(hdrcolor.Color)
-		}
-	default:
-		panic("Color Model not supported")
+// NewApply1WithSpace instanciates a new Apply filter working in the given color space.
+func NewApply1WithSpace(m1 hdr.Image, space hdr.ColorSpace, apply func(c1, c2 hdrcolor.Color) hdrcolor.Color) (*Apply, error) {
+	if err := checkApply(space, apply, m1); err != nil {
+		return nil, err
 	}
 
-	return f
+	return &Apply{
+		HDRImage1: m1,
+		space:     space,
+		apply:     apply,
+	}, nil
 }
 
-// NewApply2 instanciates a new Apply filter.
+// NewApply2 instanciates a new Apply filter working in the color space of m1
+// (hdr.RAWSpace for the color models other than RGB and XYZ).
+// It panics if m1 is nil, NewApply2WithSpace returns an error instead.
 func NewApply2(m1, m2 hdr.Image, apply func(c1, c2 hdrcolor.Color) hdrcolor.Color) *Apply {
-	f := &Apply{
+	return &Apply{
 		HDRImage1: m1,
 		HDRImage2: m2,
+		space:     hdr.ModelSpace(m1.ColorModel()),
 		apply:     apply,
 	}
+}
 
-	switch m1.ColorModel() {
-	case hdrcolor.XYZModel:
-		f.hdrat = func(x, y int) hdrcolor.Color {
-			c := f.apply(f.HDRImage1.HDRAt(x, y), f.HDRImage2.HDRAt(x, y))
-			return hdrcolor.XYZModel.Convert(c.(color.Color)).(hdrcolor.Color)
-		}
-	case hdrcolor.RGBModel:
-		f.hdrat = func(x, y int) hdrcolor.Color {
-			c := f.apply(f.HDRImage1.HDRAt(x, y), f.HDRImage2.HDRAt(x, y))
-			return hdrcolor.RGBModel.Convert(c.(color.Color)).(hdrcolor.Color)
-		}
-	default:
-		panic("Color Model not supported")
+// NewApply2WithSpace instanciates a new Apply filter working in the given color space.
+func NewApply2WithSpace(m1, m2 hdr.Image, space hdr.ColorSpace, apply func(c1, c2 hdrcolor.Color) hdrcolor.Color) (*Apply, error) {
+	if err := checkApply(space, apply, m1, m2); err != nil {
+		return nil, err
 	}
 
-	return f
+	return &Apply{
+		HDRImage1: m1,
+		HDRImage2: m2,
+		space:     space,
+		apply:     apply,
+	}, nil
 }
 
-// ColorModel returns the Image's color model.
+// ColorModel returns the color model of the working color space.
 func (f *Apply) ColorModel() color.Model {
-	return f.HDRImage1.ColorModel()
+	return f.space.Model()
 }
 
 // Bounds implements image.Image interface.
@@ -85,7 +85,21 @@ func (f *Apply) Size() int {
 
 // HDRAt computes the log10(x) and returns the filtered color at the given coordinates.
 func (f *Apply) HDRAt(x, y int) hdrcolor.Color {
-	return f.hdrat(x, y)
+	var c2 hdrcolor.Color
+	if f.HDRImage2 != nil {
+		c2 = f.HDRImage2.HDRAt(x, y)
+	}
+
+	c := f.apply(f.HDRImage1.HDRAt(x, y), c2)
+	return f.space.Model().Convert(c.(color.Color)).(hdrcolor.Color)
+}
+
+// ReadRow implements hdr.RowReader.
+// The apply function works on colors so the pixels are still computed one by one.
+func (f *Apply) ReadRow(dst []float64, x1, x2, y int, space hdr.ColorSpace) {
+	for x, i := x1, 0; x < x2; x, i = x+1, i+3 {
+		dst[i+0], dst[i+1], dst[i+2] = space.Channels(f.HDRAt(x, y))
+	}
 }
 
 // At computes the log10(x) and returns the filtered color at the given coordinates.
@@ -98,3 +112,11 @@ func (f *Apply) At(x, y int) color.Color {
 		A: 255,
 	}
 }
+
+// checkApply returns an error when the Apply filter arguments are invalid.
+func checkApply(space hdr.ColorSpace, apply func(c1, c2 hdrcolor.Color) hdrcolor.Color, images ...hdr.Image) error {
+	if apply == nil {
+		return ArgumentError("nil apply function")
+	}
+	return checkSpace(space, images...)
+}
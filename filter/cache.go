@@ -0,0 +1,164 @@
+package filter
+
+import (
+	"container/list"
+	"image"
+	"image/color"
+	"sync"
+
+	"github.com/mdouchement/hdr"
+	"github.com/mdouchement/hdr/hdrcolor"
+	"github.com/mdouchement/hdr/xmath"
+)
+
+// A Cache wraps a (lazy) filter chain and keeps its most recently used tiles in memory.
+// It is an alternative to Materialize when the image is sparsely or repeatedly accessed
+// (e.g. previews, regions, random sampling) and the whole image does not need to be computed.
+//
+// A Cache is safe for concurrent use.
+type Cache struct {
+	HDRImage hdr.Image
+	TileSize int
+	Capacity int
+	space    hdr.ColorSpace
+	mu       sync.Mutex
+	tiles    map[image.Point]*list.Element
+	lru      *list.List // Front is the most recently used tile
+}
+
+type cacheTile struct {
+	key  image.Point
+	rect image.Rectangle
+	pix  []float64
+}
+
+// NewCache instanciates a new Cache of the given image
+// keeping at most capacity tiles of tileSize x tileSize pixels.
+func NewCache(m hdr.Image, tileSize, capacity int) *Cache {
+	if tileSize < 1 {
+		tileSize = 64
+	}
+	if capacity < 1 {
+		capacity = 1
+	}
+
+	return &Cache{
+		HDRImage: m,
+		TileSize: tileSize,
+		Capacity: capacity,
+		space:    colorSpace(m),
+		tiles:    map[image.Point]*list.Element{},
+		lru:      list.New(),
+	}
+}
+
+// NewDefaultCache instanciates a new Cache of the given image with 64x64 tiles and 256 tiles.
+func NewDefaultCache(m hdr.Image) *Cache {
+	return NewCache(m, 64, 256)
+}
+
+// ColorModel returns the color model of the cached channels.
+func (c *Cache) ColorModel() color.Model {
+	return c.space.Model()
+}
+
+// Bounds implements image.Image interface.
+func (c *Cache) Bounds() image.Rectangle {
+	return c.HDRImage.Bounds()
+}
+
+// Size implements Image.
+func (c *Cache) Size() int {
+	return c.HDRImage.Size()
+}
+
+// At returns the color at the given coordinates.
+func (c *Cache) At(x, y int) color.Color {
+	return c.HDRAt(x, y)
+}
+
+// HDRAt returns the HDR color at the given coordinates, its tile is computed when it is not cached.
+func (c *Cache) HDRAt(x, y int) hdrcolor.Color {
+	if !(image.Point{x, y}.In(c.Bounds())) {
+		return c.space.Color(0, 0, 0)
+	}
+
+	t := c.tile(x, y)
+	i := 3 * ((y-t.rect.Min.Y)*t.rect.Dx() + x - t.rect.Min.X)
+	return c.space.Color(t.pix[i+0], t.pix[i+1], t.pix[i+2])
+}
+
+// ReadRow implements hdr.RowReader.
+func (c *Cache) ReadRow(dst []float64, x1, x2, y int, space hdr.ColorSpace) {
+	row := dst[:3*(x2-x1)]
+	for i := range row {
+		row[i] = 0
+	}
+
+	d := c.Bounds()
+	if y < d.Min.Y || y >= d.Max.Y {
+		return
+	}
+
+	for x, ix2 := xmath.Clamp(d.Min.X, d.Max.X, x1), xmath.Clamp(d.Min.X, d.Max.X, x2); x < ix2; {
+		t := c.tile(x, y)
+		n := xmath.Clamp(x, t.rect.Max.X, ix2) - x
+		i := 3 * ((y-t.rect.Min.Y)*t.rect.Dx() + x - t.rect.Min.X)
+		copy(row[3*(x-x1):], t.pix[i:i+3*n])
+		x += n
+	}
+	hdr.ConvertRow(row, c.space, space)
+}
+
+// Reset drops all the cached tiles.
+func (c *Cache) Reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.tiles = map[image.Point]*list.Element{}
+	c.lru.Init()
+}
+
+// tile returns the tile containing the pixel (x, y), it is computed and the least recently used tile evicted when needed.
+func (c *Cache) tile(x, y int) *cacheTile {
+	d := c.Bounds()
+	key := image.Pt((x-d.Min.X)/c.TileSize, (y-d.Min.Y)/c.TileSize)
+
+	c.mu.Lock()
+	if e, ok := c.tiles[key]; ok {
+		c.lru.MoveToFront(e)
+		c.mu.Unlock()
+		return e.Value.(*cacheTile)
+	}
+	c.mu.Unlock()
+
+	// The tile is computed without holding the lock, concurrent misses on the same tile compute it twice.
+	t := &cacheTile{key: key}
+	t.rect = image.Rect(
+		d.Min.X+key.X*c.TileSize,
+		d.Min.Y+key.Y*c.TileSize,
+		d.Min.X+(key.X+1)*c.TileSize,
+		d.Min.Y+(key.Y+1)*c.TileSize,
+	).Intersect(d)
+	t.pix = make([]float64, 3*t.rect.Dx()*t.rect.Dy())
+	w := 3 * t.rect.Dx()
+	for y := t.rect.Min.Y; y < t.rect.Max.Y; y++ {
+		i := (y - t.rect.Min.Y) * w
+		hdr.ReadRow(c.HDRImage, t.pix[i:i+w], t.rect.Min.X, t.rect.Max.X, y, c.space)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if e, ok := c.tiles[key]; ok {
+		c.lru.MoveToFront(e)
+		return e.Value.(*cacheTile)
+	}
+	c.tiles[key] = c.lru.PushFront(t)
+	for c.lru.Len() > c.Capacity {
+		e := c.lru.Back()
+		c.lru.Remove(e)
+		delete(c.tiles, e.Value.(*cacheTile).key)
+	}
+	return t
+}
@@ -0,0 +1,168 @@
+package filter
+
+import (
+	"fmt"
+	"image"
+	"math"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/mdouchement/hdr"
+	"github.com/mdouchement/hdr/hdrcolor"
+	"github.com/mdouchement/hdr/parallel"
+)
+
+// counter is an image which counts the reads of its pixels.
+type counter struct {
+	hdr.Image
+	reads int64
+}
+
+func (c *counter) HDRAt(x, y int) hdrcolor.Color {
+	atomic.AddInt64(&c.reads, 1)
+	return c.Image.HDRAt(x, y)
+}
+
+// chain returns a lazy filter chain of a random image with the given color model.
+func chain(r image.Rectangle, model string) hdr.Image {
+	rnd := rand.New(rand.NewSource(47))
+	var src hdr.Image = hdr.NewRGB64(r)
+	if model == "XYZ" {
+		src = hdr.NewXYZ64(r)
+	}
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			src.(hdr.ImageSet).Set(x, y, hdrcolor.RGB{R: 4 * rnd.Float64(), G: rnd.Float64() + 0.01, B: 2 * rnd.Float64()})
+		}
+	}
+
+	m := NewApply2(NewLog10(src), src, func(c1, c2 hdrcolor.Color) hdrcolor.Color {
+		r1, g1, b1, _ := c1.HDRRGBA()
+		r2, g2, b2, _ := c2.HDRRGBA()
+		return hdrcolor.RGB{R: r1 * r2, G: g1 + g2, B: b1 - b2}
+	})
+	if model == "RAW" {
+		return hdr.NewLMSCAT02w(m)
+	}
+	return m
+}
+
+func TestMaterialize(t *testing.T) {
+	r := image.Rect(-5, 3, 90, 71)
+
+	for model, expected := range map[string]string{"RGB": "*hdr.RGB64", "XYZ": "*hdr.XYZ64", "RAW": "*hdr.Planar"} {
+		m := chain(r, model)
+
+		for _, workers := range []int{1, 4} {
+			actual := MaterializeWithExecutor(&parallel.Executor{Workers: workers}, m)
+			if typ := fmt.Sprintf("%T", actual); typ != expected {
+				t.Fatalf("%s: got %s, want %s", model, typ, expected)
+			}
+			if actual.Bounds() != r {
+				t.Fatalf("%s: got bounds %v, want %v", model, actual.Bounds(), r)
+			}
+
+			// The planar buffer of the raw channels stores float32 values
+			tolerance := 0.0
+			if model == "RAW" {
+				tolerance = 1e-6
+			}
+
+			space := hdr.ModelSpace(m.ColorModel())
+			for y := r.Min.Y; y < r.Max.Y; y++ {
+				for x := r.Min.X; x < r.Max.X; x++ {
+					a1, a2, a3 := space.Channels(actual.HDRAt(x, y))
+					e1, e2, e3 := space.Channels(m.HDRAt(x, y))
+					if math.Abs(a1-e1) > tolerance*math.Abs(e1) || math.Abs(a2-e2) > tolerance*math.Abs(e2) || math.Abs(a3-e3) > tolerance*math.Abs(e3) {
+						t.Fatalf("%s, %d workers: pixel (%d, %d): got %v, want %v", model, workers, x, y, []float64{a1, a2, a3}, []float64{e1, e2, e3})
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestCache(t *testing.T) {
+	r := image.Rect(-5, 3, 90, 71)
+
+	for _, model := range []string{"RGB", "XYZ", "RAW"} {
+		m := chain(r, model)
+		c := NewCache(m, 16, 4)
+
+		// Same pixels as the chain, in any order
+		for _, i := range rand.New(rand.NewSource(47)).Perm(r.Dx() * r.Dy()) {
+			x, y := r.Min.X+i%r.Dx(), r.Min.Y+i/r.Dx()
+			if a, e := c.HDRAt(x, y), m.HDRAt(x, y); a != e {
+				t.Fatalf("%s: pixel (%d, %d): got %v, want %v", model, x, y, a, e)
+			}
+		}
+
+		// The rows are converted like the chain ones
+		d := m.Bounds()
+		actual := make([]float64, 3*d.Dx())
+		expected := make([]float64, 3*d.Dx())
+		for _, space := range []hdr.ColorSpace{hdr.RGBSpace, hdr.XYZSpace, hdr.RAWSpace} {
+			for y := d.Min.Y; y < d.Max.Y; y++ {
+				hdr.ReadRow(c, actual, d.Min.X, d.Max.X, y, space)
+				hdr.ReadRow(m, expected, d.Min.X, d.Max.X, y, space)
+				for i, v := range expected {
+					if actual[i] != v {
+						t.Fatalf("%s, space %d: pixel (%d, %d): got %v, want %v", model, space, d.Min.X+i/3, y, actual[i], v)
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestCacheEviction(t *testing.T) {
+	src := &counter{Image: hdr.NewRGB64(image.Rect(0, 0, 32, 8))}
+	c := NewCache(src, 8, 2)
+
+	read := func(x int, tile string, computed bool) {
+		t.Helper()
+
+		before := atomic.LoadInt64(&src.reads)
+		c.HDRAt(x, 0)
+		if reads := atomic.LoadInt64(&src.reads) - before; (reads > 0) != computed {
+			t.Fatalf("tile %s: got %d reads, want computed %v", tile, reads, computed)
+		}
+	}
+
+	read(0, "A", true)
+	read(8, "B", true)
+	read(1, "A", false)
+	read(16, "C", true) // Evicts B, the least recently used tile
+	read(2, "A", false)
+	read(9, "B", true) // Evicts C
+	read(17, "C", true)
+
+	c.Reset()
+	read(3, "A", true)
+}
+
+func TestCacheConcurrent(t *testing.T) {
+	r := image.Rect(0, 0, 64, 64)
+	m := chain(r, "RGB")
+	c := NewCache(m, 8, 3)
+
+	var wg sync.WaitGroup
+	for w := 0; w < 8; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+
+			rnd := rand.New(rand.NewSource(int64(w)))
+			for i := 0; i < 2000; i++ {
+				x, y := rnd.Intn(r.Dx()), rnd.Intn(r.Dy())
+				if a, e := c.HDRAt(x, y), m.HDRAt(x, y); a != e {
+					t.Errorf("pixel (%d, %d): got %v, want %v", x, y, a, e)
+					return
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+}
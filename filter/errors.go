@@ -0,0 +1,31 @@
+package filter
+
+import "github.com/mdouchement/hdr"
+
+// An UnsupportedError reports that a filter does not support the given feature (e.g. a color space).
+type UnsupportedError string
+
+func (e UnsupportedError) Error() string {
+	return "filter: unsupported feature: " + string(e)
+}
+
+// An ArgumentError reports that a filter is instanciated with an invalid argument.
+type ArgumentError string
+
+func (e ArgumentError) Error() string {
+	return "filter: invalid argument: " + string(e)
+}
+
+// checkSpace returns an error when the images are missing or the filter can not work in the given color space.
+func checkSpace(space hdr.ColorSpace, images ...hdr.Image) error {
+	for _, m := range images {
+		if m == nil {
+			return ArgumentError("nil image")
+		}
+	}
+
+	if !space.Valid() {
+		return UnsupportedError("color space")
+	}
+	return nil
+}
@@ -10,6 +10,7 @@ import (
 
 	"github.com/mdouchement/hdr"
 	"github.com/mdouchement/hdr/hdrcolor"
+	"github.com/mdouchement/hdr/parallel"
 	"github.com/mdouchement/hdr/xmath"
 	"gonum.org/v1/gonum/mat"
 )
@@ -33,10 +34,14 @@ const (
 // References:
 // https://github.com/mdouchement/bilateral
 // http://people.csail.mit.edu/sparis/bf/
+//
+// The colors are filtered in its working color space (RGB by default).
 type FastBilateral struct {
 	HDRImage   hdr.Image
 	SigmaRange float64
 	SigmaSpace float64
+	// Executor cancels and reports the progress of Perform (optional).
+	Executor   *parallel.Executor
 	minmaxOnce sync.Once
 	min        []float64
 	max        []float64
@@ -46,9 +51,10 @@ type FastBilateral struct {
 	// 2 -> smallColor1Depth (gray & color)
 	// 3 -> smallColor2Depth (color)
 	// 4 -> smallColor3Depth (color)
-	size []int
-	grid *grid
-	auto bool
+	size  []int
+	grid  *grid
+	auto  bool
+	space hdr.ColorSpace
 }
 
 // NewFastBilateralAuto instanciates a new FastBilateral with automatic sigma values.
@@ -58,7 +64,8 @@ func NewFastBilateralAuto(m hdr.Image) *FastBilateral {
 	return f
 }
 
-// NewFastBilateral instanciates a new FastBilateral.
+// NewFastBilateral instanciates a new FastBilateral working in RGB.
+// The arguments are not validated, see NewFastBilateralWithSpace.
 func NewFastBilateral(m hdr.Image, sigmaSpace, sigmaRange float64) *FastBilateral {
 	fbl := &FastBilateral{
 		HDRImage:   m,
@@ -67,6 +74,7 @@ func NewFastBilateral(m hdr.Image, sigmaSpace, sigmaRange float64) *FastBilatera
 		min:        make([]float64, dimension-2),
 		max:        make([]float64, dimension-2),
 		size:       make([]int, dimension),
+		space:      hdr.RGBSpace,
 	}
 	for i := range fbl.min {
 		fbl.min[i] = math.Inf(1)
@@ -76,16 +84,65 @@ func NewFastBilateral(m hdr.Image, sigmaSpace, sigmaRange float64) *FastBilatera
 	return fbl
 }
 
+// NewFastBilateralWithSpace instanciates a new FastBilateral working in the given color space.
+// It returns an ArgumentError when the image is nil or a sigma is not a positive finite number.
+func NewFastBilateralWithSpace(m hdr.Image, space hdr.ColorSpace, sigmaSpace, sigmaRange float64) (*FastBilateral, error) {
+	if err := checkSpace(space, m); err != nil {
+		return nil, err
+	}
+	if err := checkBilateral(m, sigmaSpace, sigmaRange); err != nil {
+		return nil, err
+	}
+
+	f := NewFastBilateral(m, sigmaSpace, sigmaRange)
+	f.space = space
+	return f, nil
+}
+
+// checkBilateral validates the arguments of the bilateral filters.
+func checkBilateral(m hdr.Image, sigmaSpace, sigmaRange float64) error {
+	if m == nil {
+		return ArgumentError("nil image")
+	}
+	if !(sigmaSpace > 0) || math.IsInf(sigmaSpace, 1) {
+		return ArgumentError("sigma space must be a positive number")
+	}
+	if !(sigmaRange > 0) || math.IsInf(sigmaRange, 1) {
+		return ArgumentError("sigma range must be a positive number")
+	}
+	return nil
+}
+
 // Perform runs the bilateral filter.
+// The remaining stages are skipped once the executor context is done.
 func (f *FastBilateral) Perform() {
-	f.minmaxOnce.Do(f.minmax)
-	f.downsampling()
-	f.convolution()
+	var src *hdr.Planar // The grid is built from the color planes
+	stages := []func(){
+		func() { src = toPlanar(f.Executor, f.HDRImage, f.space) },
+		func() { f.minmaxOnce.Do(func() { f.minmax(src) }) },
+		func() { f.downsampling(src) },
+		f.convolution,
+	}
+	performStages(f.Executor, stages)
 }
 
-// ColorModel returns the Image's color model.
+// performStages runs the stages in order until the executor context is done.
+// Each stage is a unit of work of the executor progress.
+func performStages(e *parallel.Executor, stages []func()) {
+	e.Begin(len(stages))
+	for _, stage := range stages {
+		if e.Err() != nil {
+			return
+		}
+
+		stage()
+		e.Advance(1)
+	}
+}
+
+// ColorModel returns the color model of the working color space.
 func (f *FastBilateral) ColorModel() color.Model {
-	return hdrcolor.RGBModel
+	return f.space.Model()
 }
 
 // Bounds implements image.Image interface.
@@ -100,11 +157,30 @@ func (f *FastBilateral) Size() int {
 
 // HDRAt computes the interpolation and returns the filtered color at the given coordinates.
 func (f *FastBilateral) HDRAt(x, y int) hdrcolor.Color {
-	pixel := f.HDRImage.HDRAt(x, y)
-	r, g, b, _ := pixel.HDRRGBA()
-	rgb := []float64{r, g, b}
+	p1, p2, p3 := f.space.Channels(f.HDRImage.HDRAt(x, y))
+	c := []float64{p1, p2, p3}
+
+	min := f.HDRImage.Bounds().Min
+	f.interpolate(x-min.X, y-min.Y, c, make([]float64, dimension))
+	return f.space.Color(c[c1], c[c2], c[c3])
+}
 
+// ReadRow implements hdr.RowReader.
+func (f *FastBilateral) ReadRow(dst []float64, x1, x2, y int, space hdr.ColorSpace) {
+	row := dst[:3*(x2-x1)]
+	hdr.ReadRow(f.HDRImage, row, x1, x2, y, f.space)
+
+	min := f.HDRImage.Bounds().Min
 	offset := make([]float64, dimension)
+	for x, i := x1, 0; x < x2; x, i = x+1, i+3 {
+		f.interpolate(x-min.X, y-min.Y, row[i:i+3], offset)
+	}
+	hdr.ConvertRow(row, f.space, space)
+}
+
+// interpolate replaces the given color (in the working color space) of the pixel (x, y), relative to the image origin, by its filtered color.
+// offset is a buffer of the grid dimension.
+func (f *FastBilateral) interpolate(x, y int, rgb, offset []float64) {
 	// Grid coords
 	offset[0] = float64(x)/f.SigmaSpace + paddingS // Grid width
 	offset[1] = float64(y)/f.SigmaSpace + paddingS // Grid height
@@ -115,11 +191,9 @@ func (f *FastBilateral) HDRAt(x, y int) hdrcolor.Color {
 	c := f.nLinearInterpolation(offset...)
 	c.colors.ScaleVec(1/c.threshold, c.colors) // Normalize
 
-	return hdrcolor.RGB{
-		R: c.colors.AtVec(c1),
-		G: c.colors.AtVec(c2),
-		B: c.colors.AtVec(c3),
-	}
+	rgb[c1] = c.colors.AtVec(c1)
+	rgb[c2] = c.colors.AtVec(c2)
+	rgb[c3] = c.colors.AtVec(c3)
 }
 
 // At computes the interpolation and returns the filtered color at the given coordinates.
@@ -137,10 +211,10 @@ func (f *FastBilateral) At(x, y int) color.Color {
 func (f *FastBilateral) HDRResultImage() hdr.Image {
 	d := f.HDRImage.Bounds()
 	dst := hdr.NewRGB(d)
-	for x := 0; x < d.Dx(); x++ {
-		for y := 0; y < d.Dy(); y++ {
-			dst.Set(x, y, f.HDRAt(x, y))
-		}
+	row := make([]float64, 3*d.Dx())
+	for y := d.Min.Y; y < d.Max.Y; y++ {
+		f.ReadRow(row, d.Min.X, d.Max.X, y, hdr.RGBSpace)
+		dst.WriteRow(row, d.Min.X, d.Max.X, y, hdr.RGBSpace)
 	}
 	return dst
 }
@@ -149,22 +223,21 @@ func (f *FastBilateral) HDRResultImage() hdr.Image {
 func (f *FastBilateral) ResultImage() hdr.Image {
 	d := f.HDRImage.Bounds()
 	dst := hdr.NewRGB(d)
-	for x := 0; x < d.Dx(); x++ {
-		for y := 0; y < d.Dy(); y++ {
-			dst.Set(x, y, f.HDRAt(x, y))
-		}
+	row := make([]float64, 3*d.Dx())
+	for y := d.Min.Y; y < d.Max.Y; y++ {
+		f.ReadRow(row, d.Min.X, d.Max.X, y, hdr.RGBSpace)
+		dst.WriteRow(row, d.Min.X, d.Max.X, y, hdr.RGBSpace)
 	}
 	return dst
 }
 
-func (f *FastBilateral) minmax() {
-	d := f.HDRImage.Bounds()
-	for y := 0; y < d.Dy(); y++ {
-		for x := 0; x < d.Dx(); x++ {
-			r, g, b, _ := f.HDRImage.HDRAt(x, y).HDRRGBA()
-			for ci, c := range []float64{r, g, b} {
-				f.min[ci] = math.Min(f.min[ci], c)
-				f.max[ci] = math.Max(f.max[ci], c)
+func (f *FastBilateral) minmax(src *hdr.Planar) {
+	d := src.Bounds()
+	for y := d.Min.Y; y < d.Max.Y; y++ {
+		for ci := range src.Planes {
+			for _, c := range src.Row(ci, y) {
+				f.min[ci] = math.Min(f.min[ci], float64(c))
+				f.max[ci] = math.Max(f.max[ci], float64(c))
 			}
 		}
 	}
@@ -176,7 +249,9 @@ func (f *FastBilateral) minmax() {
 			min = math.Min(min, f.min[n])
 			max = math.Max(max, f.max[n])
 		}
-		f.SigmaRange = (max - min) * 0.1
+		if sigma := (max - min) * 0.1; sigma > 0 { // Keeps the default sigma on flat images
+			f.SigmaRange = sigma
+		}
 	}
 
 	f.size[0] = int(float64(d.Dx()-1)/f.SigmaSpace) + 1 + 2*paddingS
@@ -190,28 +265,30 @@ func (f *FastBilateral) minmax() {
 	// fmt.Println("size:", xmath.Mul(f.size...), f.size)
 }
 
-func (f *FastBilateral) downsampling() {
-	d := f.HDRImage.Bounds()
+func (f *FastBilateral) downsampling(src *hdr.Planar) {
+	d := src.Bounds()
 	offset := make([]int, dimension)
 
 	dim := dimension - 2
 	f.grid = newGrid(f.size, dim)
 
-	for x := 0; x < d.Dx(); x++ {
-		offset[0] = int(1*float64(x)/f.SigmaSpace+0.5) + paddingS
-
-		for y := 0; y < d.Dy(); y++ {
-			offset[1] = int(1*float64(y)/f.SigmaSpace+0.5) + paddingS
+	rgb := make([]float64, dim)
+	for y := 0; y < d.Dy(); y++ {
+		offset[1] = int(1*float64(y)/f.SigmaSpace+0.5) + paddingS
+		r, g, b := src.Row(c1, d.Min.Y+y), src.Row(c2, d.Min.Y+y), src.Row(c3, d.Min.Y+y)
 
-			r, g, b, _ := f.HDRImage.HDRAt(x, y).HDRRGBA()
-			rgb := []float64{r, g, b}
+		for x := 0; x < d.Dx(); x++ {
+			offset[0] = int(1*float64(x)/f.SigmaSpace+0.5) + paddingS
 
+			rgb[c1], rgb[c2], rgb[c3] = float64(r[x]), float64(g[x]), float64(b[x])
 			for z := 0; z < dimension-2; z++ {
 				offset[2+z] = int((rgb[z]-f.min[z])/f.SigmaRange+0.5) + paddingR
 			}
 
 			v := f.grid.At(offset...)
-			v.colors.AddVec(v.colors, mat.NewVecDense(dim, rgb))
+			for z := 0; z < dim; z++ {
+				v.colors.SetVec(z, v.colors.AtVec(z)+rgb[z])
+			}
 			v.threshold++
 		}
 	}
@@ -320,11 +397,8 @@ type (
 	}
 )
 
+// newGrid allocates a grid of the given size, which holds the `dimension' X, Y & Zs' dimensions.
 func newGrid(size []int, n int) *grid {
-	if len(size) < 3 {
-		panic("Grid size must be greater or equals to 3")
-	}
-
 	cells := make([][][][][]*cell, size[xi])
 	for x := range cells {
 		cells[x] = make([][][][]*cell, size[yi])
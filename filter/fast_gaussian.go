@@ -4,7 +4,8 @@ import (
 	"math"
 
 	"github.com/mdouchement/hdr"
-	"github.com/mdouchement/hdr/hdrcolor"
+	"github.com/mdouchement/hdr/parallel"
+	"github.com/mdouchement/hdr/xmath"
 )
 
 // fast gaussian blur based on http://blog.ivank.net/fastest-gaussian-blur.html
@@ -12,131 +13,143 @@ import (
 
 // FastGaussian blurs im using a fast approximation of gaussian blur.
 // The algorithm has a computational complexity independent of radius.
+// The channels are blurred on their own plane and the pixels beyond the borders replicate the edges.
+// The returned image has the type of src (see hdr.EmptyAs).
 func FastGaussian(src hdr.Image, radius int) hdr.Image {
+	return FastGaussianWithExecutor(nil, src, radius)
+}
+
+// FastGaussianWithExecutor is like FastGaussian but runs on the given executor.
+// When the executor context is done, the remaining rows and columns are not blurred.
+func FastGaussianWithExecutor(e *parallel.Executor, src hdr.Image, radius int) hdr.Image {
 	boxes := determineBoxes(float64(radius), 3)
-	tmp := hdr.EmptyAs(src)
-	dst := hdr.EmptyAs(src)
-	boxBlur4(dst, tmp, src, (boxes[0]-1)/2)
-	boxBlur4(dst, tmp, dst, (boxes[1]-1)/2)
-	boxBlur4(dst, tmp, dst, (boxes[2]-1)/2)
+	dst := planarCopy(e, src)
+	scratch := hdr.EmptyAs(dst).(*hdr.Planar)
 
-	return dst
-}
+	w, h := dst.Rect.Dx(), dst.Rect.Dy()
+	if w == 0 || h == 0 {
+		return fromPlanar(e, dst, src)
+	}
 
-func boxBlur4(dst, scratch, src hdr.Image, radius int) {
-	if src == scratch || dst == scratch {
-		panic("scratch must be different than src and dst")
+	for c := range dst.Planes {
+		for _, box := range boxes {
+			completed := e.Lines(h, func(y1, y2 int) {
+				boxBlurH(scratch.Planes[c], dst.Planes[c], y1, y2, w, dst.Stride, (box-1)/2)
+			})
+			<-completed
+
+			completed = e.Lines(w, func(x1, x2 int) {
+				boxBlurV(dst.Planes[c], scratch.Planes[c], x1, x2, h, dst.Stride, (box-1)/2)
+			})
+			<-completed
+		}
 	}
-	boxBlurH(scratch.(hdr.ImageSet), src, radius)
-	boxBlurV(dst.(hdr.ImageSet), scratch, radius)
+
+	return fromPlanar(e, dst, src)
 }
 
-func boxBlurH(dst hdr.ImageSet, src hdr.Image, radius int) {
-	w, h := src.Bounds().Dx(), src.Bounds().Dy()
+// boxBlurH blurs the rows [y1, y2) of the src plane into the dst plane.
+func boxBlurH(dst, src []float32, y1, y2, w, stride, radius int) {
 	r1 := radius + 1
 	r1f := float64(r1)
 	r2f := float64(2*radius + 1)
-	var vr, vg, vb float64
 
-	for y := 0; y < h; y++ {
-		fvr, fvg, fvb, _ := src.HDRAt(0, y).HDRRGBA()
-		lvr, lvg, lvb, _ := src.HDRAt(w-1, y).HDRRGBA()
+	for y := y1; y < y2; y++ {
+		in := src[y*stride : y*stride+w]
+		out := dst[y*stride : y*stride+w]
+
+		// The sliding window can overflow small images, the values outside the row replicate the edges
+		at := func(x int) float64 {
+			return float64(in[xmath.Clamp(0, w-1, x)])
+		}
+		set := func(x int, v float64) {
+			if x >= 0 && x < w {
+				out[x] = float32(v / r2f)
+			}
+		}
 
-		vr = r1f * fvr
-		vg = r1f * fvg
-		vb = r1f * fvb
+		fv, lv := at(0), at(w-1)
+		v := r1f * fv
 
 		for x := 0; x < radius; x++ {
-			r, g, b, _ := src.HDRAt(x, y).HDRRGBA()
-			vr += r
-			vg += g
-			vb += b
+			v += at(x)
 		}
 
 		for x := 0; x < r1; x++ {
-			r, g, b, _ := src.HDRAt(x+radius, y).HDRRGBA()
-			vr += r - fvr
-			vg += g - fvg
-			vb += b - fvb
-
-			dst.Set(x, y, hdrcolor.RGB{R: vr / r2f, G: vg / r2f, B: vb / r2f})
+			v += at(x+radius) - fv
+			set(x, v)
 		}
 
 		for x := r1; x < w-radius; x++ {
-			r, g, b, _ := src.HDRAt(x+radius, y).HDRRGBA()
-			r1, g1, b1, _ := src.HDRAt(x-r1, y).HDRRGBA()
-
-			vr += r - r1
-			vg += g - g1
-			vb += b - b1
-
-			dst.Set(x, y, hdrcolor.RGB{R: vr / r2f, G: vg / r2f, B: vb / r2f})
+			v += float64(in[x+radius]) - float64(in[x-r1])
+			out[x] = float32(v / r2f)
 		}
 
 		for x := w - radius; x < w; x++ {
-			r, g, b, _ := src.HDRAt(x-r1, y).HDRRGBA()
-
-			vr += lvr - r
-			vg += lvg - g
-			vb += lvb - b
-
-			dst.Set(x, y, hdrcolor.RGB{R: vr / r2f, G: vg / r2f, B: vb / r2f})
+			v += lv - at(x-r1)
+			set(x, v)
 		}
 	}
 }
 
-func boxBlurV(dst hdr.ImageSet, src hdr.Image, radius int) {
-	w, h := src.Bounds().Dx(), src.Bounds().Dy()
-
+// boxBlurV blurs the columns [x1, x2) of the src plane into the dst plane.
+// The columns are processed together row by row.
+func boxBlurV(dst, src []float32, x1, x2, h, stride, radius int) {
+	w := x2 - x1
 	r1 := radius + 1
 	r1f := float64(r1)
 	r2f := float64(2*radius + 1)
-	var vr, vg, vb float64
-
-	for x := 0; x < w; x++ {
-		fvr, fvg, fvb, _ := src.HDRAt(x, 0).HDRRGBA()
-		lvr, lvg, lvb, _ := src.HDRAt(x, h-1).HDRRGBA()
 
-		vr = r1f * fvr
-		vg = r1f * fvg
-		vb = r1f * fvb
-
-		for y := 0; y < radius; y++ {
-			r, g, b, _ := src.HDRAt(x, y).HDRRGBA()
-			vr += r
-			vg += g
-			vb += b
+	// The sliding window can overflow small images, the rows outside the plane replicate the edges
+	row := func(y int) []float32 {
+		y = xmath.Clamp(0, h-1, y)
+		return src[y*stride+x1 : y*stride+x2]
+	}
+	v := make([]float64, w) // Accumulators of the columns
+	set := func(y int) {
+		if y < 0 || y >= h {
+			return
 		}
-
-		for y := 0; y < r1; y++ {
-			r, g, b, _ := src.HDRAt(x, y+radius).HDRRGBA()
-			vr += r - fvr
-			vg += g - fvg
-			vb += b - fvb
-
-			dst.Set(x, y, hdrcolor.RGB{R: vr / r2f, G: vg / r2f, B: vb / r2f})
+		out := dst[y*stride+x1 : y*stride+x2]
+		for x := range v {
+			out[x] = float32(v[x] / r2f)
 		}
-		for y := r1; y < h-radius; y++ {
-			r, g, b, _ := src.HDRAt(x, y+radius).HDRRGBA()
-			r1, g1, b1, _ := src.HDRAt(x, y-r1).HDRRGBA()
+	}
 
-			vr += r - r1
-			vg += g - g1
-			vb += b - b1
+	first, last := row(0), row(h-1)
+	for x := range v {
+		v[x] = r1f * float64(first[x])
+	}
 
-			dst.Set(x, y, hdrcolor.RGB{R: vr / r2f, G: vg / r2f, B: vb / r2f})
+	for y := 0; y < radius; y++ {
+		in := row(y)
+		for x := range v {
+			v[x] += float64(in[x])
 		}
+	}
 
-		for y := h - radius; y < h; y++ {
-			r, g, b, _ := src.HDRAt(x, y-r1).HDRRGBA()
-
-			vr += lvr - r
-			vg += lvg - g
-			vb += lvb - b
+	for y := 0; y < r1; y++ {
+		in := row(y + radius)
+		for x := range v {
+			v[x] += float64(in[x]) - float64(first[x])
+		}
+		set(y)
+	}
 
-			dst.Set(x, y, hdrcolor.RGB{R: vr / r2f, G: vg / r2f, B: vb / r2f})
+	for y := r1; y < h-radius; y++ {
+		in, out := row(y+radius), row(y-r1)
+		for x := range v {
+			v[x] += float64(in[x]) - float64(out[x])
+		}
+		set(y)
+	}
 
+	for y := h - radius; y < h; y++ {
+		out := row(y - r1)
+		for x := range v {
+			v[x] += float64(last[x]) - float64(out[x])
 		}
+		set(y)
 	}
 }
 
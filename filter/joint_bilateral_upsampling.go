@@ -0,0 +1,83 @@
+package filter
+
+import (
+	"image"
+	"math"
+
+	"github.com/mdouchement/hdr"
+	"github.com/mdouchement/hdr/hdrcolor"
+	"github.com/mdouchement/hdr/parallel"
+)
+
+const (
+	jbuRadius     = 2 // Low resolution window radius (pixels)
+	jbuSigmaSpace = 1 // Low resolution spatial sigma (pixels)
+)
+
+// JointBilateralUpsample upsamples the low resolution image with the edges of a high resolution guide.
+// The returned image has the size of the guide and a zero origin.
+// transform maps the guide pixels to the (continuous) coordinates of the low resolution image and lowGuide.
+// The guides are usually log-luminances, sigmaRange uses the same unit (+Inf disables the range weighting).
+//
+// Reference:
+// Joint Bilateral Upsampling.
+// J. Kopf, M. F. Cohen, D. Lischinski and M. Uyttendaele.
+// In ACM Transactions on Graphics (SIGGRAPH), 2007.
+func JointBilateralUpsample(low hdr.Image, lowGuide, guide *Plane, transform func(x, y int) (float64, float64), sigmaRange float64) *hdr.XYZ {
+	return JointBilateralUpsampleWithExecutor(nil, low, lowGuide, guide, transform, sigmaRange)
+}
+
+// JointBilateralUpsampleWithExecutor is like JointBilateralUpsample but runs on the given executor.
+// When the executor context is done, the remaining pixels are zero.
+func JointBilateralUpsampleWithExecutor(e *parallel.Executor, low hdr.Image, lowGuide, guide *Plane, transform func(x, y int) (float64, float64), sigmaRange float64) *hdr.XYZ {
+	dst := hdr.NewXYZ(image.Rect(0, 0, guide.Width, guide.Height))
+	d := low.Bounds()
+
+	completed := e.TilesR(dst.Bounds(), func(x1, y1, x2, y2 int) {
+		for y := y1; y < y2; y++ {
+			for x := x1; x < x2; x++ {
+				lx, ly := transform(x, y)
+				g := guide.At(x, y)
+				cx, cy := int(math.Floor(lx)), int(math.Floor(ly))
+
+				var c hdrcolor.XYZ
+				var sum, spatialSum float64
+				var spatial hdrcolor.XYZ // Fallback without range weighting
+
+				for qy := cy - jbuRadius + 1; qy <= cy+jbuRadius; qy++ {
+					for qx := cx - jbuRadius + 1; qx <= cx+jbuRadius; qx++ {
+						if qx < 0 || qy < 0 || qx >= d.Dx() || qy >= d.Dy() {
+							continue
+						}
+
+						dx, dy := float64(qx)-lx, float64(qy)-ly
+						ws := math.Exp(-(dx*dx + dy*dy) / (2 * jbuSigmaSpace * jbuSigmaSpace))
+						dr := g - lowGuide.At(qx, qy)
+						w := ws * math.Exp(-(dr*dr)/(2*sigmaRange*sigmaRange))
+
+						X, Y, Z, _ := low.HDRAt(d.Min.X+qx, d.Min.Y+qy).HDRXYZA()
+						c.X += w * X
+						c.Y += w * Y
+						c.Z += w * Z
+						sum += w
+
+						spatial.X += ws * X
+						spatial.Y += ws * Y
+						spatial.Z += ws * Z
+						spatialSum += ws
+					}
+				}
+
+				if sum < 1e-12 {
+					c, sum = spatial, spatialSum
+				}
+				if sum > 0 {
+					dst.SetXYZ(x, y, hdrcolor.XYZ{X: c.X / sum, Y: c.Y / sum, Z: c.Z / sum})
+				}
+			}
+		}
+	})
+	<-completed
+
+	return dst
+}
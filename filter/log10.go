@@ -11,53 +11,37 @@ import (
 )
 
 // A Log10 applies a log10 for all pixels of the image.
+// The log10 is applied on the channels of its working color space.
 type Log10 struct {
 	HDRImage hdr.Image
-	hdrat    func(x, y int) hdrcolor.Color
+	space    hdr.ColorSpace
 }
 
-// NewLog10 instanciates a new Log10 filter.
+// NewLog10 instanciates a new Log10 filter working in the color space of the image
+// (hdr.RAWSpace for the color models other than RGB and XYZ).
+// It panics if m is nil, NewLog10WithSpace returns an error instead.
 func NewLog10(m hdr.Image) *Log10 {
-	f := &Log10{
+	return &Log10{
 		HDRImage: m,
+		space:    hdr.ModelSpace(m.ColorModel()),
 	}
+}
 
-	log10 := func(x float64) float64 {
-		if x < 0.0001 {
-			x = 0.0001
-		}
-		return math.Log10(x)
-	}
-
-	switch m.ColorModel() {
-	case hdrcolor.XYZModel:
-		f.hdrat = func(x, y int) hdrcolor.Color {
-			X, Y, Z, _ := f.HDRImage.HDRAt(x, y).HDRXYZA()
-			return hdrcolor.XYZ{
-				X: log10(X),
-				Y: log10(Y),
-				Z: log10(Z),
-			}
-		}
-	case hdrcolor.RGBModel:
-		fallthrough
-	default:
-		f.hdrat = func(x, y int) hdrcolor.Color {
-			r, g, b, _ := f.HDRImage.HDRAt(x, y).HDRRGBA()
-			return hdrcolor.RGB{
-				R: log10(r),
-				G: log10(g),
-				B: log10(b),
-			}
-		}
+// NewLog10WithSpace instanciates a new Log10 filter working on the channels of the given color space.
+func NewLog10WithSpace(m hdr.Image, space hdr.ColorSpace) (*Log10, error) {
+	if err := checkSpace(space, m); err != nil {
+		return nil, err
 	}
 
-	return f
+	return &Log10{
+		HDRImage: m,
+		space:    space,
+	}, nil
 }
 
-// ColorModel returns the Image's color model.
+// ColorModel returns the color model of the working color space.
 func (f *Log10) ColorModel() color.Model {
-	return f.HDRImage.ColorModel()
+	return f.space.Model()
 }
 
 // Bounds implements image.Image interface.
@@ -72,7 +56,18 @@ func (f *Log10) Size() int {
 
 // HDRAt computes the log10(x) and returns the filtered color at the given coordinates.
 func (f *Log10) HDRAt(x, y int) hdrcolor.Color {
-	return f.hdrat(x, y)
+	p1, p2, p3 := f.space.Channels(f.HDRImage.HDRAt(x, y))
+	return f.space.Color(log10(p1), log10(p2), log10(p3))
+}
+
+// ReadRow implements hdr.RowReader.
+func (f *Log10) ReadRow(dst []float64, x1, x2, y int, space hdr.ColorSpace) {
+	row := dst[:3*(x2-x1)]
+	hdr.ReadRow(f.HDRImage, row, x1, x2, y, f.space)
+	for i, v := range row {
+		row[i] = log10(v)
+	}
+	hdr.ConvertRow(row, f.space, space)
 }
 
 // At computes the log10(x) and returns the filtered color at the given coordinates.
@@ -85,3 +80,10 @@ func (f *Log10) At(x, y int) color.Color {
 		A: 255,
 	}
 }
+
+func log10(x float64) float64 {
+	if x < 0.0001 {
+		x = 0.0001
+	}
+	return math.Log10(x)
+}
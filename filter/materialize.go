@@ -0,0 +1,37 @@
+package filter
+
+import (
+	"github.com/mdouchement/hdr"
+	"github.com/mdouchement/hdr/parallel"
+)
+
+// Materialize evaluates in parallel the (lazy) filter chain m into a concrete buffer,
+// so the next accesses to its pixels do not compute the chain again.
+// The returned image has the bounds of m, it is a hdr.RGB64 for the RGB color model, a hdr.XYZ64 for the XYZ color model
+// and a hdr.Planar with the raw channels for the other ones.
+func Materialize(m hdr.Image) hdr.Image {
+	return MaterializeWithExecutor(nil, m)
+}
+
+// MaterializeWithExecutor is like Materialize but runs on the given executor.
+// When the executor context is done, the remaining pixels are zero.
+func MaterializeWithExecutor(e *parallel.Executor, m hdr.Image) hdr.Image {
+	d := m.Bounds()
+	space := colorSpace(m)
+
+	var dst hdr.Buffer = hdr.NewPlanar(d, space)
+	if space != hdr.RAWSpace {
+		dst = hdr.NewBuffer(d, space, hdr.Float64)
+	}
+
+	completed := e.TilesR(d, func(x1, y1, x2, y2 int) {
+		row := make([]float64, 3*(x2-x1))
+		for y := y1; y < y2; y++ {
+			hdr.ReadRow(m, row, x1, x2, y, space)
+			dst.WriteRow(row, x1, x2, y, space)
+		}
+	})
+	<-completed
+
+	return dst
+}
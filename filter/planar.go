@@ -0,0 +1,62 @@
+package filter
+
+import (
+	"github.com/mdouchement/hdr"
+	"github.com/mdouchement/hdr/parallel"
+)
+
+// toPlanar returns m as a planar image in the given color space, the conversion runs on the given executor.
+// m is returned as is when it is already a Planar in this space.
+func toPlanar(e *parallel.Executor, m hdr.Image, space hdr.ColorSpace) *hdr.Planar {
+	if p, ok := m.(*hdr.Planar); ok && p.Space == space {
+		return p
+	}
+
+	d := m.Bounds()
+	p := hdr.NewPlanar(d, space)
+
+	completed := e.TilesR(d, func(x1, y1, x2, y2 int) {
+		row := make([]float64, 3*(x2-x1))
+		for y := y1; y < y2; y++ {
+			hdr.ReadRow(m, row, x1, x2, y, space)
+			p.WriteRow(row, x1, x2, y, space)
+		}
+	})
+	<-completed
+
+	return p
+}
+
+// planarCopy returns a planar copy of m in its color space, the conversion runs on the given executor.
+func planarCopy(e *parallel.Executor, m hdr.Image) *hdr.Planar {
+	p := toPlanar(e, m, colorSpace(m))
+	if hdr.Image(p) == m {
+		return hdr.Copy(p).(*hdr.Planar)
+	}
+	return p
+}
+
+// fromPlanar returns p converted to the image type of src (see hdr.EmptyAs), the conversion runs on the given executor.
+// p is returned as is when src is a Planar.
+func fromPlanar(e *parallel.Executor, p *hdr.Planar, src hdr.Image) hdr.Image {
+	if _, ok := src.(*hdr.Planar); ok {
+		return p
+	}
+
+	dst := hdr.EmptyAs(src).(hdr.ImageSet)
+	completed := e.TilesR(p.Rect, func(x1, y1, x2, y2 int) {
+		row := make([]float64, 3*(x2-x1))
+		for y := y1; y < y2; y++ {
+			p.ReadRow(row, x1, x2, y, p.Space)
+			hdr.WriteRow(dst, row, x1, x2, y, p.Space)
+		}
+	})
+	<-completed
+
+	return dst.(hdr.Image)
+}
+
+// colorSpace returns the color space of the channels of m.
+func colorSpace(m hdr.Image) hdr.ColorSpace {
+	return hdr.ModelSpace(m.ColorModel())
+}
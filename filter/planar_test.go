@@ -0,0 +1,65 @@
+package filter
+
+import (
+	"image"
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/mdouchement/hdr"
+	"github.com/mdouchement/hdr/hdrcolor"
+	"github.com/mdouchement/hdr/parallel"
+)
+
+func TestPlanarFilters(t *testing.T) {
+	r := image.Rect(2, -3, 71, 50)
+	rnd := rand.New(rand.NewSource(46))
+
+	src := hdr.NewRGB64(r)
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			src.SetRGB(x, y, hdrcolor.RGB{R: 4 * rnd.Float64(), G: rnd.Float64(), B: 2 * rnd.Float64()})
+		}
+	}
+	planar := hdr.ToPlanar(src, hdr.RGBSpace)
+	original := hdr.Copy(planar)
+
+	blurs := map[string]func(e *parallel.Executor, m hdr.Image, radius int) hdr.Image{
+		"FastGaussian": FastGaussianWithExecutor,
+		"StackBlur":    StackBlurWithExecutor,
+	}
+
+	for name, blur := range blurs {
+		e := &parallel.Executor{Workers: 3}
+
+		// The result has the type of the source
+		expected := blur(e, src, 5)
+		if _, ok := expected.(*hdr.RGB64); !ok {
+			t.Fatalf("%s: got %T, want *hdr.RGB64", name, expected)
+		}
+		actual := blur(e, planar, 5)
+		if _, ok := actual.(*hdr.Planar); !ok {
+			t.Fatalf("%s: got %T, want *hdr.Planar", name, actual)
+		}
+
+		// The filters run on float32 planes in both cases
+		for y := r.Min.Y; y < r.Max.Y; y++ {
+			for x := r.Min.X; x < r.Max.X; x++ {
+				r1, g1, b1, _ := expected.HDRAt(x, y).HDRRGBA()
+				r2, g2, b2, _ := actual.HDRAt(x, y).HDRRGBA()
+				if math.Abs(r1-r2) > 1e-6 || math.Abs(g1-g2) > 1e-6 || math.Abs(b1-b2) > 1e-6 {
+					t.Fatalf("%s: pixel (%d, %d): got %v, want %v", name, x, y, []float64{r2, g2, b2}, []float64{r1, g1, b1})
+				}
+			}
+		}
+
+		// The planar source is not modified
+		for y := r.Min.Y; y < r.Max.Y; y++ {
+			for x := r.Min.X; x < r.Max.X; x++ {
+				if a, e := planar.HDRAt(x, y), original.HDRAt(x, y); a != e {
+					t.Fatalf("%s: source pixel (%d, %d): got %v, want %v", name, x, y, a, e)
+				}
+			}
+		}
+	}
+}
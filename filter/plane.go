@@ -0,0 +1,89 @@
+package filter
+
+import (
+	"math"
+
+	"github.com/mdouchement/hdr/xmath"
+)
+
+// A Plane is a single channel float64 raster (e.g. a log-luminance) used by the
+// gradient domain and multi-scale filters.
+type Plane struct {
+	Width  int
+	Height int
+	// Pix holds the values in row-major order.
+	Pix []float64
+}
+
+// NewPlane returns a new Plane with the given size.
+func NewPlane(width, height int) *Plane {
+	return &Plane{
+		Width:  width,
+		Height: height,
+		Pix:    make([]float64, width*height),
+	}
+}
+
+// At returns the value at the given coordinates with clamped borders.
+func (p *Plane) At(x, y int) float64 {
+	x = xmath.Clamp(0, p.Width-1, x)
+	y = xmath.Clamp(0, p.Height-1, y)
+	return p.Pix[y*p.Width+x]
+}
+
+// Set sets the value at the given coordinates.
+func (p *Plane) Set(x, y int, v float64) {
+	p.Pix[y*p.Width+x] = v
+}
+
+// Copy returns a copy of the plane.
+func (p *Plane) Copy() *Plane {
+	dst := NewPlane(p.Width, p.Height)
+	copy(dst.Pix, p.Pix)
+	return dst
+}
+
+// Gradients returns the forward difference gradients of the plane.
+// The last column of gx and the last row of gy are zero (Neumann boundary conditions),
+// so the result can be fed back to PoissonReconstruct.
+func (p *Plane) Gradients() (gx, gy *Plane) {
+	gx = NewPlane(p.Width, p.Height)
+	gy = NewPlane(p.Width, p.Height)
+	for y := 0; y < p.Height; y++ {
+		for x := 0; x < p.Width; x++ {
+			i := y*p.Width + x
+			if x < p.Width-1 {
+				gx.Pix[i] = p.Pix[i+1] - p.Pix[i]
+			}
+			if y < p.Height-1 {
+				gy.Pix[i] = p.Pix[i+p.Width] - p.Pix[i]
+			}
+		}
+	}
+	return
+}
+
+// Resize bilinearly resamples the plane to the given size.
+func (p *Plane) Resize(width, height int) *Plane {
+	dst := NewPlane(width, height)
+	sx := float64(p.Width) / float64(width)
+	sy := float64(p.Height) / float64(height)
+
+	for y := 0; y < height; y++ {
+		fy := math.Max((float64(y)+0.5)*sy-0.5, 0)
+		y0 := int(fy)
+		ay := fy - float64(y0)
+
+		for x := 0; x < width; x++ {
+			fx := math.Max((float64(x)+0.5)*sx-0.5, 0)
+			x0 := int(fx)
+			ax := fx - float64(x0)
+
+			v := (1-ay)*((1-ax)*p.At(x0, y0)+ax*p.At(x0+1, y0)) +
+				ay*((1-ax)*p.At(x0, y0+1)+ax*p.At(x0+1, y0+1))
+			dst.Set(x, y, v)
+		}
+	}
+
+	return dst
+}
@@ -0,0 +1,199 @@
+package filter
+
+import (
+	"math"
+	"math/cmplx"
+
+	"github.com/mdouchement/hdr/parallel"
+	"gonum.org/v1/gonum/dsp/fourier"
+)
+
+// SolvePoisson solves the Poisson equation ∇²u = f on a width×height grid
+// (row-major order) with homogeneous Neumann boundary conditions.
+// It uses a DCT-based solver so the result is exact up to a constant; the returned u has a zero mean.
+//
+// The Laplacian is the 5-point stencil u(x-1,y) + u(x+1,y) + u(x,y-1) + u(x,y+1) - 4u(x,y)
+// where the outside pixels are the mirrored border pixels.
+// It can be used to reconstruct an image from a modified gradient field (gradient domain
+// tone mapping, seamless cloning, etc.) by providing f = Divergence(gx, gy).
+// It returns an ArgumentError when the grid is empty or f does not have width×height values.
+func SolvePoisson(f []float64, width, height int) ([]float64, error) {
+	return SolvePoissonWithExecutor(nil, f, width, height)
+}
+
+// SolvePoissonWithExecutor is like SolvePoisson but runs on the given executor.
+// When the executor context is done, the returned values are incomplete.
+func SolvePoissonWithExecutor(e *parallel.Executor, f []float64, width, height int) ([]float64, error) {
+	if width <= 0 || height <= 0 || len(f) != width*height {
+		return nil, ArgumentError("Poisson grid size")
+	}
+
+	u := make([]float64, len(f))
+	copy(u, f)
+
+	dctRows(e, u, width, height, false)
+	dctCols(e, u, width, height, false)
+
+	for y := 0; y < height; y++ {
+		ly := 2*math.Cos(math.Pi*float64(y)/float64(height)) - 2
+		for x := 0; x < width; x++ {
+			lx := 2*math.Cos(math.Pi*float64(x)/float64(width)) - 2
+
+			i := y*width + x
+			if x == 0 && y == 0 {
+				u[i] = 0 // The solution is defined up to a constant
+				continue
+			}
+			u[i] /= lx + ly
+		}
+	}
+
+	dctCols(e, u, width, height, true)
+	dctRows(e, u, width, height, true)
+
+	return u, nil
+}
+
+// Divergence computes the divergence of the gradient field (gx, gy) with backward differences.
+// It is the counterpart of forward difference gradients where the last column of gx
+// and the last row of gy are ignored (Neumann boundary conditions).
+func Divergence(gx, gy []float64, width, height int) []float64 {
+	div := make([]float64, width*height)
+
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			i := y*width + x
+
+			if x < width-1 {
+				div[i] += gx[i]
+			}
+			if x > 0 {
+				div[i] -= gx[i-1]
+			}
+			if y < height-1 {
+				div[i] += gy[i]
+			}
+			if y > 0 {
+				div[i] -= gy[i-width]
+			}
+		}
+	}
+
+	return div
+}
+
+// PoissonReconstruct returns the image whose forward difference gradients are the closest
+// (in least squares) to the given gradient field (gx, gy).
+// It returns an ArgumentError when the grid is empty or the gradients do not have width×height values.
+func PoissonReconstruct(gx, gy []float64, width, height int) ([]float64, error) {
+	return PoissonReconstructWithExecutor(nil, gx, gy, width, height)
+}
+
+// PoissonReconstructWithExecutor is like PoissonReconstruct but runs on the given executor.
+func PoissonReconstructWithExecutor(e *parallel.Executor, gx, gy []float64, width, height int) ([]float64, error) {
+	if width <= 0 || height <= 0 || len(gx) != width*height || len(gy) != width*height {
+		return nil, ArgumentError("Poisson grid size")
+	}
+	return SolvePoissonWithExecutor(e, Divergence(gx, gy, width, height), width, height)
+}
+
+//--------------------------------------//
+// DCT                                  //
+//--------------------------------------//
+
+// dctRows applies the DCT-II (or its inverse) on each row of the grid.
+func dctRows(e *parallel.Executor, grid []float64, width, height int, inverse bool) {
+	completed := e.Lines(height, func(y1, y2 int) {
+		d := newDCT(width)
+		for y := y1; y < y2; y++ {
+			d.transform(grid[y*width:(y+1)*width], inverse)
+		}
+	})
+	<-completed
+}
+
+// dctCols applies the DCT-II (or its inverse) on each column of the grid.
+func dctCols(e *parallel.Executor, grid []float64, width, height int, inverse bool) {
+	completed := e.Lines(width, func(x1, x2 int) {
+		d := newDCT(height)
+		col := make([]float64, height)
+		for x := x1; x < x2; x++ {
+			for y := 0; y < height; y++ {
+				col[y] = grid[y*width+x]
+			}
+			d.transform(col, inverse)
+			for y := 0; y < height; y++ {
+				grid[y*width+x] = col[y]
+			}
+		}
+	})
+	<-completed
+}
+
+// dct computes an unnormalized DCT-II and its inverse using a complex FFT (Makhoul's algorithm).
+type dct struct {
+	n       int
+	fft     *fourier.CmplxFFT
+	v       []complex128
+	twiddle []complex128
+}
+
+func newDCT(n int) *dct {
+	d := &dct{
+		n:       n,
+		fft:     fourier.NewCmplxFFT(n),
+		v:       make([]complex128, n),
+		twiddle: make([]complex128, n),
+	}
+	for k := range d.twiddle {
+		d.twiddle[k] = cmplx.Exp(complex(0, -math.Pi*float64(k)/float64(2*n)))
+	}
+	return d
+}
+
+func (d *dct) transform(seq []float64, inverse bool) {
+	if inverse {
+		d.backward(seq)
+		return
+	}
+	d.forward(seq)
+}
+
+// forward computes X[k] = Σ x[i] cos(πk(2i+1)/2n).
+func (d *dct) forward(seq []float64) {
+	n := d.n
+	for i := 0; i < (n+1)/2; i++ {
+		d.v[i] = complex(seq[2*i], 0)
+	}
+	for i := 0; i < n/2; i++ {
+		d.v[n-1-i] = complex(seq[2*i+1], 0)
+	}
+
+	d.fft.Coefficients(d.v, d.v)
+
+	for k := 0; k < n; k++ {
+		seq[k] = real(d.twiddle[k] * d.v[k])
+	}
+}
+
+// backward is the inverse of forward.
+func (d *dct) backward(seq []float64) {
+	n := d.n
+	for k := 0; k < n; k++ {
+		var xnk float64
+		if k > 0 {
+			xnk = seq[n-k]
+		}
+		d.v[k] = complex(seq[k], -xnk) / d.twiddle[k]
+	}
+
+	d.fft.Sequence(d.v, d.v)
+
+	scale := 1 / float64(n)
+	for i := 0; i < (n+1)/2; i++ {
+		seq[2*i] = real(d.v[i]) * scale
+	}
+	for i := 0; i < n/2; i++ {
+		seq[2*i+1] = real(d.v[n-1-i]) * scale
+	}
+}
@@ -0,0 +1,91 @@
+package filter
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/mdouchement/hdr/parallel"
+)
+
+// poissonEpsilon is the tolerance of the round trips through the DCT solver.
+const poissonEpsilon = 1e-9
+
+// randomPlane returns a plane of random values with a zero mean.
+func randomPlane(rnd *rand.Rand, width, height int) *Plane {
+	p := NewPlane(width, height)
+
+	var mean float64
+	for i := range p.Pix {
+		p.Pix[i] = rnd.Float64()*10 - 5
+		mean += p.Pix[i]
+	}
+	mean /= float64(len(p.Pix))
+
+	for i := range p.Pix {
+		p.Pix[i] -= mean
+	}
+	return p
+}
+
+func TestPoissonReconstruct(t *testing.T) {
+	rnd := rand.New(rand.NewSource(29))
+
+	for _, size := range [][2]int{{1, 1}, {1, 7}, {9, 1}, {2, 2}, {16, 16}, {31, 17}, {64, 33}} {
+		width, height := size[0], size[1]
+		expected := randomPlane(rnd, width, height)
+
+		gx, gy := expected.Gradients()
+		actual, err := PoissonReconstruct(gx.Pix, gy.Pix, width, height)
+		if err != nil {
+			t.Fatalf("%dx%d: %v", width, height, err)
+		}
+
+		for i, v := range expected.Pix {
+			if math.Abs(actual[i]-v) > poissonEpsilon {
+				t.Fatalf("%dx%d: pixel %d: got %v, want %v", width, height, i, actual[i], v)
+			}
+		}
+	}
+}
+
+func TestSolvePoisson(t *testing.T) {
+	rnd := rand.New(rand.NewSource(29))
+	width, height := 37, 23
+	expected := randomPlane(rnd, width, height)
+
+	// 5-point Laplacian with mirrored (Neumann) borders
+	f := make([]float64, width*height)
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			u := expected.At(x, y)
+			f[y*width+x] = expected.At(x-1, y) + expected.At(x+1, y) + expected.At(x, y-1) + expected.At(x, y+1) - 4*u
+		}
+	}
+
+	for _, workers := range []int{1, 4} {
+		e := &parallel.Executor{Workers: workers}
+		actual, err := SolvePoissonWithExecutor(e, f, width, height)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for i, v := range expected.Pix {
+			if math.Abs(actual[i]-v) > poissonEpsilon {
+				t.Fatalf("%d workers: pixel %d: got %v, want %v", workers, i, actual[i], v)
+			}
+		}
+	}
+}
+
+func TestPoissonArguments(t *testing.T) {
+	if _, err := SolvePoisson(nil, 0, 0); err == nil {
+		t.Error("empty grid: got no error")
+	}
+	if _, err := SolvePoisson(make([]float64, 5), 2, 3); err == nil {
+		t.Error("short grid: got no error")
+	}
+	if _, err := PoissonReconstruct(make([]float64, 6), make([]float64, 5), 2, 3); err == nil {
+		t.Error("short gradient: got no error")
+	}
+}
@@ -11,53 +11,37 @@ import (
 )
 
 // A Pow10 applies a pow10 for all pixels of the image.
+// The pow10 is applied on the channels of its working color space.
 type Pow10 struct {
 	HDRImage hdr.Image
-	hdrat    func(x, y int) hdrcolor.Color
+	space    hdr.ColorSpace
 }
 
-// NewPow10 instanciates a new Pow10 filter.
+// NewPow10 instanciates a new Pow10 filter working in the color space of the image
+// (hdr.RAWSpace for the color models other than RGB and XYZ).
+// It panics if m is nil, NewPow10WithSpace returns an error instead.
 func NewPow10(m hdr.Image) *Pow10 {
-	f := &Pow10{
+	return &Pow10{
 		HDRImage: m,
+		space:    hdr.ModelSpace(m.ColorModel()),
 	}
+}
 
-	pow10 := func(x float64) float64 {
-		if x > 12 {
-			x = 0
-		}
-		return math.Pow(10, x)
-	}
-
-	switch m.ColorModel() {
-	case hdrcolor.XYZModel:
-		f.hdrat = func(x, y int) hdrcolor.Color {
-			X, Y, Z, _ := f.HDRImage.HDRAt(x, y).HDRXYZA()
-			return hdrcolor.XYZ{
-				X: pow10(X),
-				Y: pow10(Y),
-				Z: pow10(Z),
-			}
-		}
-	case hdrcolor.RGBModel:
-		fallthrough
-	default:
-		f.hdrat = func(x, y int) hdrcolor.Color {
-			r, g, b, _ := f.HDRImage.HDRAt(x, y).HDRRGBA()
-			return hdrcolor.RGB{
-				R: pow10(r),
-				G: pow10(g),
-				B: pow10(b),
-			}
-		}
+// NewPow10WithSpace instanciates a new Pow10 filter working on the channels of the given color space.
+func NewPow10WithSpace(m hdr.Image, space hdr.ColorSpace) (*Pow10, error) {
+	if err := checkSpace(space, m); err != nil {
+		return nil, err
 	}
 
-	return f
+	return &Pow10{
+		HDRImage: m,
+		space:    space,
+	}, nil
 }
 
-// ColorModel returns the Image's color model.
+// ColorModel returns the color model of the working color space.
 func (f *Pow10) ColorModel() color.Model {
-	return f.HDRImage.ColorModel()
+	return f.space.Model()
 }
 
 // Bounds implements image.Image interface.
@@ -72,7 +56,18 @@ func (f *Pow10) Size() int {
 
 // HDRAt computes the pow10(x) and returns the filtered color at the given coordinates.
 func (f *Pow10) HDRAt(x, y int) hdrcolor.Color {
-	return f.hdrat(x, y)
+	p1, p2, p3 := f.space.Channels(f.HDRImage.HDRAt(x, y))
+	return f.space.Color(pow10(p1), pow10(p2), pow10(p3))
+}
+
+// ReadRow implements hdr.RowReader.
+func (f *Pow10) ReadRow(dst []float64, x1, x2, y int, space hdr.ColorSpace) {
+	row := dst[:3*(x2-x1)]
+	hdr.ReadRow(f.HDRImage, row, x1, x2, y, f.space)
+	for i, v := range row {
+		row[i] = pow10(v)
+	}
+	hdr.ConvertRow(row, f.space, space)
 }
 
 // At computes the pow10(x) and returns the filtered color at the given coordinates.
@@ -85,3 +80,10 @@ func (f *Pow10) At(x, y int) color.Color {
 		A: 255,
 	}
 }
+
+func pow10(x float64) float64 {
+	if x > 12 {
+		x = 0
+	}
+	return math.Pow(10, x)
+}
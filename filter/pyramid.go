@@ -0,0 +1,167 @@
+package filter
+
+import "github.com/mdouchement/hdr/parallel"
+
+// Burt and Adelson's 5-tap binomial kernel.
+var pyramidKernel = [5]float64{1. / 16, 4. / 16, 6. / 16, 4. / 16, 1. / 16}
+
+// Reduce blurs the plane with a 5-tap binomial kernel and downsamples it by a factor of 2.
+// The size of the returned plane is rounded up so an odd sized plane keeps its last row and column.
+func (p *Plane) Reduce() *Plane {
+	return p.reduce(nil)
+}
+
+func (p *Plane) reduce(e *parallel.Executor) *Plane {
+	width, height := (p.Width+1)/2, (p.Height+1)/2
+
+	tmp := NewPlane(width, p.Height)
+	completed := e.Lines(tmp.Height, func(y1, y2 int) {
+		for y := y1; y < y2; y++ {
+			for x := 0; x < tmp.Width; x++ {
+				var v float64
+				for i, w := range pyramidKernel {
+					v += w * p.At(2*x+i-2, y)
+				}
+				tmp.Set(x, y, v)
+			}
+		}
+	})
+	<-completed
+
+	dst := NewPlane(width, height)
+	completed = e.Lines(dst.Height, func(y1, y2 int) {
+		for y := y1; y < y2; y++ {
+			for x := 0; x < dst.Width; x++ {
+				var v float64
+				for i, w := range pyramidKernel {
+					v += w * tmp.At(x, 2*y+i-2)
+				}
+				dst.Set(x, y, v)
+			}
+		}
+	})
+	<-completed
+
+	return dst
+}
+
+// Expand upsamples the plane by a factor of 2 to the given size and interpolates it
+// with a 5-tap binomial kernel. It is the counterpart of Reduce.
+func (p *Plane) Expand(width, height int) *Plane {
+	return p.expand(nil, width, height)
+}
+
+func (p *Plane) expand(e *parallel.Executor, width, height int) *Plane {
+	tmp := NewPlane(width, p.Height)
+	completed := e.Lines(tmp.Height, func(y1, y2 int) {
+		for y := y1; y < y2; y++ {
+			for x := 0; x < tmp.Width; x++ {
+				var v float64
+				for i, w := range pyramidKernel {
+					if sx := x + i - 2; sx%2 == 0 {
+						v += 2 * w * p.At(sx/2, y)
+					}
+				}
+				tmp.Set(x, y, v)
+			}
+		}
+	})
+	<-completed
+
+	dst := NewPlane(width, height)
+	completed = e.Lines(dst.Height, func(y1, y2 int) {
+		for y := y1; y < y2; y++ {
+			for x := 0; x < dst.Width; x++ {
+				var v float64
+				for i, w := range pyramidKernel {
+					if sy := y + i - 2; sy%2 == 0 {
+						v += 2 * w * tmp.At(x, sy/2)
+					}
+				}
+				dst.Set(x, y, v)
+			}
+		}
+	})
+	<-completed
+
+	return dst
+}
+
+// PyramidLevels returns the number of levels of a pyramid whose coarsest level
+// is not smaller than minSize pixels (at least one level).
+func PyramidLevels(width, height, minSize int) int {
+	levels := 1
+	for width/2 >= minSize && height/2 >= minSize {
+		width, height = (width+1)/2, (height+1)/2
+		levels++
+	}
+	return levels
+}
+
+// GaussianPyramid returns the Gaussian pyramid of the plane, from the finest level
+// (the plane itself) to the coarsest one.
+func GaussianPyramid(p *Plane, levels int) []*Plane {
+	return GaussianPyramidWithExecutor(nil, p, levels)
+}
+
+// GaussianPyramidWithExecutor is like GaussianPyramid but runs on the given executor.
+func GaussianPyramidWithExecutor(e *parallel.Executor, p *Plane, levels int) []*Plane {
+	pyramid := make([]*Plane, levels)
+	pyramid[0] = p
+	for k := 1; k < levels; k++ {
+		pyramid[k] = pyramid[k-1].reduce(e)
+	}
+	return pyramid
+}
+
+// LaplacianPyramid returns the Laplacian pyramid of the plane.
+// The last level is the low-pass residual (the coarsest Gaussian level).
+func LaplacianPyramid(p *Plane, levels int) []*Plane {
+	return LaplacianPyramidWithExecutor(nil, p, levels)
+}
+
+// LaplacianPyramidWithExecutor is like LaplacianPyramid but runs on the given executor.
+func LaplacianPyramidWithExecutor(e *parallel.Executor, p *Plane, levels int) []*Plane {
+	return LaplacianFromGaussianWithExecutor(e, GaussianPyramidWithExecutor(e, p, levels))
+}
+
+// LaplacianFromGaussian returns the Laplacian pyramid of the given Gaussian pyramid.
+func LaplacianFromGaussian(gaussian []*Plane) []*Plane {
+	return LaplacianFromGaussianWithExecutor(nil, gaussian)
+}
+
+// LaplacianFromGaussianWithExecutor is like LaplacianFromGaussian but runs on the given executor.
+func LaplacianFromGaussianWithExecutor(e *parallel.Executor, gaussian []*Plane) []*Plane {
+	n := len(gaussian)
+	pyramid := make([]*Plane, n)
+	pyramid[n-1] = gaussian[n-1].Copy()
+
+	for k := 0; k < n-1; k++ {
+		g := gaussian[k]
+		expanded := gaussian[k+1].expand(e, g.Width, g.Height)
+		for i := range expanded.Pix {
+			expanded.Pix[i] = g.Pix[i] - expanded.Pix[i]
+		}
+		pyramid[k] = expanded
+	}
+
+	return pyramid
+}
+
+// CollapseLaplacian reconstructs the plane from its Laplacian pyramid.
+func CollapseLaplacian(pyramid []*Plane) *Plane {
+	return CollapseLaplacianWithExecutor(nil, pyramid)
+}
+
+// CollapseLaplacianWithExecutor is like CollapseLaplacian but runs on the given executor.
+func CollapseLaplacianWithExecutor(e *parallel.Executor, pyramid []*Plane) *Plane {
+	p := pyramid[len(pyramid)-1]
+	for k := len(pyramid) - 2; k >= 0; k-- {
+		l := pyramid[k]
+		p = p.expand(e, l.Width, l.Height)
+		for i, v := range l.Pix {
+			p.Pix[i] += v
+		}
+	}
+	return p
+}
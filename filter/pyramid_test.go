@@ -0,0 +1,63 @@
+package filter
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/mdouchement/hdr/parallel"
+)
+
+func TestLaplacianPyramidCollapse(t *testing.T) {
+	rnd := rand.New(rand.NewSource(31))
+
+	for _, size := range [][2]int{{1, 1}, {2, 3}, {17, 9}, {64, 64}, {101, 67}} {
+		width, height := size[0], size[1]
+		p := randomPlane(rnd, width, height)
+		levels := PyramidLevels(width, height, 4)
+
+		for _, workers := range []int{1, 3} {
+			e := &parallel.Executor{Workers: workers}
+			actual := CollapseLaplacianWithExecutor(e, LaplacianPyramidWithExecutor(e, p, levels))
+
+			if actual.Width != width || actual.Height != height {
+				t.Fatalf("%dx%d: got %dx%d", width, height, actual.Width, actual.Height)
+			}
+			for i, v := range p.Pix {
+				if math.Abs(actual.Pix[i]-v) > 1e-12 {
+					t.Fatalf("%dx%d, %d workers: pixel %d: got %v, want %v", width, height, workers, i, actual.Pix[i], v)
+				}
+			}
+		}
+	}
+}
+
+func TestGaussianPyramidSizes(t *testing.T) {
+	p := NewPlane(101, 67)
+	levels := PyramidLevels(p.Width, p.Height, 8)
+	if levels != 4 {
+		t.Fatalf("got %d levels, want 4", levels)
+	}
+
+	expected := [][2]int{{101, 67}, {51, 34}, {26, 17}, {13, 9}}
+	for k, g := range GaussianPyramid(p, levels) {
+		if g.Width != expected[k][0] || g.Height != expected[k][1] {
+			t.Errorf("level %d: got %dx%d, want %dx%d", k, g.Width, g.Height, expected[k][0], expected[k][1])
+		}
+	}
+}
+
+func TestPyramidWorkers(t *testing.T) {
+	p := randomPlane(rand.New(rand.NewSource(31)), 75, 41)
+	levels := PyramidLevels(p.Width, p.Height, 4)
+
+	expected := LaplacianPyramid(p, levels)
+	actual := LaplacianPyramidWithExecutor(&parallel.Executor{Workers: 4}, p, levels)
+	for k := range expected {
+		for i, v := range expected[k].Pix {
+			if actual[k].Pix[i] != v {
+				t.Fatalf("level %d: pixel %d: got %v, want %v", k, i, actual[k].Pix[i], v)
+			}
+		}
+	}
+}
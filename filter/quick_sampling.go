@@ -9,29 +9,53 @@ import (
 )
 
 // A QuickSampling allows to iterate on pixels over a reduced image size.
+// The colors are returned in its working color space.
 type QuickSampling struct {
 	HDRImage hdr.Image
 	sampling float32
 	rect     image.Rectangle
+	space    hdr.ColorSpace
 }
 
-// NewQuickSampling instanciates a new QuickSampling for the given sampling included in [0, 1].
+// NewQuickSampling instanciates a new QuickSampling for the given sampling included in [0, 1],
+// working in the color space of the image (hdr.RAWSpace for the color models other than RGB and XYZ).
+//
+// Deprecated: it panics when the arguments are invalid, use NewQuickSamplingWithSpace.
 func NewQuickSampling(img hdr.Image, sampling float32) *QuickSampling {
-	if sampling < 0 || sampling > 1 {
-		panic("Invalid sampling value. It must be include in [0, 1]")
+	if img == nil {
+		panic(ArgumentError("nil image"))
 	}
 
-	y := float32(img.Bounds().Max.Y)
+	f, err := NewQuickSamplingWithSpace(img, hdr.ModelSpace(img.ColorModel()), sampling)
+	if err != nil {
+		panic(err)
+	}
+	return f
+}
+
+// NewQuickSamplingWithSpace instanciates a new QuickSampling working in the given color space
+// for the given sampling included in [0, 1].
+// It returns an ArgumentError when the image is nil or the sampling is out of range.
+func NewQuickSamplingWithSpace(img hdr.Image, space hdr.ColorSpace, sampling float32) (*QuickSampling, error) {
+	if err := checkSpace(space, img); err != nil {
+		return nil, err
+	}
+	if !(sampling >= 0 && sampling <= 1) { // Also rejects NaN
+		return nil, ArgumentError("sampling must be included in [0, 1]")
+	}
+
+	d := img.Bounds()
 	return &QuickSampling{
 		HDRImage: img,
 		sampling: sampling,
-		rect:     image.Rect(img.Bounds().Min.X, img.Bounds().Min.Y, img.Bounds().Max.X, int(y*sampling)),
-	}
+		rect:     image.Rect(d.Min.X, d.Min.Y, d.Max.X, d.Min.Y+int(float32(d.Dy())*sampling)),
+		space:    space,
+	}, nil
 }
 
-// ColorModel delegates to HDRImage function.
+// ColorModel returns the color model of the working color space.
 func (f *QuickSampling) ColorModel() color.Model {
-	return f.HDRImage.ColorModel()
+	return f.space.Model()
 }
 
 // Bounds implements Image with quick sampling HDRImage.
@@ -53,9 +77,16 @@ func (f *QuickSampling) At(x, y int) color.Color {
 // HDRAt implements Image with quick sampling on HDRImage.
 func (f *QuickSampling) HDRAt(x, y int) hdrcolor.Color {
 	rx, ry := f.realAt(x, y)
-	return f.HDRImage.HDRAt(rx, ry)
+	return f.space.Color(f.space.Channels(f.HDRImage.HDRAt(rx, ry)))
+}
+
+// ReadRow implements hdr.RowReader with quick sampling on HDRImage.
+func (f *QuickSampling) ReadRow(dst []float64, x1, x2, y int, space hdr.ColorSpace) {
+	rx, ry := f.realAt(x1, y)
+	hdr.ReadRow(f.HDRImage, dst, rx, rx+x2-x1, ry, f.space)
+	hdr.ConvertRow(dst[:3*(x2-x1)], f.space, space)
 }
 
 func (f *QuickSampling) realAt(x, y int) (int, int) {
-	return x, int(float32(y) * f.sampling)
+	return x, f.rect.Min.Y + int(float32(y-f.rect.Min.Y)*f.sampling)
 }
@@ -0,0 +1,112 @@
+package filter
+
+import (
+	"image"
+	"math"
+
+	"github.com/mdouchement/hdr"
+	"github.com/mdouchement/hdr/hdrcolor"
+	"github.com/mdouchement/hdr/parallel"
+	"github.com/mdouchement/hdr/xmath"
+)
+
+// Resample returns the region r of m resampled at the given scale (output pixels per source pixel).
+// The returned image has a zero origin.
+// Downscaling averages the covered source pixels (box filter) and upscaling interpolates them (bilinear).
+func Resample(m hdr.Image, r image.Rectangle, scale float64) *hdr.RGB64 {
+	return ResampleWithExecutor(nil, m, r, scale)
+}
+
+// ResampleWithExecutor is like Resample but runs on the given executor.
+// When the executor context is done, the remaining pixels are zero.
+func ResampleWithExecutor(e *parallel.Executor, m hdr.Image, r image.Rectangle, scale float64) *hdr.RGB64 {
+	r = r.Intersect(m.Bounds())
+	width := int(math.Max(1, math.Ceil(float64(r.Dx())*scale)))
+	height := int(math.Max(1, math.Ceil(float64(r.Dy())*scale)))
+	dst := hdr.NewRGB64(image.Rect(0, 0, width, height))
+
+	if r.Empty() {
+		return dst
+	}
+
+	sample := bilinearSample(m, r, scale)
+	if scale < 1 {
+		sample = boxSample(m, r, scale)
+	}
+
+	completed := e.TilesR(dst.Bounds(), func(x1, y1, x2, y2 int) {
+		for y := y1; y < y2; y++ {
+			for x := x1; x < x2; x++ {
+				dst.SetRGB(x, y, sample(x, y))
+			}
+		}
+	})
+	<-completed
+
+	return dst
+}
+
+// Proxy returns m downscaled so its largest side is at most size pixels, and the used scale.
+func Proxy(m hdr.Image, size int) (*hdr.RGB64, float64) {
+	return ProxyWithExecutor(nil, m, size)
+}
+
+// ProxyWithExecutor is like Proxy but runs on the given executor.
+func ProxyWithExecutor(e *parallel.Executor, m hdr.Image, size int) (*hdr.RGB64, float64) {
+	d := m.Bounds()
+	scale := math.Min(1, float64(size)/math.Max(float64(d.Dx()), float64(d.Dy())))
+
+	return ResampleWithExecutor(e, m, d, scale), scale
+}
+
+// boxSample averages the source pixels covered by the destination pixel.
+func boxSample(m hdr.Image, r image.Rectangle, scale float64) func(x, y int) hdrcolor.RGB {
+	return func(x, y int) hdrcolor.RGB {
+		sx1 := r.Min.X + int(float64(x)/scale)
+		sy1 := r.Min.Y + int(float64(y)/scale)
+		sx2 := xmath.Clamp(sx1+1, r.Max.X, r.Min.X+int(math.Ceil(float64(x+1)/scale)))
+		sy2 := xmath.Clamp(sy1+1, r.Max.Y, r.Min.Y+int(math.Ceil(float64(y+1)/scale)))
+
+		var c hdrcolor.RGB
+		for sy := sy1; sy < sy2; sy++ {
+			for sx := sx1; sx < sx2; sx++ {
+				cr, cg, cb, _ := m.HDRAt(sx, sy).HDRRGBA()
+				c.R += cr
+				c.G += cg
+				c.B += cb
+			}
+		}
+
+		n := float64((sx2 - sx1) * (sy2 - sy1))
+		return hdrcolor.RGB{R: c.R / n, G: c.G / n, B: c.B / n}
+	}
+}
+
+// bilinearSample interpolates the source pixels surrounding the destination pixel center.
+func bilinearSample(m hdr.Image, r image.Rectangle, scale float64) func(x, y int) hdrcolor.RGB {
+	return func(x, y int) hdrcolor.RGB {
+		fx := xmath.ClampF64(0, float64(r.Dx()-1), (float64(x)+0.5)/scale-0.5)
+		fy := xmath.ClampF64(0, float64(r.Dy()-1), (float64(y)+0.5)/scale-0.5)
+		x0, y0 := int(fx), int(fy)
+		x1, y1 := xmath.Clamp(0, r.Dx()-1, x0+1), xmath.Clamp(0, r.Dy()-1, y0+1)
+		ax, ay := fx-float64(x0), fy-float64(y0)
+
+		at := func(x, y int) (float64, float64, float64) {
+			cr, cg, cb, _ := m.HDRAt(r.Min.X+x, r.Min.Y+y).HDRRGBA()
+			return cr, cg, cb
+		}
+		r00, g00, b00 := at(x0, y0)
+		r10, g10, b10 := at(x1, y0)
+		r01, g01, b01 := at(x0, y1)
+		r11, g11, b11 := at(x1, y1)
+
+		lerp := func(v00, v10, v01, v11 float64) float64 {
+			return (v00*(1-ax)+v10*ax)*(1-ay) + (v01*(1-ax)+v11*ax)*ay
+		}
+		return hdrcolor.RGB{
+			R: lerp(r00, r10, r01, r11),
+			G: lerp(g00, g10, g01, g11),
+			B: lerp(b00, b10, b01, b11),
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package filter
+
+import (
+	"image"
+	"math/rand"
+	"testing"
+
+	"github.com/mdouchement/hdr"
+	"github.com/mdouchement/hdr/hdrcolor"
+)
+
+// filters returns each filter (and its color space variants) of src.
+func filters(t *testing.T, src hdr.Image) map[string]hdr.Image {
+	identity := func(c1, _ hdrcolor.Color) hdrcolor.Color { return c1 }
+	mean := func(c1, c2 hdrcolor.Color) hdrcolor.Color {
+		r1, g1, b1, _ := c1.HDRRGBA()
+		r2, g2, b2, _ := c2.HDRRGBA()
+		return hdrcolor.RGB{R: (r1 + r2) / 2, G: (g1 + g2) / 2, B: (b1 + b2) / 2}
+	}
+
+	fb := NewFastBilateralAuto(src)
+	fb.Perform()
+	yfb := NewYFastBilateralAuto(src)
+	yfb.Perform()
+
+	m := map[string]hdr.Image{
+		"Log10":          NewLog10(src),
+		"Pow10":          NewPow10(NewLog10(src)),
+		"Apply1":         NewApply1(src, identity),
+		"Apply2":         NewApply2(src, NewLog10(src), mean),
+		"QuickSampling":  NewQuickSampling(src, 0.6),
+		"FastBilateral":  fb,
+		"YFastBilateral": yfb,
+		"Cache":          NewCache(NewLog10(src), 8, 4),
+		"Materialize":    Materialize(NewPow10(src)),
+	}
+
+	for _, space := range []hdr.ColorSpace{hdr.XYZSpace, hdr.RAWSpace} {
+		log10, err := NewLog10WithSpace(src, space)
+		if err != nil {
+			t.Fatal(err)
+		}
+		apply, err := NewApply1WithSpace(src, space, identity)
+		if err != nil {
+			t.Fatal(err)
+		}
+		qs, err := NewQuickSamplingWithSpace(src, space, 0.6)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fb, err := NewFastBilateralWithSpace(src, space, 16, 0.1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fb.Perform()
+
+		suffix := map[hdr.ColorSpace]string{hdr.XYZSpace: "XYZ", hdr.RAWSpace: "RAW"}[space]
+		m["Log10"+suffix] = log10
+		m["Apply1"+suffix] = apply
+		m["QuickSampling"+suffix] = qs
+		m["FastBilateral"+suffix] = fb
+	}
+
+	yfb, err := NewYFastBilateralWithSpace(src, hdr.XYZSpace, 16, 0.1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	yfb.Perform()
+	m["YFastBilateralXYZ"] = yfb
+
+	return m
+}
+
+func TestReadRow(t *testing.T) {
+	r := image.Rect(3, -2, 45, 31)
+	rnd := rand.New(rand.NewSource(45))
+
+	sources := map[string]hdr.ImageSet{"RGB64": hdr.NewRGB64(r), "XYZ": hdr.NewXYZ(r), "Planar": hdr.NewPlanarAligned(r, hdr.RGBSpace, 4)}
+	for _, src := range sources {
+		for y := r.Min.Y; y < r.Max.Y; y++ {
+			for x := r.Min.X; x < r.Max.X; x++ {
+				src.Set(x, y, hdrcolor.RGB{R: 4 * rnd.Float64(), G: rnd.Float64() + 0.01, B: 2 * rnd.Float64()})
+			}
+		}
+	}
+
+	x1, x2 := r.Min.X-2, r.Max.X+3
+	row := make([]float64, 3*(x2-x1))
+
+	for sname, src := range sources {
+		for fname, m := range filters(t, src.(hdr.Image)) {
+			for _, space := range []hdr.ColorSpace{hdr.RGBSpace, hdr.XYZSpace, hdr.RAWSpace} {
+				for y := r.Min.Y - 1; y <= r.Max.Y; y++ {
+					hdr.ReadRow(m, row, x1, x2, y, space)
+
+					for x := x1; x < x2; x++ {
+						p1, p2, p3 := space.Channels(m.HDRAt(x, y))
+
+						i := 3 * (x - x1)
+						if row[i] != p1 || row[i+1] != p2 || row[i+2] != p3 {
+							t.Fatalf("%s of %s, space %d: pixel (%d, %d): got %v, want %v", fname, sname, space, x, y, row[i:i+3], []float64{p1, p2, p3})
+						}
+					}
+				}
+			}
+		}
+	}
+}
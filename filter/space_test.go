@@ -0,0 +1,81 @@
+package filter
+
+import (
+	"image"
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/mdouchement/hdr"
+	"github.com/mdouchement/hdr/hdrcolor"
+)
+
+func TestLMSLog10(t *testing.T) {
+	r := image.Rect(1, 2, 30, 20)
+	rnd := rand.New(rand.NewSource(48))
+
+	src := hdr.NewXYZ64(r)
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			src.Set(x, y, hdrcolor.RGB{R: 4*rnd.Float64() + 0.01, G: rnd.Float64() + 0.01, B: 2*rnd.Float64() + 0.01})
+		}
+	}
+
+	for name, lms := range map[string]hdr.Image{"LMSCAT02w": hdr.NewLMSCAT02w(src), "LMSHPEw": hdr.NewLMSHPEw(src)} {
+		log := NewLog10(lms)
+		if log.ColorModel() != hdrcolor.RAWModel {
+			t.Fatalf("%s: got a %v model, want the raw one", name, log.ColorModel())
+		}
+		back := NewPow10(log)
+
+		for y := r.Min.Y; y < r.Max.Y; y++ {
+			for x := r.Min.X; x < r.Max.X; x++ {
+				L, M, S, _ := lms.HDRAt(x, y).HDRPixel()
+
+				// The LMS channels are not converted to RGB nor XYZ
+				c, ok := log.HDRAt(x, y).(hdrcolor.RAW)
+				if !ok {
+					t.Fatalf("%s: got a %T, want a hdrcolor.RAW", name, log.HDRAt(x, y))
+				}
+				if c.P1 != log10(L) || c.P2 != log10(M) || c.P3 != log10(S) {
+					t.Fatalf("%s: pixel (%d, %d): got %v, want the log10 of %v", name, x, y, c, []float64{L, M, S})
+				}
+
+				p1, p2, p3, _ := back.HDRAt(x, y).HDRPixel()
+				if math.Abs(p1-L) > 1e-12*L || math.Abs(p2-M) > 1e-12*M || math.Abs(p3-S) > 1e-12*S {
+					t.Fatalf("%s: pixel (%d, %d): got %v after pow10, want %v", name, x, y, []float64{p1, p2, p3}, []float64{L, M, S})
+				}
+			}
+		}
+	}
+}
+
+func TestSpaceErrors(t *testing.T) {
+	m := hdr.NewRGB64(image.Rect(0, 0, 4, 4))
+	identity := func(c1, _ hdrcolor.Color) hdrcolor.Color { return c1 }
+	invalid := hdr.ColorSpace(42)
+
+	errs := func(_ interface{}, err error) error { return err }
+	tests := []struct {
+		name     string
+		err      error
+		expected error
+	}{
+		{"Log10 nil image", errs(NewLog10WithSpace(nil, hdr.RGBSpace)), ArgumentError("nil image")},
+		{"Log10 invalid space", errs(NewLog10WithSpace(m, invalid)), UnsupportedError("color space")},
+		{"Pow10 nil image", errs(NewPow10WithSpace(nil, hdr.XYZSpace)), ArgumentError("nil image")},
+		{"Pow10 invalid space", errs(NewPow10WithSpace(m, invalid)), UnsupportedError("color space")},
+		{"Apply1 nil image", errs(NewApply1WithSpace(nil, hdr.RAWSpace, identity)), ArgumentError("nil image")},
+		{"Apply1 nil function", errs(NewApply1WithSpace(m, hdr.RGBSpace, nil)), ArgumentError("nil apply function")},
+		{"Apply2 nil image", errs(NewApply2WithSpace(m, nil, hdr.RGBSpace, identity)), ArgumentError("nil image")},
+		{"Apply2 invalid space", errs(NewApply2WithSpace(m, m, invalid, identity)), UnsupportedError("color space")},
+		{"Log10 raw space", errs(NewLog10WithSpace(m, hdr.RAWSpace)), nil},
+		{"Apply2 XYZ space", errs(NewApply2WithSpace(m, m, hdr.XYZSpace, identity)), nil},
+	}
+
+	for _, test := range tests {
+		if test.err != test.expected {
+			t.Errorf("%s: got error %v, want %v", test.name, test.err, test.expected)
+		}
+	}
+}
@@ -2,252 +2,97 @@ package filter
 
 import (
 	"github.com/mdouchement/hdr"
-	"github.com/mdouchement/hdr/hdrcolor"
+	"github.com/mdouchement/hdr/parallel"
 )
 
 // HDR port of https://github.com/esimov/stackblur-go
 
 // StackBlur performs a fast almost Gaussian Blur implementation.
+// The channels are blurred on their own plane, the returned image has the type of src (see hdr.EmptyAs).
 func StackBlur(src hdr.Image, radius int) hdr.Image {
-	m := hdr.Copy(src)
-	ms := m.(hdr.ImageSet)
-
-	var stackEnd, stackIn, stackOut *blurStack
-	var width, height = m.Bounds().Dx(), m.Bounds().Dy()
-	var (
-		div, widthMinus1, heightMinus1, radiusPlus1, sumFactor, p int
-		rSum, gSum, bSum,
-		rOutSum, gOutSum, bOutSum,
-		rInSum, gInSum, bInSum,
-		pr, pg, pb float64
-	)
-
-	div = radius + radius + 1
-	widthMinus1 = width - 1
-	heightMinus1 = height - 1
-	radiusPlus1 = radius + 1
-	sumFactor = radiusPlus1 * (radiusPlus1 + 1) / 2
-
-	bs := blurStack{}
-	stackStart := bs.NewBlurStack()
-	stack := stackStart
-
-	for i := 1; i < div; i++ {
-		stack.next = bs.NewBlurStack()
-		stack = stack.next
-		if i == radiusPlus1 {
-			stackEnd = stack
-		}
-	}
-	stack.next = stackStart
-
-	divsum := float64((div + 1) >> 1)
-	divsum *= divsum
-
-	for y := 0; y < height; y++ {
-		rInSum, gInSum, bInSum, rSum, gSum, bSum = 0, 0, 0, 0, 0, 0
-
-		pr, pg, pb, _ = m.HDRAt(0, y).HDRRGBA()
-
-		rOutSum = float64(radiusPlus1) * pr
-		gOutSum = float64(radiusPlus1) * pg
-		bOutSum = float64(radiusPlus1) * pb
-
-		rSum += float64(sumFactor) * pr
-		gSum += float64(sumFactor) * pg
-		bSum += float64(sumFactor) * pb
-
-		stack = stackStart
-
-		for i := 0; i < radiusPlus1; i++ {
-			stack.r = pr
-			stack.g = pg
-			stack.b = pb
-			stack = stack.next
-		}
+	return StackBlurWithExecutor(nil, src, radius)
+}
 
-		for i := 1; i < radiusPlus1; i++ {
-			p = i
-			if widthMinus1 < i {
-				p = widthMinus1
+// StackBlurWithExecutor is like StackBlur but runs on the given executor.
+// When the executor context is done, the remaining rows and columns are not blurred.
+func StackBlurWithExecutor(e *parallel.Executor, src hdr.Image, radius int) hdr.Image {
+	m := planarCopy(e, src)
+	width, height := m.Rect.Dx(), m.Rect.Dy()
+
+	for _, plane := range m.Planes {
+		completed := e.Lines(height, func(y1, y2 int) {
+			stack := make([]float64, 2*radius+1)
+			for y := y1; y < y2; y++ {
+				stackBlurLine(plane, y*m.Stride, 1, width, radius, stack)
 			}
+		})
+		<-completed
 
-			pr, pg, pb, _ = m.HDRAt(p, y).HDRRGBA()
-
-			stack.r = pr
-			stack.g = pg
-			stack.b = pb
-
-			rSum += stack.r * float64(radiusPlus1-i)
-			gSum += stack.g * float64(radiusPlus1-i)
-			bSum += stack.b * float64(radiusPlus1-i)
-
-			rInSum += pr
-			gInSum += pg
-			bInSum += pb
-
-			stack = stack.next
-		}
-
-		stackIn = stackStart
-		stackOut = stackEnd
-
-		for x := 0; x < width; x++ {
-			ms.Set(x, y, hdrcolor.RGB{
-				R: rSum / divsum,
-				G: gSum / divsum,
-				B: bSum / divsum,
-			})
-
-			rSum -= rOutSum
-			gSum -= gOutSum
-			bSum -= bOutSum
-
-			rOutSum -= stackIn.r
-			gOutSum -= stackIn.g
-			bOutSum -= stackIn.b
-
-			p = x + radius + 1
-
-			if p > widthMinus1 {
-				p = widthMinus1
+		completed = e.Lines(width, func(x1, x2 int) {
+			stack := make([]float64, 2*radius+1)
+			for x := x1; x < x2; x++ {
+				stackBlurLine(plane, x, m.Stride, height, radius, stack)
 			}
-
-			stackIn.r, stackIn.g, stackIn.b, _ = m.HDRAt(p, y).HDRRGBA()
-
-			rInSum += stackIn.r
-			gInSum += stackIn.g
-			bInSum += stackIn.b
-
-			rSum += rInSum
-			gSum += gInSum
-			bSum += bInSum
-
-			stackIn = stackIn.next
-
-			pr = stackOut.r
-			pg = stackOut.g
-			pb = stackOut.b
-
-			rOutSum += pr
-			gOutSum += pg
-			bOutSum += pb
-
-			rInSum -= pr
-			gInSum -= pg
-			bInSum -= pb
-
-			stackOut = stackOut.next
-		}
+		})
+		<-completed
 	}
 
-	for x := 0; x < width; x++ {
-		rInSum, gInSum, bInSum, rSum, gSum, bSum = 0, 0, 0, 0, 0, 0
-
-		pr, pg, pb, _ = m.HDRAt(x, 0).HDRRGBA()
-
-		rOutSum = float64(radiusPlus1) * pr
-		gOutSum = float64(radiusPlus1) * pg
-		bOutSum = float64(radiusPlus1) * pb
-
-		rSum += float64(sumFactor) * pr
-		gSum += float64(sumFactor) * pg
-		bSum += float64(sumFactor) * pb
-
-		stack = stackStart
-
-		for i := 0; i < radiusPlus1; i++ {
-			stack.r = pr
-			stack.g = pg
-			stack.b = pb
-			stack = stack.next
-		}
-
-		for i := 1; i <= radius; i++ {
-			pr, pg, pb, _ = m.HDRAt(x, i).HDRRGBA()
+	return fromPlanar(e, m, src)
+}
 
-			stack.r = pr
-			stack.g = pg
-			stack.b = pb
+// stackBlurLine blurs in place the n values plane[offset], plane[offset+step], ... of a line (row or column).
+// The stack is a ring buffer of 2*radius+1 values.
+func stackBlurLine(plane []float32, offset, step, n, radius int, stack []float64) {
+	var sum, inSum, outSum, p float64
 
-			rSum += stack.r * float64(radiusPlus1-i)
-			gSum += stack.g * float64(radiusPlus1-i)
-			bSum += stack.b * float64(radiusPlus1-i)
+	div := len(stack)
+	radiusPlus1 := radius + 1
+	sumFactor := radiusPlus1 * (radiusPlus1 + 1) / 2
 
-			rInSum += pr
-			gInSum += pg
-			bInSum += pb
+	divsum := float64((div + 1) >> 1)
+	divsum *= divsum
 
-			stack = stack.next
+	// The line is extended with its edge values
+	at := func(i int) float64 {
+		if i > n-1 {
+			i = n - 1
 		}
+		return float64(plane[offset+i*step])
+	}
 
-		stackIn = stackStart
-		stackOut = stackEnd
-
-		for y := 0; y < height; y++ {
-			ms.Set(x, y, hdrcolor.RGB{
-				R: rSum / divsum,
-				G: gSum / divsum,
-				B: bSum / divsum,
-			})
-
-			rSum -= rOutSum
-			gSum -= gOutSum
-			bSum -= bOutSum
-
-			rOutSum -= stackIn.r
-			gOutSum -= stackIn.g
-			bOutSum -= stackIn.b
+	p = at(0)
+	outSum = float64(radiusPlus1) * p
+	sum += float64(sumFactor) * p
 
-			p = y + radiusPlus1
-			if p > heightMinus1 {
-				p = heightMinus1
-			}
+	for i := 0; i < radiusPlus1; i++ {
+		stack[i] = p
+	}
 
-			stackIn.r, stackIn.g, stackIn.b, _ = m.HDRAt(x, p).HDRRGBA()
+	for i := 1; i < radiusPlus1; i++ {
+		p = at(i)
+		stack[radius+i] = p
 
-			rInSum += stackIn.r
-			gInSum += stackIn.g
-			bInSum += stackIn.b
+		sum += p * float64(radiusPlus1-i)
+		inSum += p
+	}
 
-			rSum += rInSum
-			gSum += gInSum
-			bSum += bInSum
+	stackIn, stackOut := 0, radiusPlus1%div
+	for i := 0; i < n; i++ {
+		plane[offset+i*step] = float32(sum / divsum)
 
-			stackIn = stackIn.next
+		sum -= outSum
+		outSum -= stack[stackIn]
 
-			pr = stackOut.r
-			pg = stackOut.g
-			pb = stackOut.b
+		stack[stackIn] = at(i + radiusPlus1)
+		inSum += stack[stackIn]
+		sum += inSum
 
-			rOutSum += pr
-			gOutSum += pg
-			bOutSum += pb
+		stackIn = (stackIn + 1) % div
 
-			rInSum -= pr
-			gInSum -= pg
-			bInSum -= pb
+		p = stack[stackOut]
+		outSum += p
+		inSum -= p
 
-			stackOut = stackOut.next
-		}
+		stackOut = (stackOut + 1) % div
 	}
-
-	return m
-}
-
-//-----------------------------------------//
-//                                         //
-// stack                                   //
-//                                         //
-//-----------------------------------------//
-
-// blurStack is a linked list containing the color value and a pointer to the next struct.
-type blurStack struct {
-	r, g, b float64
-	next    *blurStack
-}
-
-// NewBlurStack is a constructor function returning a new struct of type blurStack.
-func (f *blurStack) NewBlurStack() *blurStack {
-	return &blurStack{r: f.r, g: f.g, b: f.b, next: f.next}
 }
@@ -8,6 +8,7 @@ import (
 
 	"github.com/mdouchement/hdr"
 	"github.com/mdouchement/hdr/hdrcolor"
+	"github.com/mdouchement/hdr/parallel"
 	"github.com/mdouchement/hdr/xmath"
 	"gonum.org/v1/gonum/mat"
 )
@@ -21,10 +22,14 @@ const yDimension = 3
 // References:
 // https://github.com/mdouchement/bilateral
 // http://people.csail.mit.edu/sparis/bf/
+//
+// The luminance is filtered and the colors are returned in its working color space.
 type YFastBilateral struct {
 	HDRImage   hdr.Image
 	SigmaRange float64
 	SigmaSpace float64
+	// Executor cancels and reports the progress of Perform (optional).
+	Executor   *parallel.Executor
 	minmaxOnce sync.Once
 	min        float64
 	max        float64
@@ -32,10 +37,10 @@ type YFastBilateral struct {
 	// 0 -> smallWidth
 	// 1 -> smallHeight
 	// 2 -> smallLuminance
-	size     []int
-	grid     *mat.Dense
-	auto     bool
-	newColor func(z1, z2, z3 float64) hdrcolor.Color
+	size  []int
+	grid  *mat.Dense
+	auto  bool
+	space hdr.ColorSpace
 }
 
 // NewYFastBilateralAuto instanciates a new YFastBilateral with automatic sigma values.
@@ -45,7 +50,8 @@ func NewYFastBilateralAuto(m hdr.Image) *YFastBilateral {
 	return f
 }
 
-// NewYFastBilateral instanciates a new YFastBilateral.
+// NewYFastBilateral instanciates a new YFastBilateral working in XYZ for the XYZ images and in RGB otherwise.
+// The arguments are not validated, see NewYFastBilateralWithSpace.
 func NewYFastBilateral(m hdr.Image, sigmaSpace, sigmaRange float64) *YFastBilateral {
 	f := &YFastBilateral{
 		HDRImage:   m,
@@ -54,30 +60,47 @@ func NewYFastBilateral(m hdr.Image, sigmaSpace, sigmaRange float64) *YFastBilate
 		min:        math.Inf(1),
 		max:        math.Inf(-1),
 		size:       make([]int, yDimension),
+		space:      hdr.RGBSpace,
 	}
 
-	switch m.ColorModel() {
-	case hdrcolor.XYZModel:
-		f.newColor = func(x, y, z float64) hdrcolor.Color {
-			return hdrcolor.XYZ{X: x, Y: y, Z: z}
-		}
-	case hdrcolor.RGBModel:
-		fallthrough
-	default:
-		f.newColor = func(x, y, z float64) hdrcolor.Color {
-			return hdrcolor.RGBModel.Convert(hdrcolor.XYZ{X: x, Y: y, Z: z}).(hdrcolor.Color)
-		}
+	if m.ColorModel() == hdrcolor.XYZModel {
+		f.space = hdr.XYZSpace
 	}
 
 	return f
 }
 
+// NewYFastBilateralWithSpace instanciates a new YFastBilateral working in the given color space
+// (hdr.RGBSpace or hdr.XYZSpace, the luminance has no meaning in hdr.RAWSpace).
+// It returns an ArgumentError when the image is nil or a sigma is not a positive finite number.
+func NewYFastBilateralWithSpace(m hdr.Image, space hdr.ColorSpace, sigmaSpace, sigmaRange float64) (*YFastBilateral, error) {
+	if err := checkSpace(space, m); err != nil {
+		return nil, err
+	}
+	if space == hdr.RAWSpace {
+		return nil, UnsupportedError("raw color space")
+	}
+	if err := checkBilateral(m, sigmaSpace, sigmaRange); err != nil {
+		return nil, err
+	}
+
+	f := NewYFastBilateral(m, sigmaSpace, sigmaRange)
+	f.space = space
+	return f, nil
+}
+
 // Perform runs the bilateral filter.
+// The remaining stages are skipped once the executor context is done.
 func (f *YFastBilateral) Perform() {
-	f.minmaxOnce.Do(f.minmax)
-	f.downsampling()
-	f.convolution()
-	f.normalize()
+	var src *hdr.Planar // The grid is built from the luminance plane
+	stages := []func(){
+		func() { src = toPlanar(f.Executor, f.HDRImage, hdr.XYZSpace) },
+		func() { f.minmaxOnce.Do(func() { f.minmax(src) }) },
+		func() { f.downsampling(src) },
+		f.convolution,
+		f.normalize,
+	}
+	performStages(f.Executor, stages)
 }
 
 // ColorModel returns the Image's color model.
@@ -98,15 +121,41 @@ func (f *YFastBilateral) Size() int {
 // HDRAt computes the interpolation and returns the filtered color at the given coordinates.
 func (f *YFastBilateral) HDRAt(x, y int) hdrcolor.Color {
 	X, Y, Z, _ := f.HDRImage.HDRAt(x, y).HDRXYZA()
+	min := f.HDRImage.Bounds().Min
+	Y2 := f.luminance(x-min.X, y-min.Y, Y)
+
+	delta := Y - Y2
+	c := []float64{X - delta, Y2, Z - delta}
+	hdr.ConvertRow(c, hdr.XYZSpace, f.space)
+	return f.space.Color(c[0], c[1], c[2])
+}
 
+// ReadRow implements hdr.RowReader.
+func (f *YFastBilateral) ReadRow(dst []float64, x1, x2, y int, space hdr.ColorSpace) {
+	row := dst[:3*(x2-x1)]
+	hdr.ReadRow(f.HDRImage, row, x1, x2, y, hdr.XYZSpace)
+	min := f.HDRImage.Bounds().Min
+	for x, i := x1, 0; x < x2; x, i = x+1, i+3 {
+		Y := row[i+1]
+		Y2 := f.luminance(x-min.X, y-min.Y, Y)
+
+		delta := Y - Y2
+		row[i+0] -= delta
+		row[i+1] = Y2
+		row[i+2] -= delta
+	}
+
+	hdr.ConvertRow(row, hdr.XYZSpace, f.space)
+	hdr.ConvertRow(row, f.space, space)
+}
+
+// luminance interpolates the filtered luminance of the pixel (x, y), relative to the image origin, whose luminance is Y.
+func (f *YFastBilateral) luminance(x, y int, Y float64) float64 {
 	// Grid coords
 	gw := float64(x)/f.SigmaSpace + paddingS // Grid width
 	gh := float64(y)/f.SigmaSpace + paddingS // Grid height
 	gc := (Y-f.min)/f.SigmaRange + paddingR  // Grid Y
-	Y2 := f.trilinearInterpolation(gw, gh, gc)
-
-	delta := Y - Y2
-	return f.newColor(X-delta, Y2, Z-delta)
+	return f.trilinearInterpolation(gw, gh, gc)
 }
 
 // At computes the interpolation and returns the filtered color at the given coordinates.
@@ -124,10 +173,10 @@ func (f *YFastBilateral) At(x, y int) color.Color {
 func (f *YFastBilateral) HDRResultImage() hdr.Image {
 	d := f.HDRImage.Bounds()
 	dst := hdr.NewRGB(d)
-	for x := 0; x < d.Dx(); x++ {
-		for y := 0; y < d.Dy(); y++ {
-			dst.Set(x, y, f.HDRAt(x, y))
-		}
+	row := make([]float64, 3*d.Dx())
+	for y := d.Min.Y; y < d.Max.Y; y++ {
+		f.ReadRow(row, d.Min.X, d.Max.X, y, hdr.RGBSpace)
+		dst.WriteRow(row, d.Min.X, d.Max.X, y, hdr.RGBSpace)
 	}
 	return dst
 }
@@ -136,26 +185,27 @@ func (f *YFastBilateral) HDRResultImage() hdr.Image {
 func (f *YFastBilateral) ResultImage() hdr.Image {
 	d := f.HDRImage.Bounds()
 	dst := hdr.NewRGB(d)
-	for x := 0; x < d.Dx(); x++ {
-		for y := 0; y < d.Dy(); y++ {
-			dst.Set(x, y, f.HDRAt(x, y))
-		}
+	row := make([]float64, 3*d.Dx())
+	for y := d.Min.Y; y < d.Max.Y; y++ {
+		f.ReadRow(row, d.Min.X, d.Max.X, y, hdr.RGBSpace)
+		dst.WriteRow(row, d.Min.X, d.Max.X, y, hdr.RGBSpace)
 	}
 	return dst
 }
 
-func (f *YFastBilateral) minmax() {
-	d := f.HDRImage.Bounds()
-	for y := 0; y < d.Dy(); y++ {
-		for x := 0; x < d.Dx(); x++ {
-			_, Y, _, _ := f.HDRImage.HDRAt(x, y).HDRXYZA()
-			f.min = math.Min(f.min, Y)
-			f.max = math.Max(f.max, Y)
+func (f *YFastBilateral) minmax(src *hdr.Planar) {
+	d := src.Bounds()
+	for y := d.Min.Y; y < d.Max.Y; y++ {
+		for _, Y := range src.Row(1, y) {
+			f.min = math.Min(f.min, float64(Y))
+			f.max = math.Max(f.max, float64(Y))
 		}
 	}
 
 	if f.auto {
-		f.SigmaRange = (f.max - f.min) * 0.1
+		if sigma := (f.max - f.min) * 0.1; sigma > 0 { // Keeps the default sigma on flat images
+			f.SigmaRange = sigma
+		}
 	}
 
 	f.size[0] = int(float64(d.Dx()-1)/f.SigmaSpace) + 1 + 2*paddingS
@@ -167,21 +217,21 @@ func (f *YFastBilateral) minmax() {
 	// fmt.Println("size:", xmath.Mul(f.size...), f.size)
 }
 
-func (f *YFastBilateral) downsampling() {
-	d := f.HDRImage.Bounds()
+func (f *YFastBilateral) downsampling(src *hdr.Planar) {
+	d := src.Bounds()
 	offset := make([]int, yDimension)
 
 	size := xmath.Mul(f.size...)
 	dim := yDimension - 1 // # 1 luminance and 1 threshold (edge weight)
 	f.grid = mat.NewDense(size, dim, make([]float64, dim*size))
 
-	for x := 0; x < d.Dx(); x++ {
-		offset[0] = int(float64(x)/f.SigmaSpace+0.5) + paddingS
+	for y := 0; y < d.Dy(); y++ {
+		offset[1] = int(float64(y)/f.SigmaSpace+0.5) + paddingS
 
-		for y := 0; y < d.Dy(); y++ {
-			offset[1] = int(float64(y)/f.SigmaSpace+0.5) + paddingS
+		for x, lum := range src.Row(1, d.Min.Y+y) {
+			offset[0] = int(float64(x)/f.SigmaSpace+0.5) + paddingS
 
-			_, Y, _, _ := f.HDRImage.HDRAt(x, y).HDRXYZA()
+			Y := float64(lum)
 			offset[2] = int((Y-f.min)/f.SigmaRange+0.5) + paddingR
 
 			i := f.offset(offset...)
@@ -3,11 +3,16 @@ package hdr
 import "image"
 
 // Split tries to split the given rectangle coordinates in n tiles.
+//
+// Deprecated: the tiles are not balanced, use parallel.Split.
 func Split(x1, y1, x2, y2, n int) []image.Rectangle {
 	return SplitWithRectangle(image.Rectangle{image.Point{x1, y1}, image.Point{x2, y2}}, n)
 }
 
 // SplitWithRectangle tries to split the given rectangle (image) in n tiles.
+// The tiles cover every pixel of r exactly once.
+//
+// Deprecated: the tiles are not balanced, use parallel.Split.
 func SplitWithRectangle(r image.Rectangle, n int) []image.Rectangle {
 	if n < 2 {
 		return []image.Rectangle{r}
@@ -37,16 +42,17 @@ func SplitWithRectangle(r image.Rectangle, n int) []image.Rectangle {
 		}
 	}
 
-	tileWidth := (r.Min.X + r.Max.X) / nx
-	tileHeight := (r.Min.Y + r.Max.Y) / ny
-
-	splits := make([]image.Rectangle, 0, n*n)
+	splits := make([]image.Rectangle, 0, n)
 	for y := 0; y < ny; y++ {
 		for x := 0; x < nx; x++ {
-			splits = append(splits, image.Rectangle{
-				image.Point{tileWidth * x, tileHeight * y},
-				image.Point{tileWidth*x + tileWidth, tileHeight*y + tileHeight},
-			})
+			// The remainder pixels are spread over the tiles
+			tile := image.Rect(
+				r.Min.X+x*r.Dx()/nx, r.Min.Y+y*r.Dy()/ny,
+				r.Min.X+(x+1)*r.Dx()/nx, r.Min.Y+(y+1)*r.Dy()/ny,
+			)
+			if !tile.Empty() {
+				splits = append(splits, tile)
+			}
 		}
 	}
 
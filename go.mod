@@ -6,10 +6,8 @@ require (
 	github.com/fxamacker/cbor/v2 v2.4.0
 	github.com/klauspost/compress v1.15.15
 	github.com/lucasb-eyer/go-colorful v1.2.0
+	github.com/x448/float16 v0.8.4
 	gonum.org/v1/gonum v0.12.0
 )
 
-require (
-	github.com/x448/float16 v0.8.4 // indirect
-	gonum.org/v1/netlib v0.0.0-20200229103305-d71f404090bf // indirect
-)
+require gonum.org/v1/netlib v0.0.0-20200229103305-d71f404090bf // indirect
@@ -0,0 +1,233 @@
+package hdr
+
+import (
+	"image"
+	"image/color"
+
+	"github.com/mdouchement/hdr/hdrcolor"
+	"github.com/x448/float16"
+)
+
+//===============//
+// RGB16         //
+//===============//
+
+// RGB16 is an in-memory 16 bits (half-precision) floating points image whose At method returns hdrcolor.RGB values.
+// The values greater than 65504 are stored as +Inf.
+type RGB16 struct {
+	// Pix holds the image's pixels as IEEE 754 half-precision bits, in R, G, B order. The pixel at
+	// (x, y) starts at Pix[(y-Rect.Min.Y)*Stride + (x-Rect.Min.X)*3].
+	Pix []uint16
+	// Stride is the Pix stride (in values) between vertically adjacent pixels.
+	Stride int
+	// Rect is the image's bounds.
+	Rect image.Rectangle
+}
+
+// NewRGB16 returns a new half-precision HDR RGB image with the given bounds.
+func NewRGB16(r image.Rectangle) *RGB16 {
+	w, h := r.Dx(), r.Dy()
+	buf := make([]uint16, 3*w*h)
+	return &RGB16{buf, 3 * w, r}
+}
+
+// ColorModel implements Image.
+func (p *RGB16) ColorModel() color.Model { return hdrcolor.RGBModel }
+
+// Bounds implements Image.
+func (p *RGB16) Bounds() image.Rectangle { return p.Rect }
+
+// Size implements Image.
+func (p *RGB16) Size() int {
+	return p.Bounds().Dx() * p.Bounds().Dy()
+}
+
+// At implements Image.
+func (p *RGB16) At(x, y int) color.Color {
+	return p.RGBAt(x, y)
+}
+
+// HDRAt implements Image.
+func (p *RGB16) HDRAt(x, y int) hdrcolor.Color {
+	return p.RGBAt(x, y)
+}
+
+// RGBAt returns the RGB color at this coordinate.
+func (p *RGB16) RGBAt(x, y int) hdrcolor.RGB {
+	if !(image.Point{x, y}.In(p.Rect)) {
+		return hdrcolor.RGB{}
+	}
+	i := p.PixOffset(x, y)
+	return hdrcolor.RGB{
+		R: fromHalf(p.Pix[i+0]),
+		G: fromHalf(p.Pix[i+1]),
+		B: fromHalf(p.Pix[i+2]),
+	}
+}
+
+// PixOffset returns the index of the first element of Pix that corresponds to
+// the pixel at (x, y).
+func (p *RGB16) PixOffset(x, y int) int {
+	return (y-p.Rect.Min.Y)*p.Stride + (x-p.Rect.Min.X)*3
+}
+
+// Set adds pixel to Image at given x, y.
+func (p *RGB16) Set(x, y int, c color.Color) {
+	p.SetRGB(x, y, hdrcolor.RGBModel.Convert(c).(hdrcolor.RGB))
+}
+
+// SetRGB applies the given RGB color at this coordinate.
+func (p *RGB16) SetRGB(x, y int, c hdrcolor.RGB) {
+	if !(image.Point{x, y}.In(p.Rect)) {
+		return
+	}
+	i := p.PixOffset(x, y)
+	p.Pix[i+0] = toHalf(c.R)
+	p.Pix[i+1] = toHalf(c.G)
+	p.Pix[i+2] = toHalf(c.B)
+}
+
+// ReadRow implements RowReader.
+func (p *RGB16) ReadRow(dst []float64, x1, x2, y int, space ColorSpace) {
+	readRowHalf(dst, p.Pix, p.Stride, p.Rect, RGBSpace, x1, x2, y, space)
+}
+
+// WriteRow implements RowWriter.
+func (p *RGB16) WriteRow(src []float64, x1, x2, y int, space ColorSpace) {
+	writeRowHalf(p.Pix, p.Stride, p.Rect, RGBSpace, src, x1, x2, y, space)
+}
+
+//===============//
+// XYZ16         //
+//===============//
+
+// XYZ16 is an in-memory 16 bits (half-precision) floating points image whose At method returns hdrcolor.XYZ values.
+// The values greater than 65504 are stored as +Inf.
+type XYZ16 struct {
+	// Pix holds the image's pixels as IEEE 754 half-precision bits, in X, Y, Z order. The pixel at
+	// (x, y) starts at Pix[(y-Rect.Min.Y)*Stride + (x-Rect.Min.X)*3].
+	Pix []uint16
+	// Stride is the Pix stride (in values) between vertically adjacent pixels.
+	Stride int
+	// Rect is the image's bounds.
+	Rect image.Rectangle
+}
+
+// NewXYZ16 returns a new half-precision HDR XYZ image with the given bounds.
+func NewXYZ16(r image.Rectangle) *XYZ16 {
+	w, h := r.Dx(), r.Dy()
+	buf := make([]uint16, 3*w*h)
+	return &XYZ16{buf, 3 * w, r}
+}
+
+// ColorModel implements Image.
+func (p *XYZ16) ColorModel() color.Model { return hdrcolor.XYZModel }
+
+// Bounds implements Image.
+func (p *XYZ16) Bounds() image.Rectangle { return p.Rect }
+
+// Size implements Image.
+func (p *XYZ16) Size() int {
+	return p.Bounds().Dx() * p.Bounds().Dy()
+}
+
+// At implements Image.
+func (p *XYZ16) At(x, y int) color.Color {
+	return p.XYZAt(x, y)
+}
+
+// HDRAt implements Image.
+func (p *XYZ16) HDRAt(x, y int) hdrcolor.Color {
+	return p.XYZAt(x, y)
+}
+
+// XYZAt returns the XYZ color at this coordinate.
+func (p *XYZ16) XYZAt(x, y int) hdrcolor.XYZ {
+	if !(image.Point{x, y}.In(p.Rect)) {
+		return hdrcolor.XYZ{}
+	}
+	i := p.PixOffset(x, y)
+	return hdrcolor.XYZ{
+		X: fromHalf(p.Pix[i+0]),
+		Y: fromHalf(p.Pix[i+1]),
+		Z: fromHalf(p.Pix[i+2]),
+	}
+}
+
+// PixOffset returns the index of the first element of Pix that corresponds to
+// the pixel at (x, y).
+func (p *XYZ16) PixOffset(x, y int) int {
+	return (y-p.Rect.Min.Y)*p.Stride + (x-p.Rect.Min.X)*3
+}
+
+// Set adds pixel to Image at given x, y.
+func (p *XYZ16) Set(x, y int, c color.Color) {
+	p.SetXYZ(x, y, hdrcolor.XYZModel.Convert(c).(hdrcolor.XYZ))
+}
+
+// SetXYZ applies the given XYZ color at this coordinate.
+func (p *XYZ16) SetXYZ(x, y int, c hdrcolor.XYZ) {
+	if !(image.Point{x, y}.In(p.Rect)) {
+		return
+	}
+	i := p.PixOffset(x, y)
+	p.Pix[i+0] = toHalf(c.X)
+	p.Pix[i+1] = toHalf(c.Y)
+	p.Pix[i+2] = toHalf(c.Z)
+}
+
+// ReadRow implements RowReader.
+func (p *XYZ16) ReadRow(dst []float64, x1, x2, y int, space ColorSpace) {
+	readRowHalf(dst, p.Pix, p.Stride, p.Rect, XYZSpace, x1, x2, y, space)
+}
+
+// WriteRow implements RowWriter.
+func (p *XYZ16) WriteRow(src []float64, x1, x2, y int, space ColorSpace) {
+	writeRowHalf(p.Pix, p.Stride, p.Rect, XYZSpace, src, x1, x2, y, space)
+}
+
+//
+
+// readRowHalf implements RowReader for the half-precision images storing their pixels in the native color space.
+func readRowHalf(dst []float64, pix []uint16, stride int, rect image.Rectangle, native ColorSpace, x1, x2, y int, space ColorSpace) {
+	n := 3 * (x2 - x1)
+	zero(dst[:n])
+	if y < rect.Min.Y || y >= rect.Max.Y {
+		return
+	}
+
+	ix1, ix2 := clampX(rect, x1), clampX(rect, x2)
+	row := dst[3*(ix1-x1) : 3*(ix2-x1)]
+	offset := (y-rect.Min.Y)*stride + (ix1-rect.Min.X)*3
+	for i, v := range pix[offset : offset+len(row)] {
+		row[i] = fromHalf(v)
+	}
+	ConvertRow(row, native, space)
+}
+
+// writeRowHalf implements RowWriter for the half-precision images storing their pixels in the native color space.
+func writeRowHalf(pix []uint16, stride int, rect image.Rectangle, native ColorSpace, src []float64, x1, x2, y int, space ColorSpace) {
+	if y < rect.Min.Y || y >= rect.Max.Y {
+		return
+	}
+
+	ix1, ix2 := clampX(rect, x1), clampX(rect, x2)
+	offset := (y-rect.Min.Y)*stride + (ix1-rect.Min.X)*3
+	for x, i := ix1, 3*(ix1-x1); x < ix2; x, i = x+1, i+3 {
+		p1, p2, p3 := src[i+0], src[i+1], src[i+2]
+		if converts(space, native) {
+			p1, p2, p3 = convert(space, native)(p1, p2, p3)
+		}
+
+		pix[offset+0], pix[offset+1], pix[offset+2] = toHalf(p1), toHalf(p2), toHalf(p3)
+		offset += 3
+	}
+}
+
+func toHalf(v float64) uint16 {
+	return float16.Fromfloat32(float32(v)).Bits()
+}
+
+func fromHalf(v uint16) float64 {
+	return float64(float16.Frombits(v).Float32())
+}
@@ -146,6 +146,7 @@ func (c RAW) HDRPixel() (p1, p2, p3, pa float64) {
 var (
 	RGBModel = color.ModelFunc(rgbModel)
 	XYZModel = color.ModelFunc(xyzModel)
+	RAWModel = color.ModelFunc(rawModel)
 )
 
 func rgbModel(c color.Color) color.Color {
@@ -183,3 +184,20 @@ func xyzModel(c color.Color) color.Color {
 	x, y, z := colorful.LinearRgbToXyz(float64(r), float64(g), float64(b))
 	return XYZ{X: x, Y: y, Z: z}
 }
+
+func rawModel(c color.Color) color.Color {
+	if _, ok := c.(RAW); ok {
+		// Already RAW
+		return c
+	}
+
+	if hdrc, ok := c.(Color); ok {
+		// HDR color
+		p1, p2, p3, _ := hdrc.HDRPixel()
+		return RAW{P1: p1, P2: p2, P3: p3}
+	}
+
+	// LDR color
+	r, g, b, _ := c.RGBA()
+	return RAW{P1: float64(r), P2: float64(g), P3: float64(b)}
+}
@@ -35,6 +35,12 @@ func EmptyAs(src Image) Image {
 		return NewXYZ(m.Bounds())
 	case *XYZ64:
 		return NewXYZ64(m.Bounds())
+	case *RGB16:
+		return NewRGB16(m.Bounds())
+	case *XYZ16:
+		return NewXYZ16(m.Bounds())
+	case *Planar:
+		return NewPlanarAligned(m.Bounds(), m.Space, m.Stride)
 	default:
 		// fallback
 		return NewRGB64(m.Bounds())
@@ -60,6 +66,20 @@ func Copy(src Image) Image {
 		dst := NewXYZ64(m.Bounds())
 		copy(dst.Pix, m.Pix)
 		return dst
+	case *RGB16:
+		dst := NewRGB16(m.Bounds())
+		copy(dst.Pix, m.Pix)
+		return dst
+	case *XYZ16:
+		dst := NewXYZ16(m.Bounds())
+		copy(dst.Pix, m.Pix)
+		return dst
+	case *Planar:
+		dst := NewPlanarAligned(m.Bounds(), m.Space, m.Stride)
+		for c := range dst.Planes {
+			copy(dst.Planes[c], m.Planes[c])
+		}
+		return dst
 	default:
 		// fallback
 		dst := NewRGB64(m.Bounds())
@@ -72,6 +92,57 @@ func Copy(src Image) Image {
 	}
 }
 
+// A Buffer is an in-memory image that reads and writes its pixels by rows.
+type Buffer interface {
+	RowReader
+	RowWriter
+}
+
+// A Precision is the size of the floating points channels of an in-memory image.
+type Precision int
+
+const (
+	// Float32 is the precision of RGB and XYZ images.
+	Float32 Precision = iota
+	// Float64 is the precision of RGB64 and XYZ64 images.
+	Float64
+	// Float16 is the (half) precision of RGB16 and XYZ16 images.
+	Float16
+)
+
+// NewBuffer returns a new in-memory image with the given bounds, color space and precision.
+// The XYZSpace returns a XYZ, XYZ64 or XYZ16 image, the other color spaces a RGB, RGB64 or RGB16 image.
+func NewBuffer(r image.Rectangle, space ColorSpace, precision Precision) Buffer {
+	xyz := space == XYZSpace
+
+	switch {
+	case precision == Float64 && xyz:
+		return NewXYZ64(r)
+	case precision == Float64:
+		return NewRGB64(r)
+	case precision == Float16 && xyz:
+		return NewXYZ16(r)
+	case precision == Float16:
+		return NewRGB16(r)
+	case xyz:
+		return NewXYZ(r)
+	default:
+		return NewRGB(r)
+	}
+}
+
+// Size returns the number of bytes of a pixel with this precision (3 channels).
+func (p Precision) Size() int {
+	switch p {
+	case Float64:
+		return 3 * 8
+	case Float16:
+		return 3 * 2
+	default:
+		return 3 * 4
+	}
+}
+
 //===============//
 // RGB           //
 //===============//
@@ -158,6 +229,16 @@ func (p *RGB) SetRGB(x, y int, c hdrcolor.RGB) {
 	p.Pix[i+2] = float32(c.B)
 }
 
+// ReadRow implements RowReader.
+func (p *RGB) ReadRow(dst []float64, x1, x2, y int, space ColorSpace) {
+	readRow(dst, p.Pix, p.Stride, p.Rect, RGBSpace, x1, x2, y, space)
+}
+
+// WriteRow implements RowWriter.
+func (p *RGB) WriteRow(src []float64, x1, x2, y int, space ColorSpace) {
+	writeRow(p.Pix, p.Stride, p.Rect, RGBSpace, src, x1, x2, y, space)
+}
+
 // RGB64 is an in-memory 64 bits floating points image whose At method returns hdrcolor.RGB values.
 type RGB64 struct {
 	// Pix holds the image's pixels, in R, G, B order. The pixel at
@@ -236,6 +317,16 @@ func (p *RGB64) SetRGB(x, y int, c hdrcolor.RGB) {
 	p.Pix[i+2] = c.B
 }
 
+// ReadRow implements RowReader.
+func (p *RGB64) ReadRow(dst []float64, x1, x2, y int, space ColorSpace) {
+	readRow(dst, p.Pix, p.Stride, p.Rect, RGBSpace, x1, x2, y, space)
+}
+
+// WriteRow implements RowWriter.
+func (p *RGB64) WriteRow(src []float64, x1, x2, y int, space ColorSpace) {
+	writeRow(p.Pix, p.Stride, p.Rect, RGBSpace, src, x1, x2, y, space)
+}
+
 //===============//
 // XYZ           //
 //===============//
@@ -322,6 +413,16 @@ func (p *XYZ) SetXYZ(x, y int, c hdrcolor.XYZ) {
 	p.Pix[i+2] = float32(c.Z)
 }
 
+// ReadRow implements RowReader.
+func (p *XYZ) ReadRow(dst []float64, x1, x2, y int, space ColorSpace) {
+	readRow(dst, p.Pix, p.Stride, p.Rect, XYZSpace, x1, x2, y, space)
+}
+
+// WriteRow implements RowWriter.
+func (p *XYZ) WriteRow(src []float64, x1, x2, y int, space ColorSpace) {
+	writeRow(p.Pix, p.Stride, p.Rect, XYZSpace, src, x1, x2, y, space)
+}
+
 // XYZ64 is an in-memory 64 bits floating points image whose At method returns hdrcolor.XYZ values.
 type XYZ64 struct {
 	// Pix holds the image's pixels, in X, Y and Z order. The pixel at
@@ -399,3 +500,13 @@ func (p *XYZ64) SetXYZ(x, y int, c hdrcolor.XYZ) {
 	p.Pix[i+1] = c.Y
 	p.Pix[i+2] = c.Z
 }
+
+// ReadRow implements RowReader.
+func (p *XYZ64) ReadRow(dst []float64, x1, x2, y int, space ColorSpace) {
+	readRow(dst, p.Pix, p.Stride, p.Rect, XYZSpace, x1, x2, y, space)
+}
+
+// WriteRow implements RowWriter.
+func (p *XYZ64) WriteRow(src []float64, x1, x2, y int, space ColorSpace) {
+	writeRow(p.Pix, p.Stride, p.Rect, XYZSpace, src, x1, x2, y, space)
+}
@@ -0,0 +1,175 @@
+package parallel
+
+import (
+	"context"
+	"image"
+	"sync"
+)
+
+// An Executor runs the parallel stages of the filters, TMOs and codecs.
+// It limits the number of workers, stops scheduling the remaining work once its context is done
+// and reports the progress.
+//
+// The areas are split in many small tiles (see Split) which are balanced between the workers by work stealing.
+//
+// A nil *Executor runs with runtime.NumCPU() workers, without cancellation nor progress.
+type Executor struct {
+	// Context cancels the remaining work when it is done (optional).
+	Context context.Context
+	// Workers is the number of parallel goroutines, runtime.NumCPU() when not positive.
+	Workers int
+	// Strategy is the tiling of the areas.
+	Strategy Strategy
+	// TileSize is the side of the square tiles or the height of the strips.
+	// When not positive, it is adapted to the number of workers (about 8 tiles per worker).
+	TileSize int
+	// Deterministic uses a fixed tile size when TileSize is not positive, so the tiles do not depend on the number of workers.
+	// Combined in tile index order (see Run), the partial results of the tiles are then reproducible across machines.
+	Deterministic bool
+	// Progress is called after each completed unit of work (tile, chunk, scanline)
+	// with the completed and total units of all the stages started so far (optional).
+	// The total grows when a new stage starts. The calls are serialized.
+	Progress func(done, total int)
+	mu       sync.Mutex
+	done     int
+	total    int
+}
+
+// NewExecutor instanciates a new Executor with runtime.NumCPU() workers.
+func NewExecutor(ctx context.Context) *Executor {
+	return &Executor{
+		Context: ctx,
+		Workers: ncpu,
+	}
+}
+
+// TilesCtx runs Tiles with the given r boundaries until ctx is done.
+func TilesCtx(ctx context.Context, r image.Rectangle, f func(x1, y1, x2, y2 int)) chan struct{} {
+	return NewExecutor(ctx).TilesR(r, f)
+}
+
+// Err returns the error of the executor context, nil while it is not done.
+func (e *Executor) Err() error {
+	if e == nil || e.Context == nil {
+		return nil
+	}
+	return e.Context.Err()
+}
+
+// Done returns the done channel of the executor context, nil (never closed) without context.
+func (e *Executor) Done() <-chan struct{} {
+	if e == nil || e.Context == nil {
+		return nil
+	}
+	return e.Context.Done()
+}
+
+// Begin declares n units of work of a new stage.
+// It is used by the sequential stages (e.g. codecs scanlines), the parallel ones declare their units themselves.
+func (e *Executor) Begin(n int) {
+	if e == nil || e.Progress == nil {
+		return
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.total += n
+	e.Progress(e.done, e.total)
+}
+
+// Advance reports n completed units of work.
+func (e *Executor) Advance(n int) {
+	if e == nil || e.Progress == nil {
+		return
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.done += n
+	e.Progress(e.done, e.total)
+}
+
+// Split splits r in tiles according to the executor strategy and tile size.
+func (e *Executor) Split(r image.Rectangle) []image.Rectangle {
+	if e == nil {
+		return Split(r, StrategySquares, tileSize(r, StrategySquares, ncpu, false))
+	}
+
+	size := e.TileSize
+	if size <= 0 {
+		size = tileSize(r, e.Strategy, e.workers(), e.Deterministic)
+	}
+	return Split(r, e.Strategy, size)
+}
+
+// Run runs f on each tile, i is the index of the tile.
+// The tiles are balanced between the workers by work stealing
+// and the tiles not started when the context is done are skipped.
+func (e *Executor) Run(tiles []image.Rectangle, f func(i int, tile image.Rectangle)) chan struct{} {
+	wg := &sync.WaitGroup{}
+	completed := make(chan struct{})
+
+	workers := e.workers()
+	if workers > len(tiles) {
+		workers = len(tiles)
+	}
+	queues := newQueues(len(tiles), workers)
+	e.Begin(len(tiles))
+
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+
+			for e.Err() == nil {
+				i, ok := next(queues, w)
+				if !ok {
+					return
+				}
+
+				f(i, tiles[i])
+				e.Advance(1)
+			}
+
+		}(w)
+	}
+
+	go func() {
+		wg.Wait()
+		close(completed)
+	}()
+
+	return completed
+}
+
+// TilesR runs f on the tiles of r.
+func (e *Executor) TilesR(r image.Rectangle, f func(x1, y1, x2, y2 int)) chan struct{} {
+	return e.Run(e.Split(r), func(_ int, tile image.Rectangle) {
+		f(tile.Min.X, tile.Min.Y, tile.Max.X, tile.Max.Y)
+	})
+}
+
+// Tiles runs f on the tiles of the width×height area.
+func (e *Executor) Tiles(width, height int, f func(x1, y1, x2, y2 int)) chan struct{} {
+	return e.TilesR(image.Rect(0, 0, width, height), f)
+}
+
+// Lines runs f on chunks of [0, n).
+// The chunks size depends on the number of workers, even in Deterministic mode.
+func (e *Executor) Lines(n int, f func(i1, i2 int)) chan struct{} {
+	r := image.Rect(0, 0, 1, n)
+	chunks := Split(r, StrategyStrips, tileSize(r, StrategyStrips, e.workers(), false))
+
+	return e.Run(chunks, func(_ int, chunk image.Rectangle) {
+		f(chunk.Min.Y, chunk.Max.Y)
+	})
+}
+
+func (e *Executor) workers() int {
+	if e == nil || e.Workers <= 0 {
+		return ncpu
+	}
+	return e.Workers
+}
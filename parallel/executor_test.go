@@ -0,0 +1,112 @@
+package parallel
+
+import (
+	"context"
+	"image"
+	"sync/atomic"
+	"testing"
+)
+
+func TestExecutorRun(t *testing.T) {
+	tiles := Split(image.Rect(0, 0, 100, 70), StrategySquares, 16)
+
+	for _, e := range []*Executor{nil, {}, {Workers: 1}, {Workers: 3}, {Workers: 2 * len(tiles)}} {
+		calls := make([]int32, len(tiles))
+		completed := e.Run(tiles, func(i int, tile image.Rectangle) {
+			if tile != tiles[i] {
+				t.Errorf("tile %d: got %v, want %v", i, tile, tiles[i])
+			}
+			atomic.AddInt32(&calls[i], 1)
+		})
+		<-completed
+
+		for i, n := range calls {
+			if n != 1 {
+				t.Fatalf("%d workers: tile %d: got %d calls, want 1", e.workers(), i, n)
+			}
+		}
+	}
+
+	// Nothing to do
+	var e *Executor
+	<-e.Run(nil, func(int, image.Rectangle) { t.Error("unexpected call") })
+}
+
+func TestExecutorCancel(t *testing.T) {
+	tiles := Split(image.Rect(0, 0, 64, 64), StrategyStrips, 1)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	e := &Executor{Context: ctx, Workers: 4}
+	<-e.Run(tiles, func(int, image.Rectangle) { t.Error("unexpected call after the cancellation") })
+	if e.Err() != context.Canceled {
+		t.Errorf("got error %v, want %v", e.Err(), context.Canceled)
+	}
+
+	// A worker stops after its current tile
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	e = &Executor{Context: ctx, Workers: 1}
+	var calls int
+	<-e.Run(tiles, func(int, image.Rectangle) {
+		calls++
+		if calls == 3 {
+			cancel()
+		}
+	})
+	if calls != 3 {
+		t.Errorf("got %d calls, want 3", calls)
+	}
+	select {
+	case <-e.Done():
+	default:
+		t.Error("the done channel is not closed")
+	}
+}
+
+func TestExecutorNil(t *testing.T) {
+	var e *Executor
+	if e.Err() != nil || e.Done() != nil {
+		t.Error("a nil executor must not be cancelled")
+	}
+	e.Begin(2) // No progress
+	e.Advance(2)
+
+	e = &Executor{}
+	if e.Err() != nil || e.Done() != nil {
+		t.Error("an executor without context must not be cancelled")
+	}
+}
+
+func TestExecutorProgress(t *testing.T) {
+	var calls, done, total int
+	e := &Executor{Workers: 3}
+	e.Progress = func(d, n int) {
+		calls++
+		if d < done || n < total || d > n {
+			t.Errorf("got progress %d/%d after %d/%d", d, n, done, total)
+		}
+		done, total = d, n
+	}
+
+	r := image.Rect(0, 0, 90, 50)
+	<-e.TilesR(r, func(x1, y1, x2, y2 int) {})
+	tiles := len(e.Split(r))
+	if done != tiles || total != tiles {
+		t.Errorf("tiles: got progress %d/%d, want %d/%d", done, total, tiles, tiles)
+	}
+
+	// A sequential stage
+	e.Begin(10)
+	for i := 0; i < 10; i++ {
+		e.Advance(1)
+	}
+	if done != tiles+10 || total != tiles+10 {
+		t.Errorf("scanlines: got progress %d/%d, want %d/%d", done, total, tiles+10, tiles+10)
+	}
+
+	// One call when the stage begins and one per unit
+	if expected := 1 + tiles + 1 + 10; calls != expected {
+		t.Errorf("got %d progress calls, want %d", calls, expected)
+	}
+}
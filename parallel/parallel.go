@@ -3,38 +3,24 @@ package parallel
 import (
 	"image"
 	"runtime"
-	"sync"
-
-	"github.com/mdouchement/hdr"
 )
 
 var ncpu = runtime.NumCPU()
 
-// TilesR runs Tiles with the given r boundaries.
+// TilesR runs f in parallel on the tiles of r.
 func TilesR(r image.Rectangle, f func(x1, y1, x2, y2 int)) chan struct{} {
-	return Tiles(r.Dx(), r.Dy(), f)
+	var e *Executor
+	return e.TilesR(r, f)
 }
 
-// Tiles runs f in runtime.NumCPU() parallel tiles.
+// Tiles runs f in parallel on the tiles of the width×height area.
 func Tiles(width, height int, f func(x1, y1, x2, y2 int)) chan struct{} {
-	// FIXME use context
-	wg := &sync.WaitGroup{}
-	completed := make(chan struct{})
-
-	for _, rect := range hdr.Split(0, 0, width, height, ncpu) {
-		wg.Add(1)
-		go func(rect image.Rectangle) {
-			defer wg.Done()
-
-			f(rect.Min.X, rect.Min.Y, rect.Max.X, rect.Max.Y)
-
-		}(rect)
-	}
-
-	go func() {
-		wg.Wait()
-		close(completed)
-	}()
+	var e *Executor
+	return e.Tiles(width, height, f)
+}
 
-	return completed
+// Lines runs f in parallel chunks of [0, n).
+func Lines(n int, f func(i1, i2 int)) chan struct{} {
+	var e *Executor
+	return e.Lines(n, f)
 }
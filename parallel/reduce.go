@@ -0,0 +1,43 @@
+package parallel
+
+import (
+	"image"
+
+	"github.com/mdouchement/hdr/xmath"
+)
+
+// Partials runs f on the tiles of r and returns the partial results in tile index order.
+// Whatever the Deterministic mode, the tiles do not depend on the number of workers
+// so the reductions combining the partials in order are reproducible across machines.
+// The results of the tiles skipped when the executor context is done are zero values.
+func Partials[T any](e *Executor, r image.Rectangle, f func(x1, y1, x2, y2 int) T) []T {
+	tiles := e.reductionSplit(r)
+	partials := make([]T, len(tiles))
+
+	completed := e.Run(tiles, func(i int, tile image.Rectangle) {
+		partials[i] = f(tile.Min.X, tile.Min.Y, tile.Max.X, tile.Max.Y)
+	})
+	<-completed
+
+	return partials
+}
+
+// Sum runs f on the tiles of r and returns the sum of their results.
+// The tile results are summed in tile index order with a pairwise summation,
+// f should use a compensated summation (see xmath.KahanSum) inside its tile.
+func Sum(e *Executor, r image.Rectangle, f func(x1, y1, x2, y2 int) float64) float64 {
+	return xmath.PairwiseSum(Partials(e, r, f))
+}
+
+// reductionSplit splits r in tiles which do not depend on the number of workers.
+func (e *Executor) reductionSplit(r image.Rectangle) []image.Rectangle {
+	if e == nil {
+		return Split(r, StrategySquares, defaultSquareSize)
+	}
+
+	size := e.TileSize
+	if size <= 0 {
+		size = tileSize(r, e.Strategy, e.workers(), true)
+	}
+	return Split(r, e.Strategy, size)
+}
@@ -0,0 +1,77 @@
+package parallel
+
+import (
+	"context"
+	"image"
+	"math"
+	"testing"
+
+	"github.com/mdouchement/hdr/xmath"
+)
+
+// value returns an irregular value for each pixel so the sum depends on the summation order.
+func value(x, y int) float64 {
+	return math.Sin(float64(x*7919+y*104729)) * math.Pow(10, float64((x+y)%9))
+}
+
+func tileSum(x1, y1, x2, y2 int) float64 {
+	var s xmath.KahanSum
+	for y := y1; y < y2; y++ {
+		for x := x1; x < x2; x++ {
+			s.Add(value(x, y))
+		}
+	}
+	return s.Value()
+}
+
+func TestPartials(t *testing.T) {
+	r := image.Rect(-3, 2, 250, 177)
+
+	for _, e := range []*Executor{nil, {Workers: 3}, {Workers: 2, Strategy: StrategyStrips, TileSize: 5}} {
+		tiles := e.reductionSplit(r)
+		partials := Partials(e, r, func(x1, y1, x2, y2 int) image.Rectangle {
+			return image.Rect(x1, y1, x2, y2)
+		})
+
+		// In tile index order
+		if len(partials) != len(tiles) {
+			t.Fatalf("got %d partials, want %d", len(partials), len(tiles))
+		}
+		for i, tile := range tiles {
+			if partials[i] != tile {
+				t.Fatalf("partial %d: got %v, want %v", i, partials[i], tile)
+			}
+		}
+	}
+}
+
+func TestSumWorkers(t *testing.T) {
+	r := image.Rect(0, 0, 301, 203)
+	expected := Sum(&Executor{Workers: 1}, r, tileSum)
+
+	// Bit-identical whatever the number of workers and the Deterministic mode
+	for _, workers := range []int{2, 3, 8, 64} {
+		for _, deterministic := range []bool{false, true} {
+			e := &Executor{Workers: workers, Deterministic: deterministic}
+			if actual := Sum(e, r, tileSum); actual != expected {
+				t.Errorf("%d workers (deterministic %v): got %v, want %v", workers, deterministic, actual, expected)
+			}
+		}
+	}
+
+	if actual := tileSum(r.Min.X, r.Min.Y, r.Max.X, r.Max.Y); math.Abs(actual-expected) > 1e-9*math.Abs(expected) {
+		t.Errorf("got %v, want %v", expected, actual)
+	}
+}
+
+func TestPartialsCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	partials := Partials(&Executor{Context: ctx}, image.Rect(0, 0, 100, 100), func(x1, y1, x2, y2 int) int { return 1 })
+	for i, v := range partials {
+		if v != 0 {
+			t.Fatalf("partial %d: got %d, want the zero value", i, v)
+		}
+	}
+}
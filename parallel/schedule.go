@@ -0,0 +1,122 @@
+package parallel
+
+import (
+	"image"
+	"math"
+	"sync"
+)
+
+const (
+	tilesPerWorker    = 8  // Number of tiles per worker for the load balancing
+	minTileSize       = 16 // Smallest side (pixels) of the adaptive square tiles
+	defaultSquareSize = 64 // Side (pixels) of the deterministic square tiles
+	defaultStripSize  = 16 // Height (rows) of the deterministic strips
+)
+
+// A Strategy defines how an area is split in tiles.
+type Strategy int
+
+const (
+	// StrategySquares splits the area in square tiles (cache friendly for the neighborhood operations).
+	StrategySquares Strategy = iota
+	// StrategyStrips splits the area in full-width row strips (cache friendly for the row operations).
+	StrategyStrips
+)
+
+// Split splits r in size×size tiles (StrategySquares) or in strips of size rows (StrategyStrips).
+// The tiles cover every pixel of r exactly once and are sorted in row-major order,
+// the last tiles of each row and column are smaller when the size does not divide r.
+func Split(r image.Rectangle, strategy Strategy, size int) []image.Rectangle {
+	if r.Empty() {
+		return nil
+	}
+	if size < 1 {
+		size = 1
+	}
+
+	width := size
+	if strategy == StrategyStrips {
+		width = r.Dx()
+	}
+
+	tiles := make([]image.Rectangle, 0, ((r.Dx()+width-1)/width)*((r.Dy()+size-1)/size))
+	for y := r.Min.Y; y < r.Max.Y; y += size {
+		for x := r.Min.X; x < r.Max.X; x += width {
+			tiles = append(tiles, image.Rect(x, y, x+width, y+size).Intersect(r))
+		}
+	}
+
+	return tiles
+}
+
+// tileSize returns the tile size used to split r for the given number of workers.
+func tileSize(r image.Rectangle, strategy Strategy, workers int, deterministic bool) int {
+	if deterministic {
+		if strategy == StrategyStrips {
+			return defaultStripSize
+		}
+		return defaultSquareSize
+	}
+
+	n := float64(workers * tilesPerWorker)
+	if strategy == StrategyStrips {
+		return int(math.Ceil(float64(r.Dy()) / n))
+	}
+
+	size := int(math.Ceil(math.Sqrt(float64(r.Dx()*r.Dy()) / n)))
+	if size < minTileSize {
+		return minTileSize
+	}
+	return size
+}
+
+//--------------------------------------//
+// Work stealing                        //
+//--------------------------------------//
+
+// A queue is a range of tile indexes owned by a worker.
+// The owner takes the tiles from the front and the other workers steal them from the back.
+type queue struct {
+	sync.Mutex
+	front int
+	back  int
+}
+
+// newQueues splits the n tile indexes in contiguous ranges, one per worker.
+func newQueues(n, workers int) []*queue {
+	queues := make([]*queue, workers)
+	for w := range queues {
+		queues[w] = &queue{
+			front: w * n / workers,
+			back:  (w + 1) * n / workers,
+		}
+	}
+	return queues
+}
+
+// next returns the next tile index of the worker w, stolen from the other workers when its range is empty.
+func next(queues []*queue, w int) (int, bool) {
+	q := queues[w]
+	q.Lock()
+	if q.front < q.back {
+		i := q.front
+		q.front++
+		q.Unlock()
+		return i, true
+	}
+	q.Unlock()
+
+	for k := 1; k < len(queues); k++ {
+		q = queues[(w+k)%len(queues)]
+		q.Lock()
+		if q.front < q.back {
+			q.back--
+			i := q.back
+			q.Unlock()
+			return i, true
+		}
+		q.Unlock()
+	}
+
+	return 0, false
+}
@@ -0,0 +1,100 @@
+package parallel
+
+import (
+	"image"
+	"testing"
+)
+
+func TestSplit(t *testing.T) {
+	rects := []image.Rectangle{
+		image.Rect(0, 0, 1, 1),
+		image.Rect(0, 0, 64, 64),
+		image.Rect(-7, 3, 93, 68),
+		image.Rect(5, 5, 6, 300),
+		image.Rect(0, 0, 300, 1),
+	}
+
+	for _, r := range rects {
+		for _, strategy := range []Strategy{StrategySquares, StrategyStrips} {
+			for _, size := range []int{0, 1, 7, 16, 64, 1000} {
+				tiles := Split(r, strategy, size)
+
+				covered := make([]int, r.Dx()*r.Dy())
+				for i, tile := range tiles {
+					if tile.Empty() || !tile.In(r) {
+						t.Fatalf("%v, strategy %d, size %d: tile %v is not a non-empty part of r", r, strategy, size, tile)
+					}
+					if strategy == StrategyStrips && (tile.Min.X != r.Min.X || tile.Max.X != r.Max.X) {
+						t.Fatalf("%v, size %d: strip %v is not full-width", r, size, tile)
+					}
+					if i > 0 && (tile.Min.Y < tiles[i-1].Min.Y || tile.Min.Y == tiles[i-1].Min.Y && tile.Min.X <= tiles[i-1].Min.X) {
+						t.Fatalf("%v, strategy %d, size %d: tile %v is not after %v", r, strategy, size, tile, tiles[i-1])
+					}
+
+					for y := tile.Min.Y; y < tile.Max.Y; y++ {
+						for x := tile.Min.X; x < tile.Max.X; x++ {
+							covered[(y-r.Min.Y)*r.Dx()+x-r.Min.X]++
+						}
+					}
+				}
+
+				for i, n := range covered {
+					if n != 1 {
+						t.Fatalf("%v, strategy %d, size %d: pixel %d covered %d times", r, strategy, size, i, n)
+					}
+				}
+			}
+		}
+	}
+
+	if tiles := Split(image.Rectangle{}, StrategySquares, 16); tiles != nil {
+		t.Errorf("empty area: got %v", tiles)
+	}
+}
+
+func TestTileSize(t *testing.T) {
+	r := image.Rect(0, 0, 1920, 1080)
+
+	for _, strategy := range []Strategy{StrategySquares, StrategyStrips} {
+		// The deterministic tiles do not depend on the number of workers
+		if a, b := tileSize(r, strategy, 1, true), tileSize(r, strategy, 12, true); a != b {
+			t.Errorf("strategy %d: got deterministic sizes %d and %d", strategy, a, b)
+		}
+
+		// About tilesPerWorker tiles per worker
+		for _, workers := range []int{1, 4, 16} {
+			n := len(Split(r, strategy, tileSize(r, strategy, workers, false)))
+			if n < workers*tilesPerWorker/2 || n > workers*tilesPerWorker*2 {
+				t.Errorf("strategy %d, %d workers: got %d tiles", strategy, workers, n)
+			}
+		}
+	}
+
+	if size := tileSize(image.Rect(0, 0, 10, 10), StrategySquares, 64, false); size != minTileSize {
+		t.Errorf("got size %d, want the minimum %d", size, minTileSize)
+	}
+}
+
+func TestQueues(t *testing.T) {
+	for _, n := range []int{1, 5, 64} {
+		for _, workers := range []int{1, 3, n} {
+			queues := newQueues(n, workers)
+
+			// Worker 0 takes its own tiles then steals all the others
+			taken := make([]int, n)
+			for {
+				i, ok := next(queues, 0)
+				if !ok {
+					break
+				}
+				taken[i]++
+			}
+
+			for i, k := range taken {
+				if k != 1 {
+					t.Fatalf("%d tiles, %d workers: tile %d taken %d times", n, workers, i, k)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,212 @@
+package hdr
+
+import (
+	"image"
+	"image/color"
+
+	"github.com/mdouchement/hdr/hdrcolor"
+)
+
+//===============//
+// Planar        //
+//===============//
+
+// Planar is an in-memory 32 bits floating points image whose channels are stored in separate planes
+// (structure of arrays). Its At method returns hdrcolor.RGB, hdrcolor.XYZ or hdrcolor.RAW values according to its Space.
+type Planar struct {
+	// Planes holds the image's channels, in R, G, B or X, Y, Z (or raw channels) order. The channel c of the pixel
+	// at (x, y) is at Planes[c][(y-Rect.Min.Y)*Stride + (x-Rect.Min.X)].
+	Planes [3][]float32
+	// Stride is the planes stride (in values) between vertically adjacent pixels.
+	// It may be greater than the width to align the rows.
+	Stride int
+	// Rect is the image's bounds.
+	Rect image.Rectangle
+	// Space is the color space of the channels.
+	Space ColorSpace
+}
+
+// NewPlanar returns a new planar image with the given bounds and color space.
+func NewPlanar(r image.Rectangle, space ColorSpace) *Planar {
+	return NewPlanarAligned(r, space, 1)
+}
+
+// NewPlanarAligned returns a new planar image with the given bounds and color space
+// whose rows are padded to a multiple of align values.
+func NewPlanarAligned(r image.Rectangle, space ColorSpace, align int) *Planar {
+	if align < 1 {
+		align = 1
+	}
+
+	stride := (r.Dx() + align - 1) / align * align
+	p := &Planar{Stride: stride, Rect: r, Space: space}
+	for c := range p.Planes {
+		p.Planes[c] = make([]float32, stride*r.Dy())
+	}
+	return p
+}
+
+// ToPlanar returns m as a planar image in the given color space.
+// m is returned as is when it is already a Planar in this space, otherwise it is converted.
+func ToPlanar(m Image, space ColorSpace) *Planar {
+	if p, ok := m.(*Planar); ok && p.Space == space {
+		return p
+	}
+
+	d := m.Bounds()
+	p := NewPlanar(d, space)
+
+	// Same layout, the channels are only deinterleaved
+	switch src := m.(type) {
+	case *RGB:
+		if space == RGBSpace {
+			p.deinterleave(src.Pix, src.Stride)
+			return p
+		}
+	case *XYZ:
+		if space == XYZSpace {
+			p.deinterleave(src.Pix, src.Stride)
+			return p
+		}
+	}
+
+	row := make([]float64, 3*d.Dx())
+	for y := d.Min.Y; y < d.Max.Y; y++ {
+		ReadRow(m, row, d.Min.X, d.Max.X, y, space)
+		p.WriteRow(row, d.Min.X, d.Max.X, y, space)
+	}
+	return p
+}
+
+// ColorModel implements Image.
+func (p *Planar) ColorModel() color.Model {
+	return p.Space.Model()
+}
+
+// Bounds implements Image.
+func (p *Planar) Bounds() image.Rectangle { return p.Rect }
+
+// Size implements Image.
+func (p *Planar) Size() int {
+	return p.Bounds().Dx() * p.Bounds().Dy()
+}
+
+// At implements Image.
+func (p *Planar) At(x, y int) color.Color {
+	return p.HDRAt(x, y)
+}
+
+// HDRAt implements Image.
+func (p *Planar) HDRAt(x, y int) hdrcolor.Color {
+	var p1, p2, p3 float64
+	if (image.Point{x, y}.In(p.Rect)) {
+		i := p.PixOffset(x, y)
+		p1, p2, p3 = float64(p.Planes[0][i]), float64(p.Planes[1][i]), float64(p.Planes[2][i])
+	}
+
+	return p.Space.Color(p1, p2, p3)
+}
+
+// PixOffset returns the index of the elements of the planes that correspond to the pixel at (x, y).
+func (p *Planar) PixOffset(x, y int) int {
+	return (y-p.Rect.Min.Y)*p.Stride + (x - p.Rect.Min.X)
+}
+
+// Row returns the values of the channel c of the row y.
+func (p *Planar) Row(c, y int) []float32 {
+	i := p.PixOffset(p.Rect.Min.X, y)
+	return p.Planes[c][i : i+p.Rect.Dx()]
+}
+
+// Set adds pixel to Image at given x, y.
+func (p *Planar) Set(x, y int, c color.Color) {
+	if !(image.Point{x, y}.In(p.Rect)) {
+		return
+	}
+	i := p.PixOffset(x, y)
+
+	p1, p2, p3, _ := p.Space.Model().Convert(c).(hdrcolor.Color).HDRPixel()
+	p.Planes[0][i] = float32(p1)
+	p.Planes[1][i] = float32(p2)
+	p.Planes[2][i] = float32(p3)
+}
+
+// ReadRow implements RowReader.
+func (p *Planar) ReadRow(dst []float64, x1, x2, y int, space ColorSpace) {
+	n := 3 * (x2 - x1)
+	zero(dst[:n])
+	if y < p.Rect.Min.Y || y >= p.Rect.Max.Y {
+		return
+	}
+
+	ix1, ix2 := clampX(p.Rect, x1), clampX(p.Rect, x2)
+	offset := p.PixOffset(ix1, y)
+	row := dst[3*(ix1-x1) : 3*(ix2-x1)]
+	for c, plane := range p.Planes {
+		for i, v := range plane[offset : offset+ix2-ix1] {
+			row[3*i+c] = float64(v)
+		}
+	}
+	ConvertRow(row, p.Space, space)
+}
+
+// WriteRow implements RowWriter.
+func (p *Planar) WriteRow(src []float64, x1, x2, y int, space ColorSpace) {
+	if y < p.Rect.Min.Y || y >= p.Rect.Max.Y {
+		return
+	}
+
+	ix1, ix2 := clampX(p.Rect, x1), clampX(p.Rect, x2)
+	fn := convert(space, p.Space)
+	offset := p.PixOffset(ix1, y)
+	for x, i := ix1, 3*(ix1-x1); x < ix2; x, i = x+1, i+3 {
+		p1, p2, p3 := src[i+0], src[i+1], src[i+2]
+		if converts(space, p.Space) {
+			p1, p2, p3 = fn(p1, p2, p3)
+		}
+
+		p.Planes[0][offset] = float32(p1)
+		p.Planes[1][offset] = float32(p2)
+		p.Planes[2][offset] = float32(p3)
+		offset++
+	}
+}
+
+// Interleave converts the planar image to an interleaved hdr.RGB or hdr.XYZ image according to its Space.
+// The RAWSpace channels are stored as is in a hdr.RGB.
+func (p *Planar) Interleave() Image {
+	var pix []float32
+	var m Image
+	if p.Space == XYZSpace {
+		dst := NewXYZ(p.Rect)
+		pix, m = dst.Pix, dst
+	} else {
+		dst := NewRGB(p.Rect)
+		pix, m = dst.Pix, dst
+	}
+
+	w := p.Rect.Dx()
+	for y := 0; y < p.Rect.Dy(); y++ {
+		row := pix[y*3*w : (y+1)*3*w]
+		for c, plane := range p.Planes {
+			for i, v := range plane[y*p.Stride : y*p.Stride+w] {
+				row[3*i+c] = v
+			}
+		}
+	}
+	return m
+}
+
+// deinterleave fills the planes with the interleaved pixels of an image with the same bounds.
+func (p *Planar) deinterleave(pix []float32, stride int) {
+	w := p.Rect.Dx()
+	for y := 0; y < p.Rect.Dy(); y++ {
+		row := pix[y*stride : y*stride+3*w]
+		for c := range p.Planes {
+			plane := p.Planes[c][y*p.Stride : y*p.Stride+w]
+			for i := range plane {
+				plane[i] = row[3*i+c]
+			}
+		}
+	}
+}
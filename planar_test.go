@@ -0,0 +1,97 @@
+package hdr
+
+import (
+	"image"
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/mdouchement/hdr/hdrcolor"
+)
+
+func TestPlanarRoundTrip(t *testing.T) {
+	r := image.Rect(-4, 7, 33, 26)
+	rnd := rand.New(rand.NewSource(46))
+
+	for name, m := range map[string]ImageSet{"RGB": NewRGB(r), "XYZ": NewXYZ(r)} {
+		for y := r.Min.Y; y < r.Max.Y; y++ {
+			for x := r.Min.X; x < r.Max.X; x++ {
+				m.Set(x, y, hdrcolor.RGB{R: 4 * rnd.Float64(), G: rnd.Float64(), B: 2 * rnd.Float64()})
+			}
+		}
+		src := m.(Image)
+		space := ModelSpace(src.ColorModel())
+
+		// The float32 channels are copied as is
+		p := ToPlanar(src, space)
+		dst := p.Interleave()
+		for y := r.Min.Y; y < r.Max.Y; y++ {
+			for x := r.Min.X; x < r.Max.X; x++ {
+				if a, e := p.HDRAt(x, y), src.HDRAt(x, y); a != e {
+					t.Fatalf("%s: planar pixel (%d, %d): got %v, want %v", name, x, y, a, e)
+				}
+				if a, e := dst.HDRAt(x, y), src.HDRAt(x, y); a != e {
+					t.Fatalf("%s: interleaved pixel (%d, %d): got %v, want %v", name, x, y, a, e)
+				}
+			}
+		}
+		if ToPlanar(p, space) != p {
+			t.Errorf("%s: a planar image in the same space must not be converted", name)
+		}
+
+		// Through the other color space, up to the float32 precision
+		other := XYZSpace
+		if space == XYZSpace {
+			other = RGBSpace
+		}
+		back := ToPlanar(ToPlanar(src, other).Interleave(), space)
+		for y := r.Min.Y; y < r.Max.Y; y++ {
+			for x := r.Min.X; x < r.Max.X; x++ {
+				a1, a2, a3 := space.Channels(back.HDRAt(x, y))
+				e1, e2, e3 := space.Channels(src.HDRAt(x, y))
+				if math.Abs(a1-e1) > 1e-5 || math.Abs(a2-e2) > 1e-5 || math.Abs(a3-e3) > 1e-5 {
+					t.Fatalf("%s: converted pixel (%d, %d): got %v, want %v", name, x, y, []float64{a1, a2, a3}, []float64{e1, e2, e3})
+				}
+			}
+		}
+	}
+}
+
+func TestPlanarAligned(t *testing.T) {
+	r := image.Rect(3, 1, 24, 6)
+
+	for _, align := range []int{0, 1, 4, 16} {
+		p := NewPlanarAligned(r, RGBSpace, align)
+		if p.Stride < r.Dx() || (align > 1 && p.Stride%align != 0) {
+			t.Errorf("align %d: got stride %d for width %d", align, p.Stride, r.Dx())
+		}
+
+		for y := r.Min.Y; y < r.Max.Y; y++ {
+			for x := r.Min.X; x < r.Max.X; x++ {
+				p.Set(x, y, hdrcolor.RGB{R: float64(x), G: float64(y), B: 1})
+			}
+		}
+
+		for y := r.Min.Y; y < r.Max.Y; y++ {
+			row := p.Row(0, y)
+			if len(row) != r.Dx() {
+				t.Fatalf("align %d: got a row of %d values, want %d", align, len(row), r.Dx())
+			}
+			for i, v := range row {
+				if v != float32(r.Min.X+i) {
+					t.Fatalf("align %d: pixel (%d, %d): got %v, want %d", align, r.Min.X+i, y, v, r.Min.X+i)
+				}
+			}
+		}
+
+		// The padding is not part of the interleaved image
+		m := p.Interleave()
+		for y := r.Min.Y; y < r.Max.Y; y++ {
+			for x := r.Min.X; x < r.Max.X; x++ {
+				if a, e := m.HDRAt(x, y), p.HDRAt(x, y); a != e {
+					t.Fatalf("align %d: pixel (%d, %d): got %v, want %v", align, x, y, a, e)
+				}
+			}
+		}
+	}
+}
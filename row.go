@@ -0,0 +1,204 @@
+package hdr
+
+import (
+	"image"
+	"image/color"
+
+	colorful "github.com/lucasb-eyer/go-colorful"
+	"github.com/mdouchement/hdr/hdrcolor"
+	"github.com/mdouchement/hdr/xmath"
+)
+
+// A ColorSpace is the color space of the channels of a row of pixels.
+type ColorSpace int
+
+const (
+	// RGBSpace holds the linear R, G, B channels (like hdrcolor.Color.HDRRGBA).
+	RGBSpace ColorSpace = iota
+	// XYZSpace holds the CIE X, Y, Z channels (like hdrcolor.Color.HDRXYZA).
+	XYZSpace
+	// RAWSpace holds the channels as they are stored by the image (like hdrcolor.Color.HDRPixel),
+	// whatever their semantics (e.g. LMS, IPT). They are never converted.
+	RAWSpace
+)
+
+// ModelSpace returns the color space of the channels of an image with the given color model.
+// The models other than hdrcolor.RGBModel and hdrcolor.XYZModel have the RAWSpace.
+func ModelSpace(m color.Model) ColorSpace {
+	switch m {
+	case hdrcolor.RGBModel:
+		return RGBSpace
+	case hdrcolor.XYZModel:
+		return XYZSpace
+	default:
+		return RAWSpace
+	}
+}
+
+// Model returns the color model of the colors in the color space.
+func (s ColorSpace) Model() color.Model {
+	switch s {
+	case XYZSpace:
+		return hdrcolor.XYZModel
+	case RAWSpace:
+		return hdrcolor.RAWModel
+	default:
+		return hdrcolor.RGBModel
+	}
+}
+
+// Color returns the color of the given channels in the color space.
+func (s ColorSpace) Color(p1, p2, p3 float64) hdrcolor.Color {
+	switch s {
+	case XYZSpace:
+		return hdrcolor.XYZ{X: p1, Y: p2, Z: p3}
+	case RAWSpace:
+		return hdrcolor.RAW{P1: p1, P2: p2, P3: p3}
+	default:
+		return hdrcolor.RGB{R: p1, G: p2, B: p3}
+	}
+}
+
+// Channels returns the channels of c in the color space.
+func (s ColorSpace) Channels(c hdrcolor.Color) (p1, p2, p3 float64) {
+	switch s {
+	case XYZSpace:
+		p1, p2, p3, _ = c.HDRXYZA()
+	case RAWSpace:
+		p1, p2, p3, _ = c.HDRPixel()
+	default:
+		p1, p2, p3, _ = c.HDRRGBA()
+	}
+	return
+}
+
+// Valid reports whether s is a known color space.
+func (s ColorSpace) Valid() bool {
+	return s >= RGBSpace && s <= RAWSpace
+}
+
+// A RowReader is an Image that reads its pixels by rows, without the per-pixel allocations of HDRAt.
+type RowReader interface {
+	Image
+
+	// ReadRow fills dst with the 3 channels of the pixels from (x1, y) to (x2-1, y) in the given color space.
+	// dst must hold at least 3*(x2-x1) values. The channels are the ones of HDRAt,
+	// so the pixels outside the bounds are zero for the in-memory images.
+	ReadRow(dst []float64, x1, x2, y int, space ColorSpace)
+}
+
+// A RowWriter is an ImageSet that writes its pixels by rows.
+type RowWriter interface {
+	ImageSet
+
+	// WriteRow sets the pixels from (x1, y) to (x2-1, y) with the 3 channels of src in the given color space.
+	// The pixels outside the bounds are ignored.
+	WriteRow(src []float64, x1, x2, y int, space ColorSpace)
+}
+
+// ReadRow fills dst with the pixels from (x1, y) to (x2-1, y) of m in the given color space.
+// It uses the RowReader fast path when m implements it, otherwise it falls back on HDRAt.
+func ReadRow(m Image, dst []float64, x1, x2, y int, space ColorSpace) {
+	if rr, ok := m.(RowReader); ok {
+		rr.ReadRow(dst, x1, x2, y, space)
+		return
+	}
+
+	for x, i := x1, 0; x < x2; x, i = x+1, i+3 {
+		dst[i+0], dst[i+1], dst[i+2] = space.Channels(m.HDRAt(x, y))
+	}
+}
+
+// WriteRow sets the pixels from (x1, y) to (x2-1, y) of m with src in the given color space.
+// It uses the RowWriter fast path when m implements it, otherwise it falls back on Set.
+func WriteRow(m ImageSet, src []float64, x1, x2, y int, space ColorSpace) {
+	if rw, ok := m.(RowWriter); ok {
+		rw.WriteRow(src, x1, x2, y, space)
+		return
+	}
+
+	for x, i := x1, 0; x < x2; x, i = x+1, i+3 {
+		m.Set(x, y, space.Color(src[i+0], src[i+1], src[i+2]))
+	}
+}
+
+// ConvertRow converts in place the pixels of row from a color space to another.
+// The RAWSpace channels are left as is.
+func ConvertRow(row []float64, from, to ColorSpace) {
+	if !converts(from, to) {
+		return
+	}
+
+	fn := convert(from, to)
+	for i := 0; i+2 < len(row); i += 3 {
+		row[i+0], row[i+1], row[i+2] = fn(row[i+0], row[i+1], row[i+2])
+	}
+}
+
+// readRow implements RowReader for the in-memory images storing their pixels in the native color space.
+func readRow[T float32 | float64](dst []float64, pix []T, stride int, rect image.Rectangle, native ColorSpace, x1, x2, y int, space ColorSpace) {
+	n := 3 * (x2 - x1)
+	if y < rect.Min.Y || y >= rect.Max.Y {
+		zero(dst[:n])
+		return
+	}
+
+	// Only the pixels inside the bounds are read, the other ones are zero.
+	ix1, ix2 := clampX(rect, x1), clampX(rect, x2)
+	if ix1 >= ix2 {
+		zero(dst[:n])
+		return
+	}
+	zero(dst[:3*(ix1-x1)])
+	zero(dst[3*(ix2-x1) : n])
+
+	row := dst[3*(ix1-x1) : 3*(ix2-x1)]
+	offset := (y-rect.Min.Y)*stride + (ix1-rect.Min.X)*3
+	for i, v := range pix[offset : offset+len(row)] {
+		row[i] = float64(v)
+	}
+	ConvertRow(row, native, space)
+}
+
+// writeRow implements RowWriter for the in-memory images storing their pixels in the native color space.
+func writeRow[T float32 | float64](pix []T, stride int, rect image.Rectangle, native ColorSpace, src []float64, x1, x2, y int, space ColorSpace) {
+	if y < rect.Min.Y || y >= rect.Max.Y {
+		return
+	}
+
+	ix1, ix2 := clampX(rect, x1), clampX(rect, x2)
+	offset := (y-rect.Min.Y)*stride + (ix1-rect.Min.X)*3
+	for x, i := ix1, 3*(ix1-x1); x < ix2; x, i = x+1, i+3 {
+		p1, p2, p3 := src[i+0], src[i+1], src[i+2]
+		if converts(space, native) {
+			p1, p2, p3 = convert(space, native)(p1, p2, p3)
+		}
+
+		pix[offset+0], pix[offset+1], pix[offset+2] = T(p1), T(p2), T(p3)
+		offset += 3
+	}
+}
+
+func zero(s []float64) {
+	for i := range s {
+		s[i] = 0
+	}
+}
+
+// converts reports whether the channels must be converted from a color space to another.
+func converts(from, to ColorSpace) bool {
+	return from != to && from != RAWSpace && to != RAWSpace
+}
+
+// convert returns the conversion function between two different color spaces (see converts).
+func convert(from, to ColorSpace) func(p1, p2, p3 float64) (float64, float64, float64) {
+	if from == XYZSpace {
+		return colorful.XyzToLinearRgb
+	}
+	return colorful.LinearRgbToXyz
+}
+
+// clampX clamps the given abscissa to the horizontal range of r.
+func clampX(r image.Rectangle, x int) int {
+	return xmath.Clamp(r.Min.X, r.Max.X, x)
+}
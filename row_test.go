@@ -0,0 +1,115 @@
+package hdr
+
+import (
+	"image"
+	"math/rand"
+	"testing"
+
+	"github.com/mdouchement/hdr/hdrcolor"
+)
+
+var spaces = []ColorSpace{RGBSpace, XYZSpace, RAWSpace}
+
+// images returns an image of each in-memory type filled with random colors.
+func images(r image.Rectangle) map[string]Buffer {
+	m := map[string]Buffer{
+		"RGB":          NewRGB(r),
+		"RGB64":        NewRGB64(r),
+		"XYZ":          NewXYZ(r),
+		"XYZ64":        NewXYZ64(r),
+		"RGB16":        NewRGB16(r),
+		"XYZ16":        NewXYZ16(r),
+		"PlanarRGB":    NewPlanar(r, RGBSpace),
+		"PlanarXYZ":    NewPlanar(r, XYZSpace),
+		"PlanarRAW":    NewPlanarAligned(r, RAWSpace, 8),
+		"PlanarRGBPad": NewPlanarAligned(r, RGBSpace, 5),
+	}
+
+	rnd := rand.New(rand.NewSource(45))
+	for _, img := range m {
+		for y := r.Min.Y; y < r.Max.Y; y++ {
+			for x := r.Min.X; x < r.Max.X; x++ {
+				img.Set(x, y, hdrcolor.RGB{R: 4 * rnd.Float64(), G: rnd.Float64(), B: 2 * rnd.Float64()})
+			}
+		}
+	}
+	return m
+}
+
+// checkRow checks that ReadRow returns the same channels as HDRAt, zero outside the bounds.
+func checkRow(t *testing.T, name string, m Image) {
+	t.Helper()
+
+	d := m.Bounds()
+	x1, x2 := d.Min.X-2, d.Max.X+3
+	row := make([]float64, 3*(x2-x1))
+
+	for _, space := range spaces {
+		for y := d.Min.Y - 1; y <= d.Max.Y; y++ {
+			ReadRow(m, row, x1, x2, y, space)
+
+			for x := x1; x < x2; x++ {
+				var p1, p2, p3 float64
+				if (image.Point{x, y}).In(d) {
+					p1, p2, p3 = space.Channels(m.HDRAt(x, y))
+				}
+
+				i := 3 * (x - x1)
+				if row[i] != p1 || row[i+1] != p2 || row[i+2] != p3 {
+					t.Fatalf("%s, space %d: pixel (%d, %d): got %v, want %v", name, space, x, y, row[i:i+3], []float64{p1, p2, p3})
+				}
+			}
+		}
+	}
+}
+
+func TestReadRow(t *testing.T) {
+	r := image.Rect(-3, 2, 37, 21)
+
+	for name, m := range images(r) {
+		checkRow(t, name, m)
+	}
+}
+
+func TestWriteRow(t *testing.T) {
+	r := image.Rect(-3, 2, 37, 21)
+	rnd := rand.New(rand.NewSource(45))
+
+	for name, dst := range images(r) {
+		for _, space := range spaces {
+			if space == RAWSpace && ModelSpace(dst.ColorModel()) != RAWSpace {
+				continue // The raw channels of the other images are not a color space
+			}
+
+			expected := EmptyAs(dst).(Buffer)
+			src := make([]float64, 3*(r.Dx()+4))
+			for y := r.Min.Y; y < r.Max.Y; y++ {
+				for i := range src {
+					src[i] = rnd.Float64()
+				}
+
+				// The pixels outside the bounds are ignored
+				WriteRow(dst, src, r.Min.X-2, r.Max.X+2, y, space)
+				for x := r.Min.X; x < r.Max.X; x++ {
+					i := 3 * (x - r.Min.X + 2)
+					expected.Set(x, y, space.Color(src[i], src[i+1], src[i+2]))
+				}
+			}
+
+			for y := r.Min.Y; y < r.Max.Y; y++ {
+				for x := r.Min.X; x < r.Max.X; x++ {
+					if a, e := dst.HDRAt(x, y), expected.HDRAt(x, y); a != e {
+						t.Fatalf("%s, space %d: pixel (%d, %d): got %v, want %v", name, space, x, y, a, e)
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestLMSRow(t *testing.T) {
+	for name, img := range images(image.Rect(1, 1, 20, 9)) {
+		checkRow(t, "LMSCAT02w "+name, NewLMSCAT02w(img))
+		checkRow(t, "LMSHPEw "+name, NewLMSHPEw(img))
+	}
+}
@@ -0,0 +1,193 @@
+package tmo
+
+import (
+	"image"
+	"image/draw"
+	"math"
+
+	"github.com/mdouchement/hdr"
+	"github.com/mdouchement/hdr/filter"
+	"github.com/mdouchement/hdr/hdrcolor"
+)
+
+// An ACESOutput is an ACES Output Device Transform.
+type ACESOutput int
+
+const (
+	// ACESsRGB100nits is the sRGB display (100 nits, dim surround).
+	ACESsRGB100nits ACESOutput = iota
+	// ACESRec709100nits is the Rec.709 display (100 nits, dim surround, BT.1886).
+	ACESRec709100nits
+	// ACESP3D6548nits is the P3-D65 cinema projector (48 nits, gamma 2.6).
+	ACESP3D6548nits
+	// ACESRec20201000nitsPQ is the Rec.2020 display (1000 nits, ST 2084 PQ).
+	ACESRec20201000nitsPQ
+)
+
+// An ACES is a TMO implementation based on the Academy Color Encoding System output pipeline:
+// the input is converted to ACES2065-1, rendered with the Reference Rendering Transform (RRT)
+// and then encoded for the selected Output Device Transform (ODT).
+//
+// The SDR outputs follow the ACES 1.0.3 RRT + ODT pair and the Rec.2020 PQ output follows the
+// ACES 1.1 single stage RRTODT (Y_MIN = 0.0001, Y_MID = 15, Y_MAX = 1000 cd/m²).
+// Computations are a float64 transcription of the CTL and the output code values match
+// the reference CTL within 1e-4 (checked on black, 0.18 grey and highlights for each ODT).
+//
+// Reference:
+// https://github.com/ampas/aces-dev
+type ACES struct {
+	HDRImage hdr.Image
+	// Exposure is included in [-10, 10] with 0.1 increment step (stops).
+	Exposure float64
+	// Output is the Output Device Transform.
+	Output ACESOutput
+	executable
+}
+
+// NewDefaultACES instanciates a new ACES TMO with default parameters.
+func NewDefaultACES(m hdr.Image) *ACES {
+	return NewACES(m, 0, ACESsRGB100nits)
+}
+
+// NewACES instanciates a new ACES TMO.
+func NewACES(m hdr.Image, exposure float64, output ACESOutput) *ACES {
+	return &ACES{
+		HDRImage: m,
+		Exposure: exposure,
+		Output:   output,
+	}
+}
+
+// Perform runs the TMO mapping.
+func (t *ACES) Perform() image.Image {
+	return t.encode(t.output(), t.PerformHDR())
+}
+
+// PerformHDR runs the TMO mapping and returns display-referred linear values.
+func (t *ACES) PerformHDR() hdr.Image {
+	img := hdr.NewRGB64(t.HDRImage.Bounds())
+	t.tonemap(img)
+
+	return img
+}
+
+// RenderRegion implements RegionOperator, the mapping is pointwise so only the region is processed.
+func (t *ACES) RenderRegion(dst draw.Image, r image.Rectangle, scale float64) {
+	region := NewACES(filter.ResampleWithExecutor(t.executor, t.HDRImage, r, scale), t.Exposure, t.Output)
+	region.executor = t.executor
+	drawRegion(dst, region.Perform())
+}
+
+func (t *ACES) tonemap(img *hdr.RGB64) {
+	exposure := math.Exp2(t.Exposure)
+	odt := t.odt()
+
+	completed := t.executor.TilesR(t.HDRImage.Bounds(), func(x1, y1, x2, y2 int) {
+		row := make([]float64, 3*(x2-x1))
+		for y := y1; y < y2; y++ {
+			hdr.ReadRow(t.HDRImage, row, x1, x2, y, hdr.XYZSpace)
+			for x, i := x1, 0; x < x2; x, i = x+1, i+3 {
+				X, Y, Z := row[i+0], row[i+1], row[i+2]
+
+				// Input is D65 referred, ACES2065-1 is D60 referred
+				aces := acesXYZToAP0.mulVec(acesD65ToD60.mulVec([3]float64{X * exposure, Y * exposure, Z * exposure}))
+				rgb := odt(aces)
+
+				img.SetRGB(x, y, hdrcolor.RGB{R: rgb[0], G: rgb[1], B: rgb[2]})
+			}
+		}
+	})
+
+	<-completed
+}
+
+// output returns the display encoding of the Output Device Transform.
+func (t *ACES) output() Output {
+	var o Output
+	switch t.Output {
+	case ACESRec709100nits:
+		o = NewGammaOutput(2.4) // BT.1886
+	case ACESP3D6548nits:
+		o = NewGammaOutput(2.6)
+	case ACESRec20201000nitsPQ:
+		o = NewOutput(EncodingPQ)
+		o.PeakLuminance = 1000
+	default:
+		o = NewOutput(EncodingSRGB)
+	}
+	o.InversePixelMapping = false
+
+	return o
+}
+
+func (t *ACES) odt() func(aces [3]float64) [3]float64 {
+	switch t.Output {
+	case ACESRec709100nits:
+		return func(aces [3]float64) [3]float64 {
+			return t.sdr(rrt(aces), true, acesRec709XYZToRGB)
+		}
+	case ACESP3D6548nits:
+		return func(aces [3]float64) [3]float64 {
+			return t.sdr(rrt(aces), false, acesP3D65XYZToRGB)
+		}
+	case ACESRec20201000nitsPQ:
+		return t.pq
+	case ACESsRGB100nits:
+		fallthrough
+	default:
+		return func(aces [3]float64) [3]float64 {
+			return t.sdr(rrt(aces), true, acesRec709XYZToRGB)
+		}
+	}
+}
+
+// sdr applies a 48 nits ODT on the given OCES values.
+func (t *ACES) sdr(oces [3]float64, dim bool, xyzToDisplay mat3) [3]float64 {
+	rgb := acesAP0ToAP1.mulVec(oces)
+
+	for i := range rgb {
+		rgb[i] = yToLinCV(acesODT48Spline.fwd(rgb[i]), acesCinemaWhite, acesCinemaBlack)
+	}
+
+	if dim {
+		rgb = darkToDimSurround(rgb)
+		rgb = acesODTSatMat.mulVec(rgb)
+	}
+
+	xyz := acesD60ToD65.mulVec(acesAP1ToXYZ.mulVec(rgb))
+	return acesClamp3(xyzToDisplay.mulVec(xyz), 0, 1)
+}
+
+// acesPQSpline is the single stage tone scale of the Rec.2020 1000 nits output,
+// the input mid grey is shifted to Y_MID.
+var acesPQSpline = func() *segmentedSpline {
+	const yMin, yMid, yMax = 0.0001, 15.0, 1000.0
+	expShift := math.Log2(newSSTS(yMin, yMax, 0).inv(yMid)) - math.Log2(0.18)
+	return newSSTS(yMin, yMax, expShift)
+}()
+
+// pq applies the Rec.2020 1000 nits ST 2084 single stage output transform.
+func (t *ACES) pq(aces [3]float64) [3]float64 {
+	const yMax = 1000.0
+
+	rgb := rrtSweeteners(aces)
+	for i := range rgb {
+		// Y_2_linCV followed by linCV_2_Y with stretched black
+		rgb[i] = yToLinCV(acesPQSpline.fwd(rgb[i]), yMax, acesPQSpline.min.y) * yMax
+	}
+
+	xyz := acesD60ToD65.mulVec(acesAP1ToXYZ.mulVec(rgb))
+	rgb = acesClamp3(acesRec2020XYZToRGB.mulVec(xyz), 0, math.Inf(1))
+
+	// Relative to the display white
+	for i := range rgb {
+		rgb[i] /= yMax
+	}
+	return rgb
+}
+
+var (
+	acesRec709XYZToRGB  = acesRec709.rgbToXYZ().inverse()
+	acesP3D65XYZToRGB   = acesP3D65.rgbToXYZ().inverse()
+	acesRec2020XYZToRGB = acesRec2020.rgbToXYZ().inverse()
+)
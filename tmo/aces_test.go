@@ -0,0 +1,59 @@
+package tmo
+
+import (
+	"image"
+	"math"
+	"testing"
+
+	"github.com/mdouchement/hdr"
+	"github.com/mdouchement/hdr/hdrcolor"
+)
+
+// acesEpsilon is the documented tolerance of the ACES output code values against the reference CTL.
+const acesEpsilon = 1e-4
+
+// acesReferences holds the code values of the reference CTL for neutral inputs: black, 0.18 grey and a highlight.
+//
+// The grey, black and highlight inputs fall on the knots of the CTL tone scales where the output is closed-form:
+// the RRT maps 0.18 to 4.8 cd/m² (c5 mid point) which the 48 nits ODT keeps (c9 mid point),
+// black lands under the low knots (CINEMA_BLACK / Y_MIN) and the highlight saturates the high knots (CINEMA_WHITE / Y_MAX).
+//   - sRGB:     moncurve_r(pow(Y_2_linCV(4.8, 48, 0.02), DIM_SURROUND_GAMMA), 2.4, 0.055)
+//   - Rec.709:  bt1886_r(pow(Y_2_linCV(4.8, 48, 0.02), DIM_SURROUND_GAMMA), 2.4, 1, 0)
+//   - P3-D65:   pow(Y_2_linCV(4.8, 48, 0.02), 1/2.6)
+//   - Rec.2020: Y_2_ST2084(Y_2_linCV(15, 1000, 0.0001) * 1000), black is Y_2_ST2084(0)
+var acesReferences = []struct {
+	name   string
+	output ACESOutput
+	black  float64
+	grey   float64
+	white  float64
+}{
+	{name: "sRGB-100", output: ACESsRGB100nits, black: 0, grey: 0.3559542754750763, white: 1},
+	{name: "Rec709-100", output: ACESRec709100nits, black: 0, grey: 0.38953011893372164, white: 1},
+	{name: "P3D65-48", output: ACESP3D6548nits, black: 0, grey: 0.411866803892259, white: 1},
+	{name: "Rec2020-1000-PQ", output: ACESRec20201000nitsPQ, black: 7.309559025783966e-07, grey: 0.332590108329092, white: 0.751827096247041},
+}
+
+func TestACESReferences(t *testing.T) {
+	inputs := []float64{0, 0.18, 10000}
+
+	m := hdr.NewRGB64(image.Rect(0, 0, len(inputs), 1))
+	for x, v := range inputs {
+		m.Set(x, 0, hdrcolor.RGB{R: v, G: v, B: v})
+	}
+
+	for _, ref := range acesReferences {
+		t.Run(ref.name, func(t *testing.T) {
+			img := NewACES(m, 0, ref.output).Perform().(*image.RGBA64)
+
+			for x, expected := range []float64{ref.black, ref.grey, ref.white} {
+				c := img.RGBA64At(x, 0)
+				for _, v := range []uint16{c.R, c.G, c.B} {
+					if actual := float64(v) / RangeMax; math.Abs(actual-expected) > acesEpsilon {
+						t.Errorf("input %v: got %v, want %v ± %v", inputs[x], actual, expected, acesEpsilon)
+					}
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,180 @@
+package tmo
+
+import (
+	"image"
+	"math"
+
+	"github.com/mdouchement/hdr"
+	"github.com/mdouchement/hdr/filter"
+	"github.com/mdouchement/hdr/hdrcolor"
+)
+
+const ashikhmin02Scales = 10 // Largest scale (pixels) of the adaptation search
+
+// An Ashikhmin02 is a local adaptation TMO implementation based on Michael Ashikhmin's 2002 white paper.
+// The local adaptation luminance is picked through a scale-space search, compressed with
+// a perceptual capacity function and the details are reintroduced afterward.
+// The world luminances are expected in cd/m².
+//
+// Reference:
+// A Tone Mapping Algorithm for High Contrast Images.
+// M. Ashikhmin.
+// In Eurographics Workshop on Rendering, 2002.
+type Ashikhmin02 struct {
+	HDRImage hdr.Image
+	Display
+	// Threshold is included in [0.1, 1] with 0.01 increment step.
+	// It is the local contrast limit of the adaptation scale selection.
+	Threshold float64
+	// Detail reintroduces the details lost by the local adaptation compression.
+	Detail bool
+	executable
+}
+
+// NewDefaultAshikhmin02 instanciates a new Ashikhmin02 TMO with default parameters.
+func NewDefaultAshikhmin02(m hdr.Image) *Ashikhmin02 {
+	return NewAshikhmin02(m, NewDefaultDisplay(), 0.5, true)
+}
+
+// NewAshikhmin02 instanciates a new Ashikhmin02 TMO.
+func NewAshikhmin02(m hdr.Image, display Display, threshold float64, detail bool) *Ashikhmin02 {
+	return &Ashikhmin02{
+		HDRImage:  m,
+		Display:   display,
+		Threshold: threshold,
+		Detail:    detail,
+	}
+}
+
+// Perform runs the TMO mapping.
+func (t *Ashikhmin02) Perform() image.Image {
+	return t.encode(NewGammaOutput(displayGamma), t.PerformHDR())
+}
+
+// PerformHDR runs the TMO mapping and returns display-referred linear values.
+func (t *Ashikhmin02) PerformHDR() hdr.Image {
+	img := hdr.NewRGB64(t.HDRImage.Bounds())
+
+	wl := newWorldLuminance(t.executor, t.HDRImage)
+	adaptation := t.adaptation()
+
+	cmin := capacity(wl.minLum)
+	cmax := capacity(wl.maxLum)
+	if cmax <= cmin {
+		cmax = cmin + 1
+	}
+
+	completed := t.executor.TilesR(t.HDRImage.Bounds(), func(x1, y1, x2, y2 int) {
+		for y := y1; y < y2; y++ {
+			for x := x1; x < x2; x++ {
+				pixel := t.HDRImage.HDRAt(x, y)
+				_, lw, _, _ := pixel.HDRXYZA()
+
+				if lw <= 0 {
+					img.SetRGB(x, y, hdrcolor.RGB{})
+					continue
+				}
+
+				la, _, _, _ := adaptation.HDRAt(x, y).HDRRGBA()
+				la = math.Max(la, wl.minLum)
+				tm := t.Ldmax * (capacity(la) - cmin) / (cmax - cmin)
+
+				ld := tm // Local adaptation only
+				if t.Detail {
+					ld = lw * tm / la // Detail reintroduction
+				}
+
+				r, g, b, _ := pixel.HDRRGBA()
+				s := ld / lw
+
+				img.SetRGB(x, y, hdrcolor.RGB{
+					R: r * s / t.Ldmax,
+					G: g * s / t.Ldmax,
+					B: b * s / t.Ldmax,
+				})
+			}
+		}
+	})
+
+	<-completed
+
+	return img
+}
+
+// adaptation returns the local adaptation luminance for each pixel: the Gaussian average
+// of the largest scale s where the local contrast |Gs - G2s| / Gs is lower than Threshold.
+func (t *Ashikhmin02) adaptation() hdr.Image {
+	d := t.HDRImage.Bounds()
+	lum := hdr.NewRGB64(d)
+	adaptation := hdr.NewRGB64(d)
+	done := make([]bool, d.Dx()*d.Dy())
+
+	completed := t.executor.TilesR(d, func(x1, y1, x2, y2 int) {
+		for y := y1; y < y2; y++ {
+			for x := x1; x < x2; x++ {
+				_, Y, _, _ := t.HDRImage.HDRAt(x, y).HDRXYZA()
+				Y = math.Max(Y, 0)
+				lum.SetRGB(x, y, hdrcolor.RGB{R: Y, G: Y, B: Y})
+			}
+		}
+	})
+	<-completed
+
+	// Scale space
+	blurred := make(map[int]hdr.Image)
+	gaussian := func(s int) hdr.Image {
+		if _, ok := blurred[s]; !ok {
+			blurred[s] = filter.FastGaussianWithExecutor(t.executor, lum, s)
+		}
+		return blurred[s]
+	}
+
+	for s := 1; s <= ashikhmin02Scales && t.executor.Err() == nil; s++ {
+		g1 := gaussian(s)
+		g2 := gaussian(2 * s)
+
+		completed = t.executor.TilesR(d, func(x1, y1, x2, y2 int) {
+			for y := y1; y < y2; y++ {
+				for x := x1; x < x2; x++ {
+					n := (y-d.Min.Y)*d.Dx() + x - d.Min.X
+					if done[n] {
+						continue
+					}
+
+					c1, _, _, _ := g1.HDRAt(x, y).HDRRGBA()
+					c2, _, _, _ := g2.HDRAt(x, y).HDRRGBA()
+
+					if s == 1 {
+						adaptation.SetRGB(x, y, hdrcolor.RGB{R: c1, G: c1, B: c1})
+					}
+
+					if c1 <= 0 || math.Abs(c1-c2)/c1 > t.Threshold {
+						done[n] = true
+						continue
+					}
+					adaptation.SetRGB(x, y, hdrcolor.RGB{R: c1, G: c1, B: c1})
+				}
+			}
+		})
+		<-completed
+
+		delete(blurred, s) // No longer used
+	}
+
+	return adaptation
+}
+
+// capacity is the perceptual capacity function C(L) that counts the number of
+// just noticeable differences from zero to L (piecewise approximation of the TVI).
+func capacity(l float64) float64 {
+	switch {
+	case l < 0.0034:
+		return l / 0.0014
+	case l < 1:
+		return 2.4483 + math.Log10(l/0.0034)/0.4027
+	case l < 7.2444:
+		return 16.5630 + (l-1)/0.4027
+	default:
+		return 32.0693 + math.Log10(l/7.2444)/0.0556
+	}
+}
@@ -0,0 +1,132 @@
+package tmo
+
+import (
+	"math"
+	"math/rand"
+	"sync"
+)
+
+const (
+	blueNoiseSize  = 64 // Power of two
+	blueNoiseSigma = 1.5
+)
+
+var (
+	blueNoiseOnce sync.Once
+	blueNoiseMask []float64
+)
+
+// blueNoise returns the blueNoiseSize x blueNoiseSize threshold mask normalized to ]0, 1[.
+// The mask is generated once and shared by all the quantizers.
+func blueNoise() []float64 {
+	blueNoiseOnce.Do(func() {
+		blueNoiseMask = voidAndCluster(blueNoiseSize, blueNoiseSigma)
+	})
+
+	return blueNoiseMask
+}
+
+// voidAndCluster generates a tileable blue noise threshold mask with the void-and-cluster method.
+// The filling phases only use the largest voids (simplified variant).
+//
+// Reference:
+// The void-and-cluster method for dither array generation.
+// R. Ulichney.
+// In SPIE Human Vision, Visual Processing, and Digital Display IV, 1993.
+func voidAndCluster(size int, sigma float64) []float64 {
+	n := size * size
+
+	// Toroidal Gaussian energy filter
+	filter := make([]float64, n)
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			dx := math.Min(float64(x), float64(size-x))
+			dy := math.Min(float64(y), float64(size-y))
+			filter[y*size+x] = math.Exp(-(dx*dx + dy*dy) / (2 * sigma * sigma))
+		}
+	}
+
+	pattern := make([]bool, n)
+	energy := make([]float64, n)
+	toggle := func(i int) {
+		pattern[i] = !pattern[i]
+		sign := 1.0
+		if !pattern[i] {
+			sign = -1
+		}
+
+		ix, iy := i%size, i/size
+		for y := 0; y < size; y++ {
+			fy := ((y - iy + size) % size) * size
+			for x := 0; x < size; x++ {
+				energy[y*size+x] += sign * filter[fy+(x-ix+size)%size]
+			}
+		}
+	}
+
+	tightestCluster := func() (c int) {
+		max := math.Inf(-1)
+		for i, set := range pattern {
+			if set && energy[i] > max {
+				max = energy[i]
+				c = i
+			}
+		}
+		return
+	}
+
+	largestVoid := func() (v int) {
+		min := math.Inf(1)
+		for i, set := range pattern {
+			if !set && energy[i] < min {
+				min = energy[i]
+				v = i
+			}
+		}
+		return
+	}
+
+	// Initial binary pattern with homogeneously distributed points
+	ones := n / 10
+	for _, i := range rand.New(rand.NewSource(1)).Perm(n)[:ones] {
+		toggle(i)
+	}
+	for {
+		c := tightestCluster()
+		toggle(c)
+		v := largestVoid()
+		if v == c {
+			toggle(c)
+			break
+		}
+		toggle(v)
+	}
+
+	initialPattern := append([]bool(nil), pattern...)
+	initialEnergy := append([]float64(nil), energy...)
+	rank := make([]int, n)
+
+	// Phase 1: rank the initial points by removing the tightest clusters
+	for r := ones - 1; r >= 0; r-- {
+		c := tightestCluster()
+		toggle(c)
+		rank[c] = r
+	}
+
+	copy(pattern, initialPattern)
+	copy(energy, initialEnergy)
+
+	// Phase 2 and 3: rank the remaining points by filling the largest voids
+	for r := ones; r < n; r++ {
+		v := largestVoid()
+		toggle(v)
+		rank[v] = r
+	}
+
+	mask := make([]float64, n)
+	for i, r := range rank {
+		mask[i] = (float64(r) + 0.5) / float64(n)
+	}
+
+	return mask
+}
@@ -0,0 +1,82 @@
+package tmo
+
+import (
+	"fmt"
+	"image"
+	"math"
+	"testing"
+
+	"github.com/mdouchement/hdr"
+	"github.com/mdouchement/hdr/hdrcolor"
+)
+
+// gradient returns an HDR image of the given bounds whose pixels only depend on their position relative to r.Min.
+func gradient(r image.Rectangle) *hdr.RGB64 {
+	m := hdr.NewRGB64(r)
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			dx, dy := float64(x-r.Min.X), float64(y-r.Min.Y)
+			m.Set(x, y, hdrcolor.RGB{
+				R: 0.01 + math.Pow(2, dx/8),
+				G: 0.02 + math.Pow(2, dy/8),
+				B: 0.05 + math.Pow(2, (dx+dy)/16),
+			})
+		}
+	}
+	return m
+}
+
+func TestNonZeroBounds(t *testing.T) {
+	origin := gradient(image.Rect(0, 0, 64, 64))
+	shifted := gradient(image.Rect(10, 10, 74, 74))
+
+	for _, name := range Registered() {
+		r, _ := Lookup(name)
+
+		// The defaults and each bool/enum parameter set to its other values
+		variants := map[string]map[string]float64{name: r.Defaults()}
+		for _, p := range r.Parameters {
+			if p.Type != ParameterBool && p.Type != ParameterEnum {
+				continue
+			}
+			for v := p.Min; v <= p.Max; v++ {
+				if v == p.Default {
+					continue
+				}
+				params := r.Defaults()
+				params[p.Name] = v
+				variants[fmt.Sprintf("%s/%s=%v", name, p.Name, v)] = params
+			}
+		}
+
+		for variant, params := range variants {
+			params := params
+			t.Run(variant, func(t *testing.T) {
+				expected := r.New(origin, params).Perform()
+				actual := r.New(shifted, params).Perform()
+
+				if actual.Bounds().Size() != shifted.Bounds().Size() {
+					t.Fatalf("got bounds %v, want size %v", actual.Bounds(), shifted.Bounds().Size())
+				}
+
+				eb, ab := expected.Bounds(), actual.Bounds()
+				for y := 0; y < eb.Dy(); y++ {
+					for x := 0; x < eb.Dx(); x++ {
+						r1, g1, b1, _ := expected.At(eb.Min.X+x, eb.Min.Y+y).RGBA()
+						r2, g2, b2, _ := actual.At(ab.Min.X+x, ab.Min.Y+y).RGBA()
+						if diff(r1, r2) > 257 || diff(g1, g2) > 257 || diff(b1, b2) > 257 {
+							t.Fatalf("pixel (%d, %d): got %v, want %v", x, y, []uint32{r2, g2, b2}, []uint32{r1, g1, b1})
+						}
+					}
+				}
+			})
+		}
+	}
+}
+
+func diff(a, b uint32) uint32 {
+	if a > b {
+		return a - b
+	}
+	return b - a
+}
@@ -2,13 +2,11 @@ package tmo
 
 import (
 	"image"
-	"image/color"
 	"math"
 
 	"github.com/mdouchement/hdr"
-	"github.com/mdouchement/hdr/filter"
+	"github.com/mdouchement/hdr/hdrcolor"
 	"github.com/mdouchement/hdr/xmath"
-	"github.com/mdouchement/hdr/parallel"
 )
 
 // A CustomReinhard05 is a custom Reinhard05 TMO implementation.
@@ -23,6 +21,7 @@ type CustomReinhard05 struct {
 	// Light is included in [0, 1] with 0.01 increment step.
 	Light float64
 	f     float64
+	executable
 }
 
 // NewDefaultCustomReinhard05 instanciates a new CustomReinhard05 TMO with default parameters.
@@ -42,7 +41,12 @@ func NewCustomReinhard05(m hdr.Image, brightness, chromatic, light float64) *Cus
 
 // Perform runs the TMO mapping.
 func (t *CustomReinhard05) Perform() image.Image {
-	img := image.NewRGBA64(t.HDRImage.Bounds())
+	return t.encode(NewGammaOutput(reinhardGamma), t.PerformHDR())
+}
+
+// PerformHDR runs the TMO mapping and returns display-referred linear values.
+func (t *CustomReinhard05) PerformHDR() hdr.Image {
+	img := hdr.NewRGB64(t.HDRImage.Bounds())
 
 	// Image brightness
 	t.f = math.Exp(-t.Brightness)
@@ -55,22 +59,25 @@ func (t *CustomReinhard05) Perform() image.Image {
 }
 
 func (t *CustomReinhard05) tonemap() (minSample, maxSample float64) {
-	qsImg := filter.NewQuickSampling(t.HDRImage, 0.6)
+	qsImg := quickSampling(t.HDRImage)
 
 	minSample = math.Inf(1)
 	maxSample = math.Inf(-1)
 	minCh := make(chan float64)
 	maxCh := make(chan float64)
 
-	completed := parallel.TilesR(qsImg.Bounds(), func(x1, y1, x2, y2 int) {
+	completed := t.executor.TilesR(qsImg.Bounds(), func(x1, y1, x2, y2 int) {
 		min := 1.0
 		max := 0.0
 
+		rgb := make([]float64, 3*(x2-x1))
+		xyz := make([]float64, 3*(x2-x1))
 		for y := y1; y < y2; y++ {
-			for x := x1; x < x2; x++ {
-				pixel := qsImg.HDRAt(x, y)
-				r, g, b, _ := pixel.HDRRGBA()
-				_, lum, _, _ := pixel.HDRXYZA()
+			hdr.ReadRow(qsImg, rgb, x1, x2, y, hdr.RGBSpace)
+			hdr.ReadRow(qsImg, xyz, x1, x2, y, hdr.XYZSpace)
+			for i := 0; i < len(rgb); i += 3 {
+				r, g, b := rgb[i+0], rgb[i+1], rgb[i+2]
+				lum := xyz[i+1]
 
 				var sample float64
 
@@ -120,18 +127,18 @@ func (t *CustomReinhard05) sampling(sample, lum float64) float64 {
 	return sample
 }
 
-func (t *CustomReinhard05) normalize(img *image.RGBA64, minSample, maxSample float64) {
-	completed := parallel.TilesR(t.HDRImage.Bounds(), func(x1, y1, x2, y2 int) {
+func (t *CustomReinhard05) normalize(img *hdr.RGB64, minSample, maxSample float64) {
+	completed := t.executor.TilesR(t.HDRImage.Bounds(), func(x1, y1, x2, y2 int) {
+		row := make([]float64, 3*(x2-x1))
 		for y := y1; y < y2; y++ {
-			for x := x1; x < x2; x++ {
-				pixel := t.HDRImage.HDRAt(x, y)
-				r, g, b, _ := pixel.HDRRGBA()
+			hdr.ReadRow(t.HDRImage, row, x1, x2, y, hdr.RGBSpace)
+			for x, i := x1, 0; x < x2; x, i = x+1, i+3 {
+				r, g, b := row[i+0], row[i+1], row[i+2]
 
-				img.SetRGBA64(x, y, color.RGBA64{
+				img.SetRGB(x, y, hdrcolor.RGB{
 					R: t.nrmz(r, minSample, maxSample),
 					G: t.nrmz(g, minSample, maxSample),
 					B: t.nrmz(b, minSample, maxSample),
-					A: RangeMax,
 				})
 			}
 		}
@@ -141,20 +148,6 @@ func (t *CustomReinhard05) normalize(img *image.RGBA64, minSample, maxSample flo
 }
 
 // normalize one channel
-func (t *CustomReinhard05) nrmz(channel, minSample, maxSample float64) uint16 {
-	// Normalize intensities
-	channel = (channel - minSample) / (maxSample - minSample)
-
-	// Gamma correction
-	if channel > RangeMin {
-		channel = math.Pow(channel, 1/reinhardGamma)
-	}
-
-	// Inverse pixel mapping
-	channel = LinearInversePixelMapping(channel, LumPixFloor, LumSize)
-
-	// Clamp to solid black and solid white
-	channel = LDRClamp(channel)
-
-	return uint16(channel)
+func (t *CustomReinhard05) nrmz(channel, minSample, maxSample float64) float64 {
+	return (channel - minSample) / (maxSample - minSample)
 }
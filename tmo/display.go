@@ -0,0 +1,92 @@
+package tmo
+
+import (
+	"math"
+
+	"github.com/mdouchement/hdr"
+	"github.com/mdouchement/hdr/parallel"
+	"github.com/mdouchement/hdr/xmath"
+)
+
+const displayGamma = 2.2
+
+// A Display describes the luminance capabilities of the output device.
+// It is shared by the perceptual operators that map world luminances (cd/m²) to display luminances.
+type Display struct {
+	// Ldmax is the maximum display luminance (cd/m²).
+	Ldmax float64
+	// Cmax is the maximum display contrast (Ldmax / Ldmin).
+	Cmax float64
+}
+
+// NewDefaultDisplay returns a typical CRT/sRGB display (100 cd/m² and 100:1 contrast).
+func NewDefaultDisplay() Display {
+	return NewDisplay(100, 100)
+}
+
+// NewDisplay returns a new Display.
+func NewDisplay(ldmax, cmax float64) Display {
+	return Display{
+		Ldmax: math.Max(1, ldmax),
+		Cmax:  math.Max(1, cmax),
+	}
+}
+
+// Ldmin returns the minimum display luminance (cd/m²).
+func (d Display) Ldmin() float64 {
+	return d.Ldmax / d.Cmax
+}
+
+// A worldLuminance holds the luminance statistics of a scene.
+type worldLuminance struct {
+	minLum float64 // Smallest non-zero luminance
+	maxLum float64
+	logAvg float64
+}
+
+func newWorldLuminance(e *parallel.Executor, m hdr.Image) *worldLuminance {
+	wl := &worldLuminance{
+		minLum: math.Inf(1),
+		maxLum: math.Inf(-1),
+	}
+	qsImg := quickSampling(m)
+
+	partials := parallel.Partials(e, qsImg.Bounds(), func(x1, y1, x2, y2 int) worldLuminance {
+		ww := worldLuminance{
+			minLum: math.Inf(1),
+			maxLum: math.Inf(-1),
+		}
+		var logSum xmath.KahanSum
+
+		row := make([]float64, 3*(x2-x1))
+		for y := y1; y < y2; y++ {
+			hdr.ReadRow(qsImg, row, x1, x2, y, hdr.XYZSpace)
+			for i := 0; i < len(row); i += 3 {
+				lum := row[i+1]
+
+				if lum > 0 {
+					ww.minLum = math.Min(ww.minLum, lum)
+				}
+				ww.maxLum = math.Max(ww.maxLum, lum)
+				logSum.Add(math.Log((2.3e-5) + math.Max(lum, 0)))
+			}
+		}
+
+		ww.logAvg = logSum.Value()
+		return ww
+	})
+
+	logSums := make([]float64, len(partials))
+	for i, ww := range partials {
+		wl.minLum = math.Min(wl.minLum, ww.minLum)
+		wl.maxLum = math.Max(wl.maxLum, ww.maxLum)
+		logSums[i] = ww.logAvg
+	}
+
+	wl.logAvg = math.Exp(xmath.PairwiseSum(logSums) / float64(qsImg.Size()))
+	if math.IsInf(wl.minLum, 1) {
+		wl.minLum = 2.3e-5
+	}
+
+	return wl
+}
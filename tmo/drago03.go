@@ -2,14 +2,16 @@ package tmo
 
 import (
 	"image"
-	"image/color"
+	"image/draw"
 	"math"
 	"sync"
 
 	colorful "github.com/lucasb-eyer/go-colorful"
 	"github.com/mdouchement/hdr"
-	"github.com/mdouchement/hdr/xmath"
+	"github.com/mdouchement/hdr/filter"
+	"github.com/mdouchement/hdr/hdrcolor"
 	"github.com/mdouchement/hdr/parallel"
+	"github.com/mdouchement/hdr/xmath"
 )
 
 // A Drago03 is an adaptive TMO implementation based on Frederic Drago's 2003 white paper.
@@ -25,6 +27,10 @@ type Drago03 struct {
 	avgLum  float64
 	divider float64
 	biasP   float64
+	// Region rendering
+	proxyOnce       sync.Once
+	proxyStatistics Statistics
+	executable
 }
 
 // NewDefaultDrago03 instanciates a new Drago03 TMO with default parameters.
@@ -43,7 +49,12 @@ func NewDrago03(m hdr.Image, bias float64) *Drago03 {
 
 // Perform runs the TMO mapping.
 func (t *Drago03) Perform() image.Image {
-	img := image.NewRGBA64(t.HDRImage.Bounds())
+	return t.encode(NewOutput(EncodingLinear), t.PerformHDR())
+}
+
+// PerformHDR runs the TMO mapping and returns display-referred linear values.
+func (t *Drago03) PerformHDR() hdr.Image {
+	img := hdr.NewRGB64(t.HDRImage.Bounds())
 
 	t.biasP = math.Log10(t.Bias) / math.Log(0.5)
 
@@ -53,56 +64,78 @@ func (t *Drago03) Perform() image.Image {
 	return img
 }
 
-func (t *Drago03) luminance() {
-	avgCh := make(chan float64)
-	maxCh := make(chan float64)
+// Statistics computes (once) and returns the adaptation statistics of the HDR image.
+func (t *Drago03) Statistics() Statistics {
+	t.lumOnce.Do(t.luminance)
+	return Statistics{
+		MaxLum: t.maxLum * t.avgLum,
+		LogAvg: t.avgLum,
+	}
+}
+
+// SetStatistics overrides the adaptation statistics used by Perform.
+func (t *Drago03) SetStatistics(s Statistics) {
+	t.lumOnce.Do(func() {})
+	t.avgLum = s.LogAvg
+	t.maxLum = s.MaxLum / s.LogAvg
+	t.divider = math.Log10(t.maxLum + 1.0)
+}
 
-	completed := parallel.TilesR(t.HDRImage.Bounds(), func(x1, y1, x2, y2 int) {
-		var avg float64
+// RenderRegion implements RegionOperator, the statistics are computed on a proxy of the whole image.
+func (t *Drago03) RenderRegion(dst draw.Image, r image.Rectangle, scale float64) {
+	t.proxyOnce.Do(func() {
+		proxy, _ := regionProxy(t.executor, t.HDRImage)
+		t.proxyStatistics = NewDrago03(proxy, t.Bias).Statistics()
+	})
+
+	region := NewDrago03(filter.ResampleWithExecutor(t.executor, t.HDRImage, r, scale), t.Bias)
+	region.executor = t.executor
+	region.SetStatistics(t.proxyStatistics)
+	drawRegion(dst, region.Perform())
+}
+
+func (t *Drago03) luminance() {
+	partials := parallel.Partials(t.executor, t.HDRImage.Bounds(), func(x1, y1, x2, y2 int) [2]float64 {
+		var avg xmath.KahanSum
 		max := math.Inf(-1)
 
+		row := make([]float64, 3*(x2-x1))
 		for y := y1; y < y2; y++ {
-			for x := x1; x < x2; x++ {
-				pixel := t.HDRImage.HDRAt(x, y)
-				_, lum, _, _ := pixel.HDRXYZA()
+			hdr.ReadRow(t.HDRImage, row, x1, x2, y, hdr.XYZSpace)
+			for i := 0; i < len(row); i += 3 {
+				lum := row[i+1]
 
-				avg += math.Log(lum + 1e-4)
+				avg.Add(math.Log(lum + 1e-4))
 				max = math.Max(max, lum)
 			}
 		}
 
-		avgCh <- avg
-		maxCh <- max
+		return [2]float64{avg.Value(), max}
 	})
 
-	for {
-		select {
-		case <-completed:
-			goto NEXT
-		case avg := <-avgCh:
-			t.avgLum += avg
-		case max := <-maxCh:
-			t.maxLum = math.Max(t.maxLum, max)
-		}
+	avgs := make([]float64, len(partials))
+	for i, partial := range partials {
+		avgs[i] = partial[0]
+		t.maxLum = math.Max(t.maxLum, partial[1])
 	}
-NEXT:
 
-	t.avgLum = math.Exp(t.avgLum / float64(t.HDRImage.Size()))
+	t.avgLum = math.Exp(xmath.PairwiseSum(avgs) / float64(t.HDRImage.Size()))
 	// Normalize
 	t.maxLum /= t.avgLum
 	// Set divider
 	t.divider = math.Log10(t.maxLum + 1.0)
 }
 
-func (t *Drago03) tonemap(img *image.RGBA64) {
-	completed := parallel.TilesR(t.HDRImage.Bounds(), func(x1, y1, x2, y2 int) {
+func (t *Drago03) tonemap(img *hdr.RGB64) {
+	completed := t.executor.TilesR(t.HDRImage.Bounds(), func(x1, y1, x2, y2 int) {
 		var lumAvgRatio float64
 		var newLum float64
 
+		row := make([]float64, 3*(x2-x1))
 		for y := y1; y < y2; y++ {
-			for x := x1; x < x2; x++ {
-				pixel := t.HDRImage.HDRAt(x, y)
-				xx, yy, zz, _ := pixel.HDRXYZA()
+			hdr.ReadRow(t.HDRImage, row, x1, x2, y, hdr.XYZSpace)
+			for x, i := x1, 0; x < x2; x, i = x+1, i+3 {
+				xx, yy, zz := row[i+0], row[i+1], row[i+2]
 
 				// Core Drago Equation
 				lumAvgRatio = yy / t.avgLum
@@ -116,25 +149,10 @@ func (t *Drago03) tonemap(img *image.RGBA64) {
 
 				// XYZ color-space to RGB conversion
 				r, g, b := colorful.XyzToLinearRgb(xx, yy, zz)
-				img.SetRGBA64(x, y, color.RGBA64{
-					R: t.normalize(r),
-					G: t.normalize(g),
-					B: t.normalize(b),
-					A: RangeMax,
-				})
+				img.SetRGB(x, y, hdrcolor.RGB{R: r, G: g, B: b})
 			}
 		}
 	})
 
 	<-completed
 }
-
-func (t *Drago03) normalize(channel float64) uint16 {
-	// Inverse pixel mapping
-	channel = LinearInversePixelMapping(channel, LumPixFloor, LumSize)
-
-	// Clamp to solid black and solid white
-	channel = LDRClamp(channel)
-
-	return uint16(channel)
-}
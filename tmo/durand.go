@@ -2,19 +2,16 @@ package tmo
 
 import (
 	"image"
-	"image/color"
+	"image/draw"
 	"math"
 	"sync"
 
 	"github.com/mdouchement/hdr"
 	"github.com/mdouchement/hdr/filter"
-	"github.com/mdouchement/hdr/parallel"
+	"github.com/mdouchement/hdr/hdrcolor"
 )
 
-const (
-	durandGamma    = 2.2
-	durandInvGamma = 1 / durandGamma
-)
+const durandGamma = 2.2
 
 // A Durand is a Fast Bilateral Filtering for the Display of High-Dynamic-Range Images.
 //
@@ -27,6 +24,12 @@ type Durand struct {
 	lumOnce  sync.Once
 	minLum   float64
 	maxLum   float64
+	scale    float64 // Resolution ratio of HDRImage when it is a proxy
+	// Region rendering
+	proxyOnce  sync.Once
+	proxy      *Durand
+	proxyGuide *filter.Plane
+	executable
 }
 
 // NewDefaultDurand instanciates a new Durand TMO with default parameters.
@@ -46,28 +49,68 @@ func NewDurand(m hdr.Image, contrast float64) *Durand {
 
 // Perform runs the TMO mapping.
 func (t *Durand) Perform() image.Image {
-	bilateral := filter.NewYFastBilateralAuto(filter.NewLog10(t.HDRImage))
-	bilateral.Perform()
-	t.base = bilateral // In log10
+	return t.encode(NewGammaOutput(durandGamma), t.PerformHDR())
+}
 
-	t.lumOnce.Do(t.luminance)
+// PerformHDR runs the TMO mapping and returns display-referred linear values.
+func (t *Durand) PerformHDR() hdr.Image {
+	t.lumOnce.Do(t.decompose) // Parameter-independent base layer
 
-	img := image.NewRGBA64(t.HDRImage.Bounds())
+	img := hdr.NewRGB64(t.HDRImage.Bounds())
 	t.tonemap(img)
 
 	return img
 }
 
+// decompose computes the base layer and its luminance range,
+// they are reused by the next Perform calls.
+func (t *Durand) decompose() {
+	bilateral := filter.NewYFastBilateralAuto(filter.NewLog10(t.HDRImage))
+	bilateral.Executor = t.executor
+	if t.scale > 0 {
+		bilateral.SigmaSpace = math.Max(1, bilateral.SigmaSpace*t.scale)
+	}
+	bilateral.Perform()
+	t.base = filter.MaterializeWithExecutor(t.executor, bilateral) // In log10, read by each Perform
+
+	t.luminance()
+}
+
+// RenderRegion implements RegionOperator, the base layer is computed on a proxy of the whole image
+// and upsampled with the region luminance as guide (joint bilateral upsampling).
+func (t *Durand) RenderRegion(dst draw.Image, r image.Rectangle, scale float64) {
+	t.proxyOnce.Do(func() {
+		proxy, s := regionProxy(t.executor, t.HDRImage)
+		t.proxy = NewDurand(proxy, t.Contrast)
+		t.proxy.executor = t.executor
+		t.proxy.scale = s
+		t.proxy.lumOnce.Do(t.proxy.decompose)
+		t.proxyGuide = log10Luminance(t.executor, proxy)
+	})
+	if t.executor.Err() != nil {
+		return
+	}
+
+	region := NewDurand(filter.ResampleWithExecutor(t.executor, t.HDRImage, r, scale), t.Contrast)
+	region.executor = t.executor
+	region.base = upsampleLayer(t.executor, t.HDRImage, t.proxy.base, t.proxyGuide, log10Luminance(t.executor, region.HDRImage), r, scale, t.proxy.scale, regionSigmaRange)
+	region.minLum, region.maxLum = t.proxy.minLum, t.proxy.maxLum
+	region.lumOnce.Do(func() {})
+	drawRegion(dst, region.Perform())
+}
+
 func (t *Durand) luminance() {
 	maxCh := make(chan float64)
 	minCh := make(chan float64)
 
-	completed := parallel.TilesR(t.HDRImage.Bounds(), func(x1, y1, x2, y2 int) {
+	completed := t.executor.TilesR(t.HDRImage.Bounds(), func(x1, y1, x2, y2 int) {
 		min, max := math.Inf(1), math.Inf(-1)
 
+		row := make([]float64, 3*(x2-x1))
 		for y := y1; y < y2; y++ {
-			for x := x1; x < x2; x++ {
-				_, Y, _, _ := t.base.HDRAt(x, y).HDRXYZA()
+			hdr.ReadRow(t.base, row, x1, x2, y, hdr.XYZSpace)
+			for i := 0; i < len(row); i += 3 {
+				Y := row[i+1]
 				min = math.Min(min, Y)
 				max = math.Max(max, Y)
 			}
@@ -89,7 +132,7 @@ func (t *Durand) luminance() {
 	}
 }
 
-func (t *Durand) tonemap(m *image.RGBA64) {
+func (t *Durand) tonemap(m *hdr.RGB64) {
 	compressionFactor := math.Log10(t.Contrast) / (t.maxLum - t.minLum)
 	absolute := compressionFactor * (t.maxLum - t.minLum)
 
@@ -99,12 +142,17 @@ func (t *Durand) tonemap(m *image.RGBA64) {
 	pow := math.Pow(math.Pow(10, compressionFactor), k2)
 	s := ((1 + k1) * pow) / (1 + k1*pow)
 
-	completed := parallel.TilesR(t.HDRImage.Bounds(), func(x1, y1, x2, y2 int) {
+	completed := t.executor.TilesR(t.HDRImage.Bounds(), func(x1, y1, x2, y2 int) {
+		rgb := make([]float64, 3*(x2-x1))
+		xyz := make([]float64, 3*(x2-x1))
+		base := make([]float64, 3*(x2-x1))
 		for y := y1; y < y2; y++ {
-			for x := x1; x < x2; x++ {
-				pixel := t.HDRImage.HDRAt(x, y)
-				_, Y, _, _ := pixel.HDRXYZA()
-				_, Yb, _, _ := t.base.HDRAt(x, y).HDRXYZA()
+			hdr.ReadRow(t.HDRImage, rgb, x1, x2, y, hdr.RGBSpace)
+			hdr.ReadRow(t.HDRImage, xyz, x1, x2, y, hdr.XYZSpace)
+			hdr.ReadRow(t.base, base, x1, x2, y, hdr.XYZSpace)
+			for x, i := x1, 0; x < x2; x, i = x+1, i+3 {
+				Y := xyz[i+1]
+				Yb := base[i+1]
 
 				Yd := t.clampToZero(Y / math.Pow(10, Yb)) // Luminance detail
 				Yd = math.Log10(Yd)                       // In log10
@@ -112,7 +160,7 @@ func (t *Durand) tonemap(m *image.RGBA64) {
 				Yc := Yb*compressionFactor + Yd - absolute // Get the compressed luminance
 				Yc = math.Pow(10, Yc)                      // Reverse all log10
 
-				r, g, b, _ := pixel.HDRRGBA()
+				r, g, b := rgb[i+0], rgb[i+1], rgb[i+2]
 
 				// Remove old luminance
 				r /= Y
@@ -126,28 +174,14 @@ func (t *Durand) tonemap(m *image.RGBA64) {
 				r = t.clampToZero(r * Yc)
 				g = t.clampToZero(g * Yc)
 				b = t.clampToZero(b * Yc)
-				// Gamma correction
-				r = math.Pow(r, durandInvGamma)
-				g = math.Pow(g, durandInvGamma)
-				b = math.Pow(b, durandInvGamma)
-
-				m.SetRGBA64(x, y, color.RGBA64{
-					R: t.normalize(r),
-					G: t.normalize(g),
-					B: t.normalize(b),
-					A: RangeMax,
-				})
+
+				m.SetRGB(x, y, hdrcolor.RGB{R: r, G: g, B: b})
 			}
 		}
 	})
 	<-completed
 }
 
-func (t *Durand) normalize(channel float64) uint16 {
-	channel = LinearInversePixelMapping(channel, LumPixFloor, LumSize)
-	return uint16(LDRClamp(channel))
-}
-
 func (t *Durand) clampToZero(x float64) float64 {
 	if math.IsNaN(x) || math.IsInf(x, -1) || math.IsInf(x, 1) {
 		return 0
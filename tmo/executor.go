@@ -0,0 +1,51 @@
+package tmo
+
+import (
+	"image"
+
+	"github.com/mdouchement/hdr"
+	"github.com/mdouchement/hdr/parallel"
+)
+
+// An ExecutableOperator is a ToneMappingOperator whose parallel stages run on a parallel.Executor
+// (cancellation, number of workers and progress reporting).
+type ExecutableOperator interface {
+	ToneMappingOperator
+	// SetExecutor sets the executor used by the next Perform calls (nil uses the default one).
+	SetExecutor(e *parallel.Executor)
+}
+
+// PerformWithExecutor runs the TMO mapping of t on the given executor.
+// It returns the executor context error when it is done before the end of the mapping.
+// In that case, the analysis cached by t is incomplete and t must not be reused.
+func PerformWithExecutor(e *parallel.Executor, t ToneMappingOperator) (image.Image, error) {
+	if et, ok := t.(ExecutableOperator); ok {
+		et.SetExecutor(e)
+	}
+
+	if err := e.Err(); err != nil {
+		return nil, err
+	}
+
+	img := t.Perform()
+	if err := e.Err(); err != nil {
+		return nil, err
+	}
+	return img, nil
+}
+
+// executable is embedded by the TMOs to run their parallel stages on an executor.
+type executable struct {
+	executor *parallel.Executor
+}
+
+// SetExecutor sets the executor used by the next Perform calls (nil uses the default one).
+func (x *executable) SetExecutor(e *parallel.Executor) {
+	x.executor = e
+}
+
+// encode runs the display encoding stage o on the executor.
+func (x *executable) encode(o Output, m hdr.Image) *image.RGBA64 {
+	o.Executor = x.executor
+	return o.Encode(m)
+}
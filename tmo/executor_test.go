@@ -0,0 +1,42 @@
+package tmo
+
+import (
+	"image"
+	"testing"
+
+	"github.com/mdouchement/hdr/parallel"
+)
+
+func TestPerformWorkers(t *testing.T) {
+	m := gradient(image.Rect(0, 0, 150, 110))
+
+	for _, name := range Registered() {
+		r, _ := Lookup(name)
+
+		t.Run(name, func(t *testing.T) {
+			var expected image.Image
+			for _, workers := range []int{1, 3, 7} {
+				actual, err := PerformWithExecutor(&parallel.Executor{Workers: workers}, r.New(m, r.Defaults()))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if expected == nil {
+					expected = actual
+					continue
+				}
+
+				// The reductions are combined in tile order so the output is bit-identical
+				d := expected.Bounds()
+				for y := d.Min.Y; y < d.Max.Y; y++ {
+					for x := d.Min.X; x < d.Max.X; x++ {
+						r1, g1, b1, _ := expected.At(x, y).RGBA()
+						r2, g2, b2, _ := actual.At(x, y).RGBA()
+						if r1 != r2 || g1 != g2 || b1 != b2 {
+							t.Fatalf("%d workers: pixel (%d, %d): got %v, want %v", workers, x, y, []uint32{r2, g2, b2}, []uint32{r1, g1, b1})
+						}
+					}
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,165 @@
+package tmo
+
+import (
+	"image"
+	"math"
+
+	"github.com/mdouchement/hdr"
+	"github.com/mdouchement/hdr/filter"
+	"github.com/mdouchement/hdr/xmath"
+)
+
+const (
+	fattalGamma        = 2.2
+	fattalMinLevelSize = 32 // Coarsest pyramid level size
+)
+
+// A Fattal02 is a gradient domain TMO implementation based on Raanan Fattal's 2002 white paper.
+// The log-luminance gradients are attenuated across a Gaussian pyramid
+// and the luminance is reconstructed with a Poisson solver.
+//
+// Reference:
+// Gradient Domain High Dynamic Range Compression.
+// R. Fattal, D. Lischinski and M. Werman.
+// In ACM Transactions on Graphics (SIGGRAPH), 2002.
+type Fattal02 struct {
+	HDRImage hdr.Image
+	// Alpha is included in [0.01, 1] with 0.01 increment step.
+	// It is the ratio of the average gradient magnitude where gradients are left unchanged.
+	Alpha float64
+	// Beta is included in [0.7, 0.95] with 0.01 increment step.
+	// Lower values compress more the large gradients.
+	Beta float64
+	// Saturation is included in [0.4, 1.2] with 0.01 increment step.
+	Saturation float64
+	// MinClipping is included in [0, 1] with 0.001 increment step (black percentile).
+	MinClipping float64
+	// MaxClipping is included in [0, 1] with 0.001 increment step (white percentile).
+	MaxClipping float64
+	executable
+}
+
+// NewDefaultFattal02 instanciates a new Fattal02 TMO with default parameters.
+func NewDefaultFattal02(m hdr.Image) *Fattal02 {
+	return NewFattal02(m, 0.1, 0.85, 0.8)
+}
+
+// NewFattal02 instanciates a new Fattal02 TMO.
+func NewFattal02(m hdr.Image, alpha, beta, saturation float64) *Fattal02 {
+	return &Fattal02{
+		HDRImage:    m,
+		Alpha:       alpha,
+		Beta:        beta,
+		Saturation:  saturation,
+		MinClipping: 0.001,
+		MaxClipping: 0.995,
+	}
+}
+
+// Perform runs the TMO mapping.
+func (t *Fattal02) Perform() image.Image {
+	return t.encode(NewGammaOutput(fattalGamma), t.PerformHDR())
+}
+
+// PerformHDR runs the TMO mapping and returns display-referred linear values.
+// When the executor context is done, the returned image is incomplete and the executor reports the error.
+func (t *Fattal02) PerformHDR() hdr.Image {
+	d := t.HDRImage.Bounds()
+	width, height := d.Dx(), d.Dy()
+
+	img := hdr.NewRGB64(d)
+	if d.Empty() {
+		return img
+	}
+
+	// Log-luminance
+	H := logLuminance(t.executor, t.HDRImage, func(lum float64) float64 {
+		return math.Log(math.Max(lum, 0) + 1e-4)
+	})
+
+	// Attenuation function computed across the Gaussian pyramid
+	attenuation := t.attenuation(H)
+
+	// Attenuated gradients (forward differences)
+	gx := make([]float64, width*height)
+	gy := make([]float64, width*height)
+	completed := t.executor.Lines(height, func(y1, y2 int) {
+		for y := y1; y < y2; y++ {
+			for x := 0; x < width; x++ {
+				i := y*width + x
+				if x < width-1 {
+					gx[i] = (H.Pix[i+1] - H.Pix[i]) * 0.5 * (attenuation.Pix[i] + attenuation.Pix[i+1])
+				}
+				if y < height-1 {
+					gy[i] = (H.Pix[i+width] - H.Pix[i]) * 0.5 * (attenuation.Pix[i] + attenuation.Pix[i+width])
+				}
+			}
+		}
+	})
+	<-completed
+
+	if t.executor.Err() != nil {
+		return img // Cancelled, skips the reconstruction
+	}
+
+	// Reconstruction
+	I, err := filter.PoissonReconstructWithExecutor(t.executor, gx, gy, width, height)
+	if err != nil {
+		// The gradients always match the non-empty image size
+		panic("tmo: Fattal02 reconstruction: " + err.Error())
+	}
+
+	tonemapLuminance(t.executor, img, t.HDRImage, I, math.Exp, t.Saturation, t.MinClipping, t.MaxClipping)
+
+	return img
+}
+
+// attenuation returns the gradient attenuation function Φ of the full resolution image.
+func (t *Fattal02) attenuation(H *filter.Plane) *filter.Plane {
+	// Gaussian pyramid
+	pyramid := filter.GaussianPyramid(H, filter.PyramidLevels(H.Width, H.Height, fattalMinLevelSize))
+
+	var attenuation *filter.Plane
+	for k := len(pyramid) - 1; k >= 0; k-- {
+		phi := t.scaling(pyramid[k], k)
+
+		if attenuation != nil {
+			attenuation = attenuation.Resize(phi.Width, phi.Height)
+			for i := range phi.Pix {
+				phi.Pix[i] *= attenuation.Pix[i]
+			}
+		}
+		attenuation = phi
+	}
+
+	return attenuation
+}
+
+// scaling returns the scaling factors φk of the given pyramid level.
+func (t *Fattal02) scaling(p *filter.Plane, k int) *filter.Plane {
+	magnitude := filter.NewPlane(p.Width, p.Height)
+	scale := math.Pow(2, float64(k+1))
+
+	var sum xmath.KahanSum
+	for y := 0; y < p.Height; y++ {
+		for x := 0; x < p.Width; x++ {
+			gx := (p.At(x+1, y) - p.At(x-1, y)) / scale
+			gy := (p.At(x, y+1) - p.At(x, y-1)) / scale
+			g := math.Sqrt(gx*gx + gy*gy)
+
+			magnitude.Set(x, y, g)
+			sum.Add(g)
+		}
+	}
+
+	alpha := t.Alpha * sum.Value() / float64(len(magnitude.Pix))
+	if alpha == 0 {
+		alpha = 1e-4
+	}
+
+	for i, g := range magnitude.Pix {
+		magnitude.Pix[i] = math.Pow(math.Max(g, 1e-4)/alpha, t.Beta-1)
+	}
+
+	return magnitude
+}
@@ -0,0 +1,122 @@
+package tmo
+
+import (
+	"image"
+	"math"
+
+	"github.com/mdouchement/hdr"
+	"github.com/mdouchement/hdr/hdrcolor"
+	"github.com/mdouchement/hdr/xmath"
+)
+
+// A Ferwerda96 is a visual adaptation TMO implementation based on James Ferwerda's 1996 white paper.
+// It combines the photopic (cones) and scotopic (rods) threshold versus intensity functions
+// to simulate the loss of color and visibility in mesopic conditions.
+// The world luminances are expected in cd/m².
+//
+// Reference:
+// A Model of Visual Adaptation for Realistic Image Synthesis.
+// J. A. Ferwerda, S. N. Pattanaik, P. Shirley and D. P. Greenberg.
+// In SIGGRAPH, 1996.
+type Ferwerda96 struct {
+	HDRImage hdr.Image
+	Display
+	// AdaptationLuminance is the world adaptation luminance (cd/m²).
+	// 0 uses the half of the maximum luminance.
+	AdaptationLuminance float64
+	executable
+}
+
+// NewDefaultFerwerda96 instanciates a new Ferwerda96 TMO with default parameters.
+func NewDefaultFerwerda96(m hdr.Image) *Ferwerda96 {
+	return NewFerwerda96(m, NewDefaultDisplay(), 0)
+}
+
+// NewFerwerda96 instanciates a new Ferwerda96 TMO.
+func NewFerwerda96(m hdr.Image, display Display, adaptationLuminance float64) *Ferwerda96 {
+	return &Ferwerda96{
+		HDRImage:            m,
+		Display:             display,
+		AdaptationLuminance: math.Max(0, adaptationLuminance),
+	}
+}
+
+// Perform runs the TMO mapping.
+func (t *Ferwerda96) Perform() image.Image {
+	return t.encode(NewGammaOutput(displayGamma), t.PerformHDR())
+}
+
+// PerformHDR runs the TMO mapping and returns display-referred linear values.
+func (t *Ferwerda96) PerformHDR() hdr.Image {
+	img := hdr.NewRGB64(t.HDRImage.Bounds())
+
+	lwa := t.AdaptationLuminance
+	if lwa <= 0 {
+		lwa = newWorldLuminance(t.executor, t.HDRImage).maxLum / 2
+	}
+	lda := t.Ldmax / 2
+
+	mp := photopicThreshold(lda) / photopicThreshold(lwa)
+	ms := scotopicThreshold(lda) / scotopicThreshold(lwa)
+	k := math.Pow(1-xmath.ClampF64(0, 1, (lwa/2-0.01)/(10-0.01)), 2) // Mesopic factor
+
+	completed := t.executor.TilesR(t.HDRImage.Bounds(), func(x1, y1, x2, y2 int) {
+		for y := y1; y < y2; y++ {
+			for x := x1; x < x2; x++ {
+				pixel := t.HDRImage.HDRAt(x, y)
+				r, g, b, _ := pixel.HDRRGBA()
+				X, Y, Z, _ := pixel.HDRXYZA()
+
+				var ls float64 // Scotopic luminance
+				if X > 0 {
+					ls = math.Max(Y*(1.33*(1+(Y+Z)/X)-1.68), 0)
+				}
+				scotopic := k * ms * ls
+
+				img.SetRGB(x, y, hdrcolor.RGB{
+					R: (mp*r + scotopic) / t.Ldmax,
+					G: (mp*g + scotopic) / t.Ldmax,
+					B: (mp*b + scotopic) / t.Ldmax,
+				})
+			}
+		}
+	})
+
+	<-completed
+
+	return img
+}
+
+// photopicThreshold returns the cones threshold versus intensity.
+func photopicThreshold(la float64) float64 {
+	l := math.Log10(math.Max(la, 1e-8))
+
+	var t float64
+	switch {
+	case l <= -2.6:
+		t = -0.72
+	case l >= 1.9:
+		t = l - 1.255
+	default:
+		t = math.Pow(0.249*l+0.65, 2.7) - 0.72
+	}
+
+	return math.Pow(10, t)
+}
+
+// scotopicThreshold returns the rods threshold versus intensity.
+func scotopicThreshold(la float64) float64 {
+	l := math.Log10(math.Max(la, 1e-8))
+
+	var t float64
+	switch {
+	case l <= -3.94:
+		t = -2.86
+	case l >= -1.44:
+		t = l - 0.395
+	default:
+		t = math.Pow(0.405*l+1.6, 2.18) - 2.86
+	}
+
+	return math.Pow(10, t)
+}
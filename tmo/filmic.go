@@ -0,0 +1,414 @@
+package tmo
+
+import (
+	"image"
+	"image/draw"
+	"math"
+
+	"github.com/mdouchement/hdr"
+	"github.com/mdouchement/hdr/filter"
+	"github.com/mdouchement/hdr/hdrcolor"
+	"github.com/mdouchement/hdr/xmath"
+)
+
+// A FilmicCurve is a global tone curve used by the Filmic TMO.
+type FilmicCurve interface {
+	// Curve maps a scene-referred value to a display-referred linear value.
+	Curve(x float64) float64
+	// DefaultWhitePoint returns the scene-referred value mapped to display white.
+	DefaultWhitePoint() float64
+}
+
+// A filmicRGBCurve is a FilmicCurve that maps the three channels together.
+type filmicRGBCurve interface {
+	CurveRGB(r, g, b float64) (float64, float64, float64)
+}
+
+// FilmicMode defines how a FilmicCurve is applied on the pixels.
+type FilmicMode int
+
+const (
+	// FilmicPerChannel applies the curve on each RGB channel.
+	FilmicPerChannel FilmicMode = iota
+	// FilmicLuminance applies the curve on the luminance and preserves the hue.
+	FilmicLuminance
+)
+
+// A Filmic is a global TMO implementation that applies the filmic curves
+// used by game and real-time engines.
+type Filmic struct {
+	HDRImage hdr.Image
+	Curve    FilmicCurve
+	Mode     FilmicMode
+	// Exposure is included in [-10, 10] with 0.1 increment step (stops).
+	Exposure float64
+	// WhitePoint is the scene-referred value mapped to display white (strictly positive).
+	WhitePoint float64
+	executable
+}
+
+// NewDefaultFilmic instanciates a new Filmic TMO with default parameters (Hable curve).
+func NewDefaultFilmic(m hdr.Image) *Filmic {
+	c := NewDefaultHableCurve()
+	return NewFilmic(m, c, FilmicPerChannel, 0, c.DefaultWhitePoint())
+}
+
+// NewFilmic instanciates a new Filmic TMO.
+func NewFilmic(m hdr.Image, curve FilmicCurve, mode FilmicMode, exposure, whitePoint float64) *Filmic {
+	return &Filmic{
+		HDRImage:   m,
+		Curve:      curve,
+		Mode:       mode,
+		Exposure:   exposure,
+		WhitePoint: math.Max(whitePoint, 1e-4),
+	}
+}
+
+// Perform runs the TMO mapping.
+func (t *Filmic) Perform() image.Image {
+	o := NewOutput(EncodingSRGB)
+	o.InversePixelMapping = false
+	return t.encode(o, t.PerformHDR())
+}
+
+// PerformHDR runs the TMO mapping and returns display-referred linear values.
+func (t *Filmic) PerformHDR() hdr.Image {
+	img := hdr.NewRGB64(t.HDRImage.Bounds())
+
+	t.tonemap(img)
+
+	return img
+}
+
+// RenderRegion implements RegionOperator, the mapping is pointwise so only the region is processed.
+func (t *Filmic) RenderRegion(dst draw.Image, r image.Rectangle, scale float64) {
+	region := NewFilmic(filter.ResampleWithExecutor(t.executor, t.HDRImage, r, scale), t.Curve, t.Mode, t.Exposure, t.WhitePoint)
+	region.executor = t.executor
+	drawRegion(dst, region.Perform())
+}
+
+func (t *Filmic) tonemap(img *hdr.RGB64) {
+	exposure := math.Exp2(t.Exposure)
+	whiteScale := 1 / t.Curve.Curve(t.WhitePoint)
+	mapping := t.mapping(whiteScale)
+
+	completed := t.executor.TilesR(t.HDRImage.Bounds(), func(x1, y1, x2, y2 int) {
+		row := make([]float64, 3*(x2-x1))
+		for y := y1; y < y2; y++ {
+			hdr.ReadRow(t.HDRImage, row, x1, x2, y, hdr.RGBSpace)
+			for x, i := x1, 0; x < x2; x, i = x+1, i+3 {
+				r, g, b := mapping(row[i+0]*exposure, row[i+1]*exposure, row[i+2]*exposure)
+
+				img.SetRGB(x, y, hdrcolor.RGB{R: r, G: g, B: b})
+			}
+		}
+	})
+
+	<-completed
+}
+
+func (t *Filmic) mapping(whiteScale float64) func(r, g, b float64) (float64, float64, float64) {
+	if t.Mode == FilmicLuminance {
+		return func(r, g, b float64) (float64, float64, float64) {
+			lum := 0.2126*r + 0.7152*g + 0.0722*b
+			if lum <= 0 {
+				return 0, 0, 0
+			}
+
+			scale := t.Curve.Curve(lum) * whiteScale / lum
+			r, g, b = r*scale, g*scale, b*scale
+
+			// Keep the hue by scaling down out of range colors instead of clipping channels
+			if max := math.Max(r, math.Max(g, b)); max > 1 {
+				r, g, b = r/max, g/max, b/max
+			}
+			return r, g, b
+		}
+	}
+
+	if c, ok := t.Curve.(filmicRGBCurve); ok {
+		return func(r, g, b float64) (float64, float64, float64) {
+			r, g, b = c.CurveRGB(r, g, b)
+			return r * whiteScale, g * whiteScale, b * whiteScale
+		}
+	}
+
+	return func(r, g, b float64) (float64, float64, float64) {
+		return t.Curve.Curve(r) * whiteScale, t.Curve.Curve(g) * whiteScale, t.Curve.Curve(b) * whiteScale
+	}
+}
+
+//--------------------------------------//
+// Hable                                //
+//--------------------------------------//
+
+// A HableCurve is John Hable's Uncharted 2 filmic curve.
+//
+// Reference:
+// http://filmicworlds.com/blog/filmic-tonemapping-operators/
+type HableCurve struct {
+	ShoulderStrength float64 // A
+	LinearStrength   float64 // B
+	LinearAngle      float64 // C
+	ToeStrength      float64 // D
+	ToeNumerator     float64 // E
+	ToeDenominator   float64 // F
+}
+
+// NewDefaultHableCurve instanciates a new HableCurve with Uncharted 2 parameters.
+func NewDefaultHableCurve() *HableCurve {
+	return &HableCurve{
+		ShoulderStrength: 0.15,
+		LinearStrength:   0.50,
+		LinearAngle:      0.10,
+		ToeStrength:      0.20,
+		ToeNumerator:     0.02,
+		ToeDenominator:   0.30,
+	}
+}
+
+// Curve implements FilmicCurve.
+func (c *HableCurve) Curve(x float64) float64 {
+	x = math.Max(x, 0)
+	A, B, C, D, E, F := c.ShoulderStrength, c.LinearStrength, c.LinearAngle, c.ToeStrength, c.ToeNumerator, c.ToeDenominator
+	return ((x*(A*x+C*B) + D*E) / (x*(A*x+B) + D*F)) - E/F
+}
+
+// DefaultWhitePoint implements FilmicCurve.
+func (c *HableCurve) DefaultWhitePoint() float64 {
+	return 11.2
+}
+
+//--------------------------------------//
+// Lottes                               //
+//--------------------------------------//
+
+// A LottesCurve is Timothy Lottes' 2016 curve from "Advanced Techniques and Optimization of HDR Color Pipelines".
+//
+// Reference:
+// https://gpuopen.com/wp-content/uploads/2016/03/GdcVdrLottes.pdf
+type LottesCurve struct {
+	// Contrast is included in [1, 2] with 0.01 increment step.
+	Contrast float64
+	// Shoulder is included in [0.9, 1] with 0.001 increment step.
+	Shoulder float64
+	// HDRMax is the scene-referred value mapped to 1 by the curve.
+	HDRMax float64
+	// MidIn is the scene-referred middle grey.
+	MidIn float64
+	// MidOut is the display-referred middle grey.
+	MidOut float64
+	b      float64
+	c      float64
+}
+
+// NewDefaultLottesCurve instanciates a new LottesCurve with default parameters.
+func NewDefaultLottesCurve() *LottesCurve {
+	return NewLottesCurve(1.6, 0.977, 8, 0.18, 0.267)
+}
+
+// NewLottesCurve instanciates a new LottesCurve.
+func NewLottesCurve(contrast, shoulder, hdrMax, midIn, midOut float64) *LottesCurve {
+	c := &LottesCurve{
+		Contrast: contrast,
+		Shoulder: shoulder,
+		HDRMax:   math.Max(hdrMax, 1),
+		MidIn:    midIn,
+		MidOut:   midOut,
+	}
+
+	a, d := c.Contrast, c.Shoulder
+	ad := math.Pow(c.HDRMax, a*d) - math.Pow(c.MidIn, a*d)
+	c.b = (-math.Pow(c.MidIn, a) + math.Pow(c.HDRMax, a)*c.MidOut) / (ad * c.MidOut)
+	c.c = (math.Pow(c.HDRMax, a*d)*math.Pow(c.MidIn, a) - math.Pow(c.HDRMax, a)*math.Pow(c.MidIn, a*d)*c.MidOut) / (ad * c.MidOut)
+
+	return c
+}
+
+// Curve implements FilmicCurve.
+func (c *LottesCurve) Curve(x float64) float64 {
+	x = math.Max(x, 0)
+	return math.Pow(x, c.Contrast) / (math.Pow(x, c.Contrast*c.Shoulder)*c.b + c.c)
+}
+
+// DefaultWhitePoint implements FilmicCurve.
+func (c *LottesCurve) DefaultWhitePoint() float64 {
+	return c.HDRMax
+}
+
+//--------------------------------------//
+// Uchimura                             //
+//--------------------------------------//
+
+// A UchimuraCurve is Hajime Uchimura's Gran Turismo curve.
+//
+// Reference:
+// https://www.slideshare.net/nikuque/hdr-theory-and-practicce-jp
+type UchimuraCurve struct {
+	// MaxBrightness is the display maximum (P).
+	MaxBrightness float64
+	// Contrast is the linear section slope (a).
+	Contrast float64
+	// LinearStart is the beginning of the linear section (m).
+	LinearStart float64
+	// LinearLength is the length of the linear section (l).
+	LinearLength float64
+	// Black is the toe tightness (c).
+	Black float64
+	// Pedestal is the toe offset (b).
+	Pedestal float64
+}
+
+// NewDefaultUchimuraCurve instanciates a new UchimuraCurve with Gran Turismo parameters.
+func NewDefaultUchimuraCurve() *UchimuraCurve {
+	return &UchimuraCurve{
+		MaxBrightness: 1,
+		Contrast:      1,
+		LinearStart:   0.22,
+		LinearLength:  0.4,
+		Black:         1.33,
+		Pedestal:      0,
+	}
+}
+
+// Curve implements FilmicCurve.
+func (c *UchimuraCurve) Curve(x float64) float64 {
+	x = math.Max(x, 0)
+	P, a, m, l, cb, b := c.MaxBrightness, c.Contrast, c.LinearStart, c.LinearLength, c.Black, c.Pedestal
+
+	l0 := ((P - m) * l) / a
+	S0 := m + l0
+	S1 := m + a*l0
+	C2 := (a * P) / (P - S1)
+	CP := -C2 / P
+
+	w0 := 1 - smoothstep(0, m, x)
+	w2 := WoB(x >= m+l0)
+	w1 := 1 - w0 - w2
+
+	T := m*math.Pow(x/m, cb) + b
+	S := P - (P-S1)*math.Exp(CP*(x-S0))
+	L := m + a*(x-m)
+
+	return T*w0 + L*w1 + S*w2
+}
+
+// DefaultWhitePoint implements FilmicCurve.
+func (c *UchimuraCurve) DefaultWhitePoint() float64 {
+	// The curve converges to MaxBrightness, this value reaches it in float32 precision.
+	return 16
+}
+
+func smoothstep(edge0, edge1, x float64) float64 {
+	t := xmath.ClampF64(0, 1, (x-edge0)/(edge1-edge0))
+	return t * t * (3 - 2*t)
+}
+
+//--------------------------------------//
+// AgX                                  //
+//--------------------------------------//
+
+// AgXLook is a creative look applied by the AgXCurve.
+type AgXLook struct {
+	Offset     float64
+	Slope      [3]float64
+	Power      [3]float64
+	Saturation float64
+}
+
+var (
+	// AgXLookBase is the neutral AgX look.
+	AgXLookBase = AgXLook{Slope: [3]float64{1, 1, 1}, Power: [3]float64{1, 1, 1}, Saturation: 1}
+	// AgXLookPunchy is the contrasted and saturated AgX look.
+	AgXLookPunchy = AgXLook{Slope: [3]float64{1, 1, 1}, Power: [3]float64{1.35, 1.35, 1.35}, Saturation: 1.4}
+)
+
+// An AgXCurve is Troy Sobotka's AgX display rendering (minimal polynomial approximation).
+//
+// Reference:
+// https://github.com/sobotka/AgX
+// https://iolite-engine.com/blog_posts/minimal_agx_implementation
+type AgXCurve struct {
+	// MinEV is the lowest log2 value of the encoding (middle grey - 10 stops).
+	MinEV float64
+	// MaxEV is the highest log2 value of the encoding (middle grey + 6.5 stops).
+	MaxEV float64
+	Look  AgXLook
+}
+
+var (
+	agxInset = mat3{
+		{0.842479062253094, 0.0784335999999992, 0.0792237451477643},
+		{0.0423282422610123, 0.878468636469772, 0.0791661274605434},
+		{0.0423756549057051, 0.0784336, 0.879142973793104},
+	}
+	agxOutset = mat3{
+		{1.19687900512017, -0.0980208811401368, -0.0990297440797205},
+		{-0.0528968517574562, 1.15190312990417, -0.0989611768448433},
+		{-0.0529716355144438, -0.0980434501171241, 1.15107367264116},
+	}
+)
+
+// NewDefaultAgXCurve instanciates a new AgXCurve with the base look.
+func NewDefaultAgXCurve() *AgXCurve {
+	return NewAgXCurve(AgXLookBase)
+}
+
+// NewAgXCurve instanciates a new AgXCurve with the given look.
+func NewAgXCurve(look AgXLook) *AgXCurve {
+	return &AgXCurve{
+		MinEV: -12.47393,
+		MaxEV: 4.026069,
+		Look:  look,
+	}
+}
+
+// Curve implements FilmicCurve.
+func (c *AgXCurve) Curve(x float64) float64 {
+	v := c.contrast(c.encode(x))
+	v = math.Pow(math.Max(v*c.Look.Slope[1]+c.Look.Offset, 0), c.Look.Power[1])
+	return c.eotf(v)
+}
+
+// CurveRGB implements filmicRGBCurve.
+func (c *AgXCurve) CurveRGB(r, g, b float64) (float64, float64, float64) {
+	v := agxInset.mulVec([3]float64{r, g, b})
+	for i := range v {
+		v[i] = c.contrast(c.encode(v[i]))
+	}
+
+	// Look (the saturation is applied around the luma of the graded values)
+	for i := range v {
+		v[i] = math.Pow(math.Max(v[i]*c.Look.Slope[i]+c.Look.Offset, 0), c.Look.Power[i])
+	}
+	luma := 0.2126*v[0] + 0.7152*v[1] + 0.0722*v[2]
+	for i := range v {
+		v[i] = luma + c.Look.Saturation*(v[i]-luma)
+	}
+
+	v = agxOutset.mulVec(v)
+	return c.eotf(v[0]), c.eotf(v[1]), c.eotf(v[2])
+}
+
+// DefaultWhitePoint implements FilmicCurve.
+func (c *AgXCurve) DefaultWhitePoint() float64 {
+	return math.Exp2(c.MaxEV)
+}
+
+// encode converts a linear value into the AgX log2 encoding.
+func (c *AgXCurve) encode(x float64) float64 {
+	v := xmath.ClampF64(c.MinEV, c.MaxEV, math.Log2(math.Max(x, 1e-10)))
+	return (v - c.MinEV) / (c.MaxEV - c.MinEV)
+}
+
+// contrast is the 6th order polynomial approximation of the AgX sigmoid.
+func (c *AgXCurve) contrast(x float64) float64 {
+	x2 := x * x
+	x4 := x2 * x2
+	return 15.5*x4*x2 - 40.14*x4*x + 31.96*x4 - 6.868*x2*x + 0.4298*x2 + 0.1191*x - 0.00232
+}
+
+// eotf linearizes the AgX display encoding.
+func (c *AgXCurve) eotf(v float64) float64 {
+	return math.Pow(math.Max(v, 0), 2.2)
+}
@@ -2,15 +2,15 @@ package tmo
 
 import (
 	"image"
-	"image/color"
+	"image/draw"
 	"math"
+	"sync"
 
 	colorful "github.com/lucasb-eyer/go-colorful"
 	"github.com/mdouchement/hdr"
 	"github.com/mdouchement/hdr/filter"
 	"github.com/mdouchement/hdr/hdrcolor"
 	"github.com/mdouchement/hdr/xmath"
-	"github.com/mdouchement/hdr/parallel"
 )
 
 const (
@@ -46,10 +46,22 @@ type ICam06 struct {
 	width          int
 	height         int
 	maxLum         float64
+	lumOnce        sync.Once
 	normalized     hdr.Image
 	baseLayer      hdr.Image
 	white          hdr.Image
+	sw             float64
 	detailCombined hdr.Image
+	analysisOnce   sync.Once
+	fixedClipping  bool // Use minRGB and maxRGB instead of computing the percentiles
+	minRGB         float64
+	maxRGB         float64
+	// Region rendering
+	proxyOnce  sync.Once
+	proxy      *ICam06
+	proxyScale float64
+	proxyGuide *filter.Plane
+	executable
 }
 
 // NewDefaultICam06 instanciates a new ICam06 TMO with default parameters.
@@ -73,30 +85,16 @@ func NewICam06(m hdr.Image, contrast, minClipping, maxClipping float64) *ICam06
 
 // Perform runs the TMO mapping.
 func (t *ICam06) Perform() image.Image {
+	o := NewOutput(EncodingSRGB)
+	o.InversePixelMapping = false
+	return t.encode(o, t.PerformHDR())
+}
+
+// PerformHDR runs the TMO mapping and returns display-referred linear values.
+func (t *ICam06) PerformHDR() hdr.Image {
 	// Note: Section & Equation numbers come from the PDF paper.
 	//
-	// Input normalization
-	t.luminance()
-	t.normalized = filter.NewApply1(t.HDRImage, func(c1 hdrcolor.Color, _ hdrcolor.Color) hdrcolor.Color {
-		x, y, z, _ := c1.HDRXYZA()
-		return hdrcolor.XYZ{
-			X: math.Max(0.00000001, maxLum*(x/t.maxLum)),
-			Y: math.Max(0.00000001, maxLum*(y/t.maxLum)),
-			Z: math.Max(0.00000001, maxLum*(z/t.maxLum)),
-		}
-	})
-	//
-	// Decomposing the image into base layer  - Section 2.2
-	log := filter.NewLog10(t.normalized)
-	bilateral := filter.NewYFastBilateralAuto(log) // Better blur with log10 values
-	bilateral.SigmaSpace = float64(t.minDim()) * 0.02
-	bilateral.Perform()
-	t.baseLayer = filter.NewPow10(bilateral) // bilateral filter + un-log10 values
-	//
-	//
-	// Chromatic adaptation (White adaptation) - Section 2.3
-	t.white = filter.FastGaussian(t.normalized, (t.minDim() / 2))
-	//
+	t.analysisOnce.Do(t.analysis) // Parameter-independent layers
 	//
 	// Non-linear tone compression - Section 2.4
 	toneCompressed := t.toneCompression() // with chromatic adaptation included
@@ -126,7 +124,7 @@ func (t *ICam06) Perform() image.Image {
 	//
 	//
 	// Image attribute adjustments - Section 2.6
-	t.detailCombined = filter.NewApply2(toneCompressed, detailLayer, func(c1, c2 hdrcolor.Color) hdrcolor.Color {
+	detailCombined := filter.NewApply2(toneCompressed, detailLayer, func(c1, c2 hdrcolor.Color) hdrcolor.Color {
 		x1, y1, z1, _ := c1.HDRXYZA()
 		x2, y2, z2, _ := c2.HDRXYZA()
 
@@ -136,24 +134,118 @@ func (t *ICam06) Perform() image.Image {
 			Z: z1 * z2,
 		}
 	})
+	t.detailCombined = filter.MaterializeWithExecutor(t.executor, detailCombined) // Read for each pass of the normalization
 	//
 	//
-	m := image.NewRGBA64(t.HDRImage.Bounds())
+	m := hdr.NewRGB64(t.HDRImage.Bounds())
 	t.normalize(m) // colorfullnessXsurround + ldr scale
 	return m
 }
 
+// analysis computes the layers that do not depend on the parameters,
+// they are reused by the next Perform calls.
+func (t *ICam06) analysis() {
+	// Input normalization
+	t.lumOnce.Do(t.luminance)
+	t.normalized = filter.MaterializeWithExecutor(t.executor, t.normalizeInput())
+	//
+	// Decomposing the image into base layer  - Section 2.2
+	log := filter.NewLog10(t.normalized)
+	bilateral := filter.NewYFastBilateralAuto(log) // Better blur with log10 values
+	bilateral.SigmaSpace = float64(t.minDim()) * 0.02
+	bilateral.Executor = t.executor
+	bilateral.Perform()
+	t.baseLayer = filter.MaterializeWithExecutor(t.executor, filter.NewPow10(bilateral)) // bilateral filter + un-log10 values
+	//
+	//
+	// Chromatic adaptation (White adaptation) - Section 2.3
+	t.white = filter.FastGaussianWithExecutor(t.executor, t.normalized, (t.minDim() / 2))
+	t.whiteScale()
+}
+
+// Statistics computes (once) and returns the adaptation statistics of the HDR image.
+// Only MaxLum, the luminance normalized to the iCAM06 maximum luminance, is used.
+func (t *ICam06) Statistics() Statistics {
+	t.lumOnce.Do(t.luminance)
+	return Statistics{MaxLum: t.maxLum}
+}
+
+// SetStatistics overrides the adaptation statistics used by Perform.
+// It has no effect once the parameter-independent layers are computed by a first Perform.
+func (t *ICam06) SetStatistics(s Statistics) {
+	t.lumOnce.Do(func() {})
+	t.maxLum = s.MaxLum
+}
+
+// RenderRegion implements RegionOperator, the layers and the clipping are computed on a proxy of the whole image.
+// The base layer and the white adaptation image are upsampled with the region luminance as guide (joint bilateral upsampling).
+func (t *ICam06) RenderRegion(dst draw.Image, r image.Rectangle, scale float64) {
+	t.proxyOnce.Do(func() {
+		proxy, s := regionProxy(t.executor, t.HDRImage)
+		t.proxy = NewICam06(proxy, t.Contrast, t.MinClipping, t.MaxClipping)
+		t.proxy.executor = t.executor
+		t.proxy.analysisOnce.Do(t.proxy.analysis)
+		t.proxyScale = s
+		t.proxyGuide = log10Luminance(t.executor, t.proxy.normalized)
+	})
+	if t.executor.Err() != nil {
+		return
+	}
+
+	// Global clipping with the current parameters
+	t.proxy.Contrast, t.proxy.MinClipping, t.proxy.MaxClipping = t.Contrast, t.MinClipping, t.MaxClipping
+	t.proxy.PerformHDR()
+
+	region := NewICam06(filter.ResampleWithExecutor(t.executor, t.HDRImage, r, scale), t.Contrast, t.MinClipping, t.MaxClipping)
+	region.executor = t.executor
+	region.maxLum = t.proxy.maxLum
+	region.normalized = filter.MaterializeWithExecutor(t.executor, region.normalizeInput())
+
+	guide := log10Luminance(t.executor, region.normalized)
+	region.baseLayer = upsampleLayer(t.executor, t.HDRImage, t.proxy.baseLayer, t.proxyGuide, guide, r, scale, t.proxyScale, regionSigmaRange)
+	region.white = upsampleLayer(t.executor, t.HDRImage, t.proxy.white, t.proxyGuide, guide, r, scale, t.proxyScale, math.Inf(1)) // Smooth enough to ignore the edges
+	region.sw = t.proxy.sw
+	region.minRGB, region.maxRGB, region.fixedClipping = t.proxy.minRGB, t.proxy.maxRGB, true
+	region.analysisOnce.Do(func() {})
+
+	drawRegion(dst, region.Perform())
+}
+
+// normalizeInput scales the input luminances to maxLum.
+func (t *ICam06) normalizeInput() hdr.Image {
+	return filter.NewApply1(t.HDRImage, func(c1 hdrcolor.Color, _ hdrcolor.Color) hdrcolor.Color {
+		x, y, z, _ := c1.HDRXYZA()
+		return hdrcolor.XYZ{
+			X: math.Max(0.00000001, maxLum*(x/t.maxLum)),
+			Y: math.Max(0.00000001, maxLum*(y/t.maxLum)),
+			Z: math.Max(0.00000001, maxLum*(z/t.maxLum)),
+		}
+	})
+}
+
+// whiteScale computes the global scale from the maximum value of the local adapted white point image.
+func (t *ICam06) whiteScale() {
+	t.sw = math.Inf(-1)
+	d := t.white.Bounds()
+	for y := d.Min.Y; y < d.Max.Y; y++ {
+		for x := d.Min.X; x < d.Max.X; x++ {
+			_, Yw, _, _ := t.white.HDRAt(x, y).HDRXYZA()
+			t.sw = math.Max(t.sw, Yw)
+		}
+	}
+}
+
 func (t *ICam06) luminance() {
 	maxCh := make(chan float64)
 
-	completed := parallel.TilesR(t.HDRImage.Bounds(), func(x1, y1, x2, y2 int) {
+	completed := t.executor.TilesR(t.HDRImage.Bounds(), func(x1, y1, x2, y2 int) {
 		max := math.Inf(-1)
 
 		for y := y1; y < y2; y++ {
 			for x := x1; x < x2; x++ {
 				_, lum, _, _ := t.HDRImage.HDRAt(x, y).HDRXYZA()
 
-				max = math.Max(t.maxLum, lum)
+				max = math.Max(max, lum)
 			}
 		}
 
@@ -190,17 +282,9 @@ func (t *ICam06) chromaticAdaptation(x, y int) (float64, float64, float64) {
 
 // Aka tonemap.
 func (t *ICam06) toneCompression() hdr.Image {
-	toneCompressed := t.white.(hdr.ImageSet) // Reuse memory allocation by updating in-place the raster
-
-	Sw := math.Inf(-1) // Global scale from the maximum value of the local adapted white point image
-	for y := 0; y < t.height; y++ {
-		for x := 0; x < t.width; x++ {
-			_, Yw, _, _ := t.white.HDRAt(x, y).HDRXYZA()
-			Sw = math.Max(Sw, Yw)
-		}
-	}
+	toneCompressed := hdr.EmptyAs(t.white).(hdr.ImageSet) // The white image is kept for the next Perform calls
 
-	completed := parallel.TilesR(t.HDRImage.Bounds(), func(x1, y1, x2, y2 int) {
+	completed := t.executor.TilesR(t.HDRImage.Bounds(), func(x1, y1, x2, y2 int) {
 		for y := y1; y < y2; y++ {
 			for x := x1; x < x2; x++ {
 				_, Yw, _, _ := t.white.HDRAt(x, y).HDRXYZA() // Yw is the luminance of the local adapted white image
@@ -241,7 +325,7 @@ func (t *ICam06) toneCompression() hdr.Image {
 				FLS := 3800*(j*j)*Lls + 0.2*math.Pow(1-(j*j), 4)*math.Pow(Lls, 1.0/6.0) // Equation 16, scotopic luminance level adaptation factor
 
 				S := math.Abs(Yca) // Luminance of each pixel in the chromatic adapted image
-				St := S / Sw
+				St := S / t.sw
 				// BS is the rod pigment bleach or satruration factor
 				Bs := 0.5/(1+0.3*math.Pow(Lls*St, 0.3)) + 0.5/(1+5*Lls) // Equation 19
 				// Noise term in Rod response is 1 / 3 of that in Cone response because Rods are more sensitive
@@ -260,7 +344,6 @@ func (t *ICam06) toneCompression() hdr.Image {
 
 	<-completed
 
-	t.white = nil // No longer used and was replaced by `toneCompressed` raster
 	return toneCompressed.(hdr.Image)
 }
 
@@ -312,34 +395,38 @@ func (t *ICam06) colorfullnessXsurround(x, y int) hdrcolor.Color {
 	return t.reverseIptColor(I, P, T) // Inverse CAT
 }
 
-func (t *ICam06) normalize(m *image.RGBA64) {
+func (t *ICam06) normalize(m *hdr.RGB64) {
 	normLum := t.normalizeLDRLuminanceFn()
 
-	// Percentile
-	size := t.HDRImage.Size()
-	perc := make(percentiles, size*3) // FIXME high memory consumption => only 2 values are needed minRGB && maxRGB
+	if !t.fixedClipping {
+		// Percentile
+		d := t.HDRImage.Bounds()
+		size := t.HDRImage.Size()
+		perc := make(percentiles, size*3) // FIXME high memory consumption => only 2 values are needed minRGB && maxRGB
 
-	completed := parallel.TilesR(t.HDRImage.Bounds(), func(x1, y1, x2, y2 int) {
-		for y := y1; y < y2; y++ {
-			for x := x1; x < x2; x++ {
-				r, g, b := normLum(x, y)
+		completed := t.executor.TilesR(d, func(x1, y1, x2, y2 int) {
+			for y := y1; y < y2; y++ {
+				for x := x1; x < x2; x++ {
+					r, g, b := normLum(x, y)
 
-				// Clipping, first part
-				i := x * y
-				perc[i] = r
-				perc[size+i] = g
-				perc[size*2+i] = b
+					// Clipping, first part
+					i := (y-d.Min.Y)*t.width + x - d.Min.X
+					perc[i] = r
+					perc[size+i] = g
+					perc[size*2+i] = b
+				}
 			}
-		}
-	})
+		})
 
-	<-completed
+		<-completed
 
-	perc.sort()
-	minRGB := math.Min(perc.percentile(t.MinClipping), 0)
-	maxRGB := perc.percentile(t.MaxClipping)
+		perc.sort()
+		t.minRGB = math.Min(perc.percentile(t.MinClipping), 0)
+		t.maxRGB = perc.percentile(t.MaxClipping)
+	}
 
-	completed = parallel.TilesR(t.HDRImage.Bounds(), func(x1, y1, x2, y2 int) {
+	minRGB, maxRGB := t.minRGB, t.maxRGB
+	completed := t.executor.TilesR(t.HDRImage.Bounds(), func(x1, y1, x2, y2 int) {
 		for y := y1; y < y2; y++ {
 			for x := x1; x < x2; x++ {
 				r, g, b := normLum(x, y)
@@ -349,13 +436,7 @@ func (t *ICam06) normalize(m *image.RGBA64) {
 				g = xmath.ClampF64(0, 1, (g-minRGB)/(maxRGB-minRGB))
 				b = xmath.ClampF64(0, 1, (b-minRGB)/(maxRGB-minRGB))
 
-				// RGB normalization
-				m.SetRGBA64(x, y, color.RGBA64{
-					R: t.normalizeC(r),
-					G: t.normalizeC(g),
-					B: t.normalizeC(b),
-					A: RangeMax,
-				})
+				m.SetRGB(x, y, hdrcolor.RGB{R: r, G: g, B: b})
 			}
 		}
 	})
@@ -363,26 +444,19 @@ func (t *ICam06) normalize(m *image.RGBA64) {
 	<-completed
 }
 
-func (t *ICam06) normalizeC(channel float64) uint16 {
-	// c := WoB(channel < -0.0031308) * (math.Pow(-1.055*-channel, 1/2.4) + 0.055)
-	c := WoB((channel >= -0.0031308) && (channel <= 0.0031308)) * channel * 12.92
-	c += WoB(channel > 0.0031308) * (math.Pow(channel, 1/2.4)*1.055 - 0.055)
-	return uint16(RangeMax * c)
-}
-
 func (t *ICam06) normalizeLDRLuminanceFn() func(x, y int) (r, g, b float64) {
 	if ICamNormalizeLDRLuminance {
 		norMaxLum := math.Inf(-1)
 		maxCh := make(chan float64)
 
-		completed := parallel.TilesR(t.HDRImage.Bounds(), func(x1, y1, x2, y2 int) {
+		completed := t.executor.TilesR(t.HDRImage.Bounds(), func(x1, y1, x2, y2 int) {
 			var max float64
 
 			for y := y1; y < y2; y++ {
 				for x := x1; x < x2; x++ {
 					_, lum, _, _ := t.colorfullnessXsurround(x, y).HDRXYZA() // FIXME perf-1
 
-					max = math.Max(t.maxLum, lum)
+					max = math.Max(max, lum)
 				}
 			}
 
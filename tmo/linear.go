@@ -2,15 +2,22 @@ package tmo
 
 import (
 	"image"
-	"image/color"
+	"image/draw"
+	"sync"
 
 	"github.com/mdouchement/hdr"
-	"github.com/mdouchement/hdr/parallel"
+	"github.com/mdouchement/hdr/filter"
+	"github.com/mdouchement/hdr/hdrcolor"
 )
 
 // A Linear is a naive TMO implementation.
 type Linear struct {
-	HDRImage hdr.Image
+	HDRImage  hdr.Image
+	rangeOnce sync.Once
+	rmm       *minmax
+	gmm       *minmax
+	bmm       *minmax
+	executable
 }
 
 // NewLinear instanciates a new Linear TMO.
@@ -22,7 +29,12 @@ func NewLinear(m hdr.Image) *Linear {
 
 // Perform runs the TMO mapping.
 func (t *Linear) Perform() image.Image {
-	img := image.NewRGBA64(t.HDRImage.Bounds())
+	return t.encode(t.output(), t.PerformHDR())
+}
+
+// PerformHDR runs the TMO mapping and returns display-referred linear values.
+func (t *Linear) PerformHDR() hdr.Image {
+	img := hdr.NewRGB64(t.HDRImage.Bounds())
 
 	rmm, gmm, bmm := t.minmax()
 
@@ -31,18 +43,42 @@ func (t *Linear) Perform() image.Image {
 	return img
 }
 
+// RenderRegion implements RegionOperator, the channel ranges are computed once on the whole image
+// (the extremes would be averaged out on a proxy).
+func (t *Linear) RenderRegion(dst draw.Image, r image.Rectangle, scale float64) {
+	t.rangeOnce.Do(func() {
+		t.rmm, t.gmm, t.bmm = t.minmax()
+	})
+
+	region := NewLinear(filter.ResampleWithExecutor(t.executor, t.HDRImage, r, scale))
+	region.executor = t.executor
+
+	img := hdr.NewRGB64(region.HDRImage.Bounds())
+	region.shiftRescale(img, t.rmm, t.gmm, t.bmm)
+	drawRegion(dst, region.encode(t.output(), img))
+}
+
+// output returns the display encoding, the values are truncated as before the Output stage.
+func (t *Linear) output() Output {
+	o := NewOutput(EncodingLinear)
+	o.InversePixelMapping = false
+	o.Truncate = true
+	return o
+}
+
 //nolint[dupl]
 func (t *Linear) minmax() (rmm, gmm, bmm *minmax) {
 	rmm, gmm, bmm = newMinMax(), newMinMax(), newMinMax()
 	mmCh := make(chan []*minmax)
 
-	completed := parallel.TilesR(t.HDRImage.Bounds(), func(x1, y1, x2, y2 int) {
+	completed := t.executor.TilesR(t.HDRImage.Bounds(), func(x1, y1, x2, y2 int) {
 		rrmm, ggmm, bbmm := newMinMax(), newMinMax(), newMinMax()
 
+		row := make([]float64, 3*(x2-x1))
 		for y := y1; y < y2; y++ {
-			for x := x1; x < x2; x++ {
-				pixel := t.HDRImage.HDRAt(x, y)
-				r, g, b, _ := pixel.HDRRGBA()
+			hdr.ReadRow(t.HDRImage, row, x1, x2, y, hdr.RGBSpace)
+			for i := 0; i < len(row); i += 3 {
+				r, g, b := row[i+0], row[i+1], row[i+2]
 
 				rrmm.update(r)
 				ggmm.update(g)
@@ -70,18 +106,18 @@ func (t *Linear) minmax() (rmm, gmm, bmm *minmax) {
 	}
 }
 
-func (t *Linear) shiftRescale(img *image.RGBA64, rmm, gmm, bmm *minmax) {
-	completed := parallel.TilesR(t.HDRImage.Bounds(), func(x1, y1, x2, y2 int) {
+func (t *Linear) shiftRescale(img *hdr.RGB64, rmm, gmm, bmm *minmax) {
+	completed := t.executor.TilesR(t.HDRImage.Bounds(), func(x1, y1, x2, y2 int) {
+		row := make([]float64, 3*(x2-x1))
 		for y := y1; y < y2; y++ {
-			for x := x1; x < x2; x++ {
-				pixel := t.HDRImage.HDRAt(x, y)
-				r, g, b, _ := pixel.HDRRGBA()
+			hdr.ReadRow(t.HDRImage, row, x1, x2, y, hdr.RGBSpace)
+			for x, i := x1, 0; x < x2; x, i = x+1, i+3 {
+				r, g, b := row[i+0], row[i+1], row[i+2]
 
-				img.SetRGBA64(x, y, color.RGBA64{
+				img.SetRGB(x, y, hdrcolor.RGB{
 					R: shiftRescale(r, rmm),
 					G: shiftRescale(g, gmm),
 					B: shiftRescale(b, bmm),
-					A: RangeMax,
 				})
 			}
 		}
@@ -90,12 +126,6 @@ func (t *Linear) shiftRescale(img *image.RGBA64, rmm, gmm, bmm *minmax) {
 	<-completed
 }
 
-func shiftRescale(channel float64, mm *minmax) uint16 {
-	if channel < RangeMin {
-		channel = RangeMax * (channel + mm.min*-1) / (mm.max + (mm.min * -1))
-	} else {
-		channel = RangeMax * (channel - mm.min) / (mm.max - mm.min)
-	}
-
-	return uint16(channel)
+func shiftRescale(channel float64, mm *minmax) float64 {
+	return (channel - mm.min) / (mm.max - mm.min)
 }
@@ -0,0 +1,180 @@
+package tmo
+
+import (
+	"image"
+	"math"
+
+	"github.com/mdouchement/hdr"
+	"github.com/mdouchement/hdr/filter"
+	"github.com/mdouchement/hdr/xmath"
+)
+
+const (
+	localLaplacianGamma        = 2.2
+	localLaplacianMinLevelSize = 4    // Coarsest pyramid level size
+	localLaplacianSamples      = 12   // Number of intensity samples (reference values γ)
+	localLaplacianNoise        = 0.01 // Detail below this level are not amplified
+)
+
+// A LocalLaplacian is an edge-aware TMO implementation based on Sylvain Paris's 2011 white paper
+// with the fast intensity sampling of Mathieu Aubry's 2014 white paper.
+// The log-luminance Laplacian pyramid is built from a set of point-wise remapped images
+// so the details are manipulated without halo artifacts.
+//
+// The intensity samples are processed one at a time: besides the output, the mapping keeps about
+// 6 float64 planes of the image size (input Gaussian pyramid, output Laplacian pyramid and the pyramids
+// of the current remapped image), about 2.4 GB for a 50 MP image.
+//
+// Reference:
+// Local Laplacian Filters: Edge-aware Image Processing with a Laplacian Pyramid.
+// S. Paris, S. W. Hasinoff and J. Kautz.
+// In ACM Transactions on Graphics (SIGGRAPH), 2011.
+//
+// Fast Local Laplacian Filters: Theory and Applications.
+// M. Aubry, S. Paris, S. W. Hasinoff, J. Kautz and F. Durand.
+// In ACM Transactions on Graphics, 2014.
+type LocalLaplacian struct {
+	HDRImage hdr.Image
+	// Detail is included in [0.01, 2] with 0.01 increment step (α).
+	// Values lower than 1 enhance the details, values greater than 1 smooth them.
+	Detail float64
+	// RangeCompression is included in [0, 1] with 0.01 increment step (β).
+	// Lower values compress more the edges (large-scale variations).
+	RangeCompression float64
+	// Sigma is included in [0.1, 3] with 0.01 increment step (σr).
+	// It is the log-luminance threshold between details and edges.
+	Sigma float64
+	// Saturation is included in [0, 2] with 0.01 increment step.
+	Saturation float64
+	// MinClipping is included in [0, 1] with 0.001 increment step (black percentile).
+	MinClipping float64
+	// MaxClipping is included in [0, 1] with 0.001 increment step (white percentile).
+	MaxClipping float64
+	executable
+}
+
+// NewDefaultLocalLaplacian instanciates a new LocalLaplacian TMO with default parameters.
+func NewDefaultLocalLaplacian(m hdr.Image) *LocalLaplacian {
+	return NewLocalLaplacian(m, 0.5, 0.2, math.Log(2.5))
+}
+
+// NewLocalLaplacian instanciates a new LocalLaplacian TMO.
+func NewLocalLaplacian(m hdr.Image, detail, rangeCompression, sigma float64) *LocalLaplacian {
+	return &LocalLaplacian{
+		HDRImage:         m,
+		Detail:           detail,
+		RangeCompression: rangeCompression,
+		Sigma:            sigma,
+		Saturation:       0.8,
+		MinClipping:      0.001,
+		MaxClipping:      0.995,
+	}
+}
+
+// Perform runs the TMO mapping.
+func (t *LocalLaplacian) Perform() image.Image {
+	return t.encode(NewGammaOutput(localLaplacianGamma), t.PerformHDR())
+}
+
+// PerformHDR runs the TMO mapping and returns display-referred linear values.
+func (t *LocalLaplacian) PerformHDR() hdr.Image {
+	d := t.HDRImage.Bounds()
+	img := hdr.NewRGB64(d)
+
+	// Log-luminance
+	L := logLuminance(t.executor, t.HDRImage, func(lum float64) float64 {
+		return math.Log(math.Max(lum, 0) + 1e-6)
+	})
+	minL, maxL := math.Inf(1), math.Inf(-1)
+	for _, v := range L.Pix {
+		minL = math.Min(minL, v)
+		maxL = math.Max(maxL, v)
+	}
+
+	levels := filter.PyramidLevels(L.Width, L.Height, localLaplacianMinLevelSize)
+	gaussian := filter.GaussianPyramidWithExecutor(t.executor, L, levels)
+
+	step := (maxL - minL) / (localLaplacianSamples - 1)
+	if step <= 0 {
+		step = 1
+	}
+
+	// Output Laplacian pyramid interpolated from the Laplacian pyramids of the remapped images
+	// according to the input Gaussian pyramid.
+	// The reference values are processed one at a time and accumulated with their interpolation weight.
+	output := make([]*filter.Plane, levels)
+	for k := 0; k < levels-1; k++ {
+		output[k] = filter.NewPlane(gaussian[k].Width, gaussian[k].Height)
+	}
+
+	remapped := filter.NewPlane(L.Width, L.Height)
+	for j := 0; j < localLaplacianSamples && t.executor.Err() == nil; j++ {
+		g := minL + float64(j)*step
+
+		completed := t.executor.Lines(L.Height, func(y1, y2 int) {
+			for i := y1 * L.Width; i < y2*L.Width; i++ {
+				remapped.Pix[i] = t.remap(L.Pix[i], g)
+			}
+		})
+		<-completed
+
+		reference := filter.LaplacianPyramidWithExecutor(t.executor, remapped, levels)
+		for k := 0; k < levels-1; k++ {
+			t.accumulate(output[k], reference[k], gaussian[k], j, minL, step)
+		}
+	}
+
+	if t.executor.Err() != nil {
+		return img // Cancelled, some references are missing
+	}
+
+	// The low-pass residual holds the large-scale variations which are compressed around their mean
+	residual := gaussian[levels-1].Copy()
+	mean := xmath.PairwiseSum(residual.Pix) / float64(len(residual.Pix))
+	for i, v := range residual.Pix {
+		residual.Pix[i] = mean + t.RangeCompression*(v-mean)
+	}
+	output[levels-1] = residual
+
+	I := filter.CollapseLaplacianWithExecutor(t.executor, output)
+
+	tonemapLuminance(t.executor, img, t.HDRImage, I.Pix, math.Exp, t.Saturation, t.MinClipping, t.MaxClipping)
+
+	return img
+}
+
+// accumulate adds to dst the level of the reference j weighted by the linear interpolation
+// of the references at the values of the input Gaussian level g.
+func (t *LocalLaplacian) accumulate(dst, reference, g *filter.Plane, j int, minL, step float64) {
+	completed := t.executor.Lines(g.Height, func(y1, y2 int) {
+		for i := y1 * g.Width; i < y2*g.Width; i++ {
+			fj := xmath.ClampF64(0, localLaplacianSamples-1, (g.Pix[i]-minL)/step)
+			n := xmath.Clamp(0, localLaplacianSamples-2, int(fj))
+			a := fj - float64(n)
+
+			switch j {
+			case n:
+				dst.Pix[i] += (1 - a) * reference.Pix[i]
+			case n + 1:
+				dst.Pix[i] += a * reference.Pix[i]
+			}
+		}
+	})
+	<-completed
+}
+
+// remap is the point-wise remapping function r(i) of the reference value g.
+// It applies the detail curve under Sigma and the edge (range compression) curve above.
+func (t *LocalLaplacian) remap(i, g float64) float64 {
+	delta := i - g
+	abs := math.Abs(delta)
+
+	if abs <= t.Sigma {
+		// Blend with the identity for noise level differences to avoid their amplification
+		s := smoothstep(localLaplacianNoise, 2*localLaplacianNoise, abs)
+		detail := s*math.Pow(abs/t.Sigma, t.Detail) + (1-s)*abs/t.Sigma
+		return g + math.Copysign(t.Sigma*detail, delta)
+	}
+
+	return g + math.Copysign(t.RangeCompression*(abs-t.Sigma)+t.Sigma, delta)
+}
@@ -2,16 +2,24 @@ package tmo
 
 import (
 	"image"
-	"image/color"
+	"image/draw"
 	"math"
+	"sync"
 
 	"github.com/mdouchement/hdr"
-	"github.com/mdouchement/hdr/parallel"
+	"github.com/mdouchement/hdr/filter"
+	"github.com/mdouchement/hdr/hdrcolor"
 )
 
 // A Logarithmic is a naive TMO implementation.
+// Values closer than 1 to the channel minimum have a negative logarithm and are clipped to black.
 type Logarithmic struct {
-	HDRImage hdr.Image
+	HDRImage  hdr.Image
+	rangeOnce sync.Once
+	rmm       *minmax
+	gmm       *minmax
+	bmm       *minmax
+	executable
 }
 
 // NewLogarithmic instanciates a new Logarithmic TMO.
@@ -23,7 +31,12 @@ func NewLogarithmic(m hdr.Image) *Logarithmic {
 
 // Perform runs the TMO mapping.
 func (t *Logarithmic) Perform() image.Image {
-	img := image.NewRGBA64(t.HDRImage.Bounds())
+	return t.encode(t.output(), t.PerformHDR())
+}
+
+// PerformHDR runs the TMO mapping and returns display-referred linear values.
+func (t *Logarithmic) PerformHDR() hdr.Image {
+	img := hdr.NewRGB64(t.HDRImage.Bounds())
 
 	rmm, gmm, bmm := t.minmax()
 
@@ -32,18 +45,42 @@ func (t *Logarithmic) Perform() image.Image {
 	return img
 }
 
+// RenderRegion implements RegionOperator, the channel ranges are computed once on the whole image
+// (the extremes would be averaged out on a proxy).
+func (t *Logarithmic) RenderRegion(dst draw.Image, r image.Rectangle, scale float64) {
+	t.rangeOnce.Do(func() {
+		t.rmm, t.gmm, t.bmm = t.minmax()
+	})
+
+	region := NewLogarithmic(filter.ResampleWithExecutor(t.executor, t.HDRImage, r, scale))
+	region.executor = t.executor
+
+	img := hdr.NewRGB64(region.HDRImage.Bounds())
+	region.shiftLogRescale(img, t.rmm, t.gmm, t.bmm)
+	drawRegion(dst, region.encode(t.output(), img))
+}
+
+// output returns the display encoding, the values are truncated as before the Output stage.
+func (t *Logarithmic) output() Output {
+	o := NewOutput(EncodingLinear)
+	o.InversePixelMapping = false
+	o.Truncate = true
+	return o
+}
+
 //nolint[dupl]
 func (t *Logarithmic) minmax() (rmm, gmm, bmm *minmax) {
 	rmm, gmm, bmm = newMinMax(), newMinMax(), newMinMax()
 	mmCh := make(chan []*minmax)
 
-	completed := parallel.TilesR(t.HDRImage.Bounds(), func(x1, y1, x2, y2 int) {
+	completed := t.executor.TilesR(t.HDRImage.Bounds(), func(x1, y1, x2, y2 int) {
 		rrmm, ggmm, bbmm := newMinMax(), newMinMax(), newMinMax()
 
+		row := make([]float64, 3*(x2-x1))
 		for y := y1; y < y2; y++ {
-			for x := x1; x < x2; x++ {
-				pixel := t.HDRImage.HDRAt(x, y)
-				r, g, b, _ := pixel.HDRRGBA()
+			hdr.ReadRow(t.HDRImage, row, x1, x2, y, hdr.RGBSpace)
+			for i := 0; i < len(row); i += 3 {
+				r, g, b := row[i+0], row[i+1], row[i+2]
 
 				rrmm.update(r)
 				ggmm.update(g)
@@ -71,21 +108,21 @@ func (t *Logarithmic) minmax() (rmm, gmm, bmm *minmax) {
 	}
 }
 
-func (t *Logarithmic) shiftLogRescale(img *image.RGBA64, rmm, gmm, bmm *minmax) {
+func (t *Logarithmic) shiftLogRescale(img *hdr.RGB64, rmm, gmm, bmm *minmax) {
 	// Calculate max for rescale
 	rmax, gmax, bmax := logMax(rmm), logMax(gmm), logMax(bmm)
 
-	completed := parallel.TilesR(t.HDRImage.Bounds(), func(x1, y1, x2, y2 int) {
+	completed := t.executor.TilesR(t.HDRImage.Bounds(), func(x1, y1, x2, y2 int) {
+		row := make([]float64, 3*(x2-x1))
 		for y := y1; y < y2; y++ {
-			for x := x1; x < x2; x++ {
-				pixel := t.HDRImage.HDRAt(x, y)
-				r, g, b, _ := pixel.HDRRGBA()
+			hdr.ReadRow(t.HDRImage, row, x1, x2, y, hdr.RGBSpace)
+			for x, i := x1, 0; x < x2; x, i = x+1, i+3 {
+				r, g, b := row[i+0], row[i+1], row[i+2]
 
-				img.SetRGBA64(x, y, color.RGBA64{
+				img.SetRGB(x, y, hdrcolor.RGB{
 					R: shiftLogRescale(r, rmm, rmax),
 					G: shiftLogRescale(g, gmm, gmax),
 					B: shiftLogRescale(b, bmm, bmax),
-					A: RangeMax,
 				})
 			}
 		}
@@ -94,23 +131,10 @@ func (t *Logarithmic) shiftLogRescale(img *image.RGBA64, rmm, gmm, bmm *minmax)
 	<-completed
 }
 
-func shiftLogRescale(channel float64, mm *minmax, max float64) uint16 {
-	// ShiftLog
-	if channel < RangeMin {
-		channel = math.Log(channel + mm.min*-1)
-	} else {
-		channel = math.Log(channel - mm.min)
-	}
-
-	// Rescale
-	channel = RangeMax * channel / max
-
-	return uint16(channel)
+func shiftLogRescale(channel float64, mm *minmax, max float64) float64 {
+	return math.Log(channel-mm.min) / max
 }
 
 func logMax(mm *minmax) float64 {
-	if mm.min < RangeMin {
-		return math.Log(mm.max + (mm.min * -1))
-	}
 	return math.Log(mm.max - mm.min)
 }
@@ -0,0 +1,316 @@
+package tmo
+
+import (
+	"image"
+	"math"
+	"sort"
+
+	"github.com/mdouchement/hdr"
+	"github.com/mdouchement/hdr/filter"
+	"github.com/mdouchement/hdr/parallel"
+	"github.com/mdouchement/hdr/xmath"
+)
+
+const (
+	mantiukGamma        = 2.2
+	mantiukMinLevelSize = 8 // Coarsest pyramid level size
+	mantiukIterations   = 200
+	mantiukTolerance    = 1e-3
+	// Transducer function coefficients (contrast discrimination data)
+	mantiukTransducerA = 54.09288
+	mantiukTransducerB = 0.41850
+)
+
+// Mantiuk06Mode defines how the contrast is processed.
+type Mantiuk06Mode int
+
+const (
+	// Mantiuk06ContrastMapping scales the perceived contrast (transducer response) of all frequencies.
+	Mantiuk06ContrastMapping Mantiuk06Mode = iota
+	// Mantiuk06ContrastEqualization equalizes the contrast magnitudes across all frequencies.
+	Mantiuk06ContrastEqualization
+)
+
+// A Mantiuk06 is a contrast domain TMO implementation based on Rafal Mantiuk's 2006 white paper.
+// The log-luminance is represented as a multi-resolution set of contrasts which are compressed
+// in a perceptual space and the luminance is reconstructed with a conjugate gradient solver.
+//
+// Reference:
+// A Perceptual Framework for Contrast Processing of High Dynamic Range Images.
+// R. Mantiuk, K. Myszkowski and H.-P. Seidel.
+// In ACM Transactions on Applied Perception, 2006.
+type Mantiuk06 struct {
+	HDRImage hdr.Image
+	Mode     Mantiuk06Mode
+	// ContrastFactor is included in [0.01, 1] with 0.01 increment step.
+	// Lower values compress more the contrasts.
+	ContrastFactor float64
+	// Saturation is included in [0, 2] with 0.01 increment step.
+	Saturation float64
+	// MinClipping is included in [0, 1] with 0.001 increment step (black percentile).
+	MinClipping float64
+	// MaxClipping is included in [0, 1] with 0.001 increment step (white percentile).
+	MaxClipping float64
+	executable
+}
+
+// NewDefaultMantiuk06 instanciates a new Mantiuk06 TMO with default parameters.
+func NewDefaultMantiuk06(m hdr.Image) *Mantiuk06 {
+	return NewMantiuk06(m, Mantiuk06ContrastMapping, 0.1, 0.8)
+}
+
+// NewMantiuk06 instanciates a new Mantiuk06 TMO.
+func NewMantiuk06(m hdr.Image, mode Mantiuk06Mode, contrastFactor, saturation float64) *Mantiuk06 {
+	return &Mantiuk06{
+		HDRImage:       m,
+		Mode:           mode,
+		ContrastFactor: contrastFactor,
+		Saturation:     saturation,
+		MinClipping:    0.001,
+		MaxClipping:    0.995,
+	}
+}
+
+// Perform runs the TMO mapping.
+func (t *Mantiuk06) Perform() image.Image {
+	return t.encode(NewGammaOutput(mantiukGamma), t.PerformHDR())
+}
+
+// PerformHDR runs the TMO mapping and returns display-referred linear values.
+func (t *Mantiuk06) PerformHDR() hdr.Image {
+	d := t.HDRImage.Bounds()
+
+	// Log-luminance
+	L := logLuminance(t.executor, t.HDRImage, func(lum float64) float64 {
+		return math.Log10(math.Max(lum, 1e-6))
+	})
+
+	// Multi-resolution contrast representation
+	pyramid := []*filter.Plane{L}
+	for p := L; p.Width/2 >= mantiukMinLevelSize && p.Height/2 >= mantiukMinLevelSize; {
+		p = halve(t.executor, p)
+		pyramid = append(pyramid, p)
+	}
+
+	gradients := make([][2]*filter.Plane, len(pyramid))
+	for k, p := range pyramid {
+		gx, gy := p.Gradients()
+		gradients[k] = [2]*filter.Plane{gx, gy}
+	}
+
+	// Contrast processing
+	switch t.Mode {
+	case Mantiuk06ContrastEqualization:
+		t.equalize(gradients)
+	default:
+		t.mapping(gradients)
+	}
+
+	// Reconstruction
+	I := t.reconstruct(gradients, L.Width, L.Height)
+
+	img := hdr.NewRGB64(d)
+	tonemapLuminance(t.executor, img, t.HDRImage, I, func(v float64) float64 {
+		return math.Pow(10, v)
+	}, t.Saturation, t.MinClipping, t.MaxClipping)
+
+	return img
+}
+
+// mapping scales the contrasts in the transducer response space.
+func (t *Mantiuk06) mapping(gradients [][2]*filter.Plane) {
+	for _, g := range gradients {
+		for _, p := range g {
+			for i, v := range p.Pix {
+				r := math.Copysign(mantiukTransducerA*math.Pow(math.Abs(v), mantiukTransducerB), v)
+				r *= t.ContrastFactor
+				p.Pix[i] = math.Copysign(math.Pow(math.Abs(r)/mantiukTransducerA, 1/mantiukTransducerB), r)
+			}
+		}
+	}
+}
+
+// equalize replaces the contrast magnitudes by their cumulative distribution over all the levels.
+func (t *Mantiuk06) equalize(gradients [][2]*filter.Plane) {
+	var magnitudes []float64
+	for _, g := range gradients {
+		for i := range g[0].Pix {
+			magnitudes = append(magnitudes, math.Hypot(g[0].Pix[i], g[1].Pix[i]))
+		}
+	}
+	sort.Float64s(magnitudes)
+
+	n := float64(len(magnitudes))
+	for _, g := range gradients {
+		for i := range g[0].Pix {
+			m := math.Hypot(g[0].Pix[i], g[1].Pix[i])
+			if m == 0 {
+				continue
+			}
+
+			cdf := float64(sort.SearchFloat64s(magnitudes, m)) / n
+			scale := t.ContrastFactor * cdf / m
+			g[0].Pix[i] *= scale
+			g[1].Pix[i] *= scale
+		}
+	}
+}
+
+// reconstruct finds the log-luminance whose multi-resolution gradients are the closest
+// (in least squares) to the given ones using the conjugate gradient method.
+func (t *Mantiuk06) reconstruct(gradients [][2]*filter.Plane, width, height int) []float64 {
+	var b *filter.Plane
+	for k := len(gradients) - 1; k >= 0; k-- {
+		g := gradients[k]
+		div := filter.Divergence(g[0].Pix, g[1].Pix, g[0].Width, g[0].Height)
+
+		if b != nil {
+			b = halveT(t.executor, b, g[0].Width, g[0].Height)
+		} else {
+			b = filter.NewPlane(g[0].Width, g[0].Height)
+		}
+		for i, v := range div {
+			b.Pix[i] -= v
+		}
+	}
+
+	x := make([]float64, width*height)
+	r := make([]float64, len(x))
+	copy(r, b.Pix)
+	p := make([]float64, len(x))
+	copy(p, r)
+
+	rs := t.dot(r, r, width, height)
+	threshold := mantiukTolerance * mantiukTolerance * rs
+	for i := 0; i < mantiukIterations && rs > threshold && t.executor.Err() == nil; i++ {
+		Ap := t.operator(p, width, height, len(gradients))
+
+		alpha := rs / t.dot(p, Ap, width, height)
+		completed := t.executor.Lines(height, func(y1, y2 int) {
+			for j := y1 * width; j < y2*width; j++ {
+				x[j] += alpha * p[j]
+				r[j] -= alpha * Ap[j]
+			}
+		})
+		<-completed
+
+		rsNew := t.dot(r, r, width, height)
+		beta := rsNew / rs
+		completed = t.executor.Lines(height, func(y1, y2 int) {
+			for j := y1 * width; j < y2*width; j++ {
+				p[j] = r[j] + beta*p[j]
+			}
+		})
+		<-completed
+		rs = rsNew
+	}
+
+	return x
+}
+
+// operator applies the normal equations matrix Σ Dkᵀ ∇ᵀ ∇ Dk on x where Dk is the downsampling to the level k.
+func (t *Mantiuk06) operator(x []float64, width, height, levels int) []float64 {
+	pyramid := []*filter.Plane{{Width: width, Height: height, Pix: x}}
+	for k := 1; k < levels; k++ {
+		pyramid = append(pyramid, halve(t.executor, pyramid[k-1]))
+	}
+
+	var result *filter.Plane
+	for k := levels - 1; k >= 0; k-- {
+		p := pyramid[k]
+
+		if result != nil {
+			result = halveT(t.executor, result, p.Width, p.Height)
+		} else {
+			result = filter.NewPlane(p.Width, p.Height)
+		}
+		subDivergence(t.executor, result, p)
+	}
+
+	return result.Pix
+}
+
+// dot returns the scalar product of the width×height vectors a and b.
+// It is a compensated reduction which does not depend on the number of workers.
+func (t *Mantiuk06) dot(a, b []float64, width, height int) float64 {
+	return parallel.Sum(t.executor, image.Rect(0, 0, width, height), func(x1, y1, x2, y2 int) float64 {
+		var sum xmath.KahanSum
+		for y := y1; y < y2; y++ {
+			for i := y*width + x1; i < y*width+x2; i++ {
+				sum.Add(a[i] * b[i])
+			}
+		}
+		return sum.Value()
+	})
+}
+
+// subDivergence subtracts the divergence of the forward difference gradients of p from dst.
+// It is filter.Divergence of p.Gradients() in one pass (Neumann boundary conditions).
+func subDivergence(e *parallel.Executor, dst, p *filter.Plane) {
+	width, height := p.Width, p.Height
+
+	completed := e.Lines(height, func(y1, y2 int) {
+		for y := y1; y < y2; y++ {
+			for x := 0; x < width; x++ {
+				i := y*width + x
+				v := p.Pix[i]
+
+				var div float64
+				if x < width-1 {
+					div += p.Pix[i+1] - v
+				}
+				if x > 0 {
+					div += p.Pix[i-1] - v
+				}
+				if y < height-1 {
+					div += p.Pix[i+width] - v
+				}
+				if y > 0 {
+					div += p.Pix[i-width] - v
+				}
+				dst.Pix[i] -= div
+			}
+		}
+	})
+	<-completed
+}
+
+// halve downsamples p by a factor of 2 with a 2x2 box filter.
+// The last row and column are dropped for odd sizes.
+func halve(e *parallel.Executor, p *filter.Plane) *filter.Plane {
+	dst := filter.NewPlane(p.Width/2, p.Height/2)
+
+	completed := e.Lines(dst.Height, func(y1, y2 int) {
+		for y := y1; y < y2; y++ {
+			for x := 0; x < dst.Width; x++ {
+				i := 2*y*p.Width + 2*x
+				dst.Set(x, y, 0.25*(p.Pix[i]+p.Pix[i+1]+p.Pix[i+p.Width]+p.Pix[i+p.Width+1]))
+			}
+		}
+	})
+	<-completed
+
+	return dst
+}
+
+// halveT is the adjoint of halve, it upsamples p to the given size.
+func halveT(e *parallel.Executor, p *filter.Plane, width, height int) *filter.Plane {
+	dst := filter.NewPlane(width, height)
+
+	// Each row of p is spread on its own 2 rows of dst
+	completed := e.Lines(p.Height, func(y1, y2 int) {
+		for y := y1; y < y2; y++ {
+			for x := 0; x < p.Width; x++ {
+				v := 0.25 * p.At(x, y)
+				i := 2*y*width + 2*x
+				dst.Pix[i] += v
+				dst.Pix[i+1] += v
+				dst.Pix[i+width] += v
+				dst.Pix[i+width+1] += v
+			}
+		}
+	})
+	<-completed
+
+	return dst
+}
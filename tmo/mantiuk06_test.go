@@ -0,0 +1,142 @@
+package tmo
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/mdouchement/hdr/filter"
+	"github.com/mdouchement/hdr/parallel"
+)
+
+// mantiukLogLuminance returns a smooth log-luminance with some noise.
+func mantiukLogLuminance(width, height int) *filter.Plane {
+	rnd := rand.New(rand.NewSource(30))
+
+	p := filter.NewPlane(width, height)
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			v := math.Sin(float64(x)/5) + math.Cos(float64(y)/3) + 0.02*float64(x+y)
+			p.Set(x, y, v+0.1*rnd.NormFloat64())
+		}
+	}
+	return p
+}
+
+// mantiukGradients returns the multi-resolution gradients of L as built by Mantiuk06.PerformHDR.
+func mantiukGradients(L *filter.Plane) [][2]*filter.Plane {
+	pyramid := []*filter.Plane{L}
+	for p := L; p.Width/2 >= mantiukMinLevelSize && p.Height/2 >= mantiukMinLevelSize; {
+		p = halve(nil, p)
+		pyramid = append(pyramid, p)
+	}
+
+	gradients := make([][2]*filter.Plane, len(pyramid))
+	for k, p := range pyramid {
+		gx, gy := p.Gradients()
+		gradients[k] = [2]*filter.Plane{gx, gy}
+	}
+	return gradients
+}
+
+// zeroMean removes the mean of v, the reconstruction is defined up to a constant.
+func zeroMean(v []float64) []float64 {
+	var mean float64
+	for _, x := range v {
+		mean += x
+	}
+	mean /= float64(len(v))
+
+	dst := make([]float64, len(v))
+	for i, x := range v {
+		dst[i] = x - mean
+	}
+	return dst
+}
+
+func TestMantiuk06Reconstruct(t *testing.T) {
+	width, height := 45, 33
+	L := mantiukLogLuminance(width, height)
+	gradients := mantiukGradients(L)
+	if len(gradients) < 2 {
+		t.Fatalf("got %d pyramid levels, want several", len(gradients))
+	}
+
+	// Unmodified gradients must give back the log-luminance (up to a constant)
+	tmo := NewDefaultMantiuk06(nil)
+	x := tmo.reconstruct(gradients, width, height)
+
+	// The normal equations right-hand side is A·L so the residual is A·(L - x)
+	e := make([]float64, len(x))
+	for i := range x {
+		e[i] = L.Pix[i] - x[i]
+	}
+	if relative := math.Sqrt(norm2(tmo.operator(e, width, height, len(gradients))) / norm2(tmo.operator(L.Pix, width, height, len(gradients)))); relative > mantiukTolerance {
+		t.Errorf("got a relative residual of %v, want at most %v", relative, mantiukTolerance)
+	}
+
+	actual, expected := zeroMean(x), zeroMean(L.Pix)
+	for i := range e {
+		e[i] = actual[i] - expected[i]
+	}
+	if relative := math.Sqrt(norm2(e) / norm2(expected)); relative > 1e-2 {
+		t.Errorf("got a relative error of %v, want at most %v", relative, 1e-2)
+	}
+}
+
+// norm2 returns the squared euclidean norm of v.
+func norm2(v []float64) float64 {
+	var sum float64
+	for _, x := range v {
+		sum += x * x
+	}
+	return sum
+}
+
+func TestMantiuk06ReconstructWorkers(t *testing.T) {
+	width, height := 45, 33
+	gradients := mantiukGradients(mantiukLogLuminance(width, height))
+
+	var expected []float64
+	for _, workers := range []int{1, 2, 5} {
+		tmo := NewDefaultMantiuk06(nil)
+		tmo.SetExecutor(&parallel.Executor{Workers: workers})
+		actual := tmo.reconstruct(gradients, width, height)
+
+		if expected == nil {
+			expected = actual
+			continue
+		}
+		for i := range expected {
+			if actual[i] != expected[i] {
+				t.Fatalf("%d workers: pixel %d: got %v, want %v", workers, i, actual[i], expected[i])
+			}
+		}
+	}
+}
+
+func TestMantiuk06HalveAdjoint(t *testing.T) {
+	rnd := rand.New(rand.NewSource(30))
+	a := filter.NewPlane(23, 16)
+	b := filter.NewPlane(11, 8)
+	for i := range a.Pix {
+		a.Pix[i] = rnd.Float64()
+	}
+	for i := range b.Pix {
+		b.Pix[i] = rnd.Float64()
+	}
+
+	// <halve(a), b> = <a, halveT(b)>
+	ha, hb := halve(nil, a), halveT(nil, b, a.Width, a.Height)
+	var left, right float64
+	for i := range b.Pix {
+		left += ha.Pix[i] * b.Pix[i]
+	}
+	for i := range a.Pix {
+		right += a.Pix[i] * hb.Pix[i]
+	}
+
+	if math.Abs(left-right) > 1e-12 {
+		t.Errorf("got <halve(a), b> = %v and <a, halveT(b)> = %v", left, right)
+	}
+}
@@ -0,0 +1,195 @@
+package tmo
+
+import (
+	"image"
+	"math"
+
+	"github.com/mdouchement/hdr"
+	"github.com/mdouchement/hdr/filter"
+	"github.com/mdouchement/hdr/hdrcolor"
+	"github.com/mdouchement/hdr/xmath"
+)
+
+const msrcrEpsilon = 1e-4 // Avoid log(0)
+
+// A MSRCR is a Multi-Scale Retinex with Color Restoration TMO implementation
+// based on Daniel Jobson's 1997 white paper.
+// Each channel is compressed by the (weighted) ratio between the pixel and its Gaussian surrounds,
+// then the colors are restored and stretched with the simplest color balance.
+//
+// Reference:
+// A Multiscale Retinex for Bridging the Gap Between Color Images and the Human Observation of Scenes.
+// D. J. Jobson, Z. Rahman and G. A. Woodell.
+// In IEEE Transactions on Image Processing, 1997.
+//
+// Multiscale Retinex.
+// A. B. Petro, C. Sbert and J.-M. Morel.
+// In Image Processing On Line, 2014.
+type MSRCR struct {
+	HDRImage hdr.Image
+	// Scales are the radii (pixels) of the Gaussian surrounds.
+	Scales []int
+	// Weights are the weights of each scale (normalized to a sum of 1).
+	Weights []float64
+	// ColorRestoration enables the color restoration (MSRCR), otherwise it is a plain MSR.
+	ColorRestoration bool
+	// Alpha controls the strength of the color restoration non-linearity.
+	Alpha float64
+	// Beta is the color restoration gain.
+	Beta float64
+	// LowClip is included in [0, 0.5] with 0.001 increment step (ratio of clipped dark pixels).
+	LowClip float64
+	// HighClip is included in [0, 0.5] with 0.001 increment step (ratio of clipped bright pixels).
+	HighClip float64
+	executable
+}
+
+// NewDefaultMSRCR instanciates a new MSRCR TMO with default parameters.
+func NewDefaultMSRCR(m hdr.Image) *MSRCR {
+	return NewMSRCR(m, []int{15, 80, 250}, nil, true)
+}
+
+// NewMSRCR instanciates a new MSRCR TMO.
+// A nil weights uses the same weight for all the scales.
+func NewMSRCR(m hdr.Image, scales []int, weights []float64, colorRestoration bool) *MSRCR {
+	if len(weights) != len(scales) {
+		weights = make([]float64, len(scales))
+		for i := range weights {
+			weights[i] = 1
+		}
+	}
+
+	return &MSRCR{
+		HDRImage:         m,
+		Scales:           scales,
+		Weights:          weights,
+		ColorRestoration: colorRestoration,
+		Alpha:            125,
+		Beta:             46,
+		LowClip:          0.01,
+		HighClip:         0.01,
+	}
+}
+
+// Perform runs the TMO mapping.
+func (t *MSRCR) Perform() image.Image {
+	return t.encode(NewOutput(EncodingLinear), t.PerformHDR())
+}
+
+// PerformHDR runs the TMO mapping and returns display-referred linear values.
+func (t *MSRCR) PerformHDR() hdr.Image {
+	d := t.HDRImage.Bounds()
+	img := hdr.NewRGB64(d)
+
+	src := hdr.NewRGB64(image.Rect(0, 0, d.Dx(), d.Dy()))
+	completed := t.executor.TilesR(d, func(x1, y1, x2, y2 int) {
+		for y := y1; y < y2; y++ {
+			for x := x1; x < x2; x++ {
+				r, g, b, _ := t.HDRImage.HDRAt(x, y).HDRRGBA()
+				src.SetRGB(x-d.Min.X, y-d.Min.Y, hdrcolor.RGB{R: math.Max(r, 0), G: math.Max(g, 0), B: math.Max(b, 0)})
+			}
+		}
+	})
+	<-completed
+
+	retinex := t.retinex(src)
+	t.colorBalance(img, retinex)
+
+	return img
+}
+
+// retinex returns the (color restored) multi-scale retinex of each channel.
+func (t *MSRCR) retinex(src *hdr.RGB64) [3][]float64 {
+	d := src.Bounds()
+	width, height := d.Dx(), d.Dy()
+
+	var sum float64
+	for _, w := range t.Weights {
+		sum += w
+	}
+
+	var retinex [3][]float64
+	for c := range retinex {
+		retinex[c] = make([]float64, width*height)
+	}
+
+	for s, radius := range t.Scales {
+		if t.executor.Err() != nil {
+			break // Cancelled
+		}
+
+		surround := filter.FastGaussianWithExecutor(t.executor, src, xmath.Clamp(1, width+height, radius))
+		w := t.Weights[s] / sum
+
+		completed := t.executor.TilesR(d, func(x1, y1, x2, y2 int) {
+			for y := y1; y < y2; y++ {
+				for x := x1; x < x2; x++ {
+					i := y*width + x
+					r, g, b, _ := src.HDRAt(x, y).HDRRGBA()
+					sr, sg, sb, _ := surround.HDRAt(x, y).HDRRGBA()
+
+					retinex[0][i] += w * (math.Log(r+msrcrEpsilon) - math.Log(sr+msrcrEpsilon))
+					retinex[1][i] += w * (math.Log(g+msrcrEpsilon) - math.Log(sg+msrcrEpsilon))
+					retinex[2][i] += w * (math.Log(b+msrcrEpsilon) - math.Log(sb+msrcrEpsilon))
+				}
+			}
+		})
+		<-completed
+	}
+
+	if !t.ColorRestoration {
+		return retinex
+	}
+
+	completed := t.executor.TilesR(d, func(x1, y1, x2, y2 int) {
+		for y := y1; y < y2; y++ {
+			for x := x1; x < x2; x++ {
+				i := y*width + x
+				r, g, b, _ := src.HDRAt(x, y).HDRRGBA()
+				total := math.Log(r + g + b + 3*msrcrEpsilon)
+
+				retinex[0][i] *= t.Beta * (math.Log(t.Alpha*(r+msrcrEpsilon)) - total)
+				retinex[1][i] *= t.Beta * (math.Log(t.Alpha*(g+msrcrEpsilon)) - total)
+				retinex[2][i] *= t.Beta * (math.Log(t.Alpha*(b+msrcrEpsilon)) - total)
+			}
+		}
+	})
+	<-completed
+
+	return retinex
+}
+
+// colorBalance stretches each channel between its clipped percentiles (simplest color balance).
+func (t *MSRCR) colorBalance(img *hdr.RGB64, retinex [3][]float64) {
+	d := t.HDRImage.Bounds()
+	width := d.Dx()
+
+	var mins, maxs [3]float64
+	for c, channel := range retinex {
+		perc := make(percentiles, len(channel))
+		copy(perc, channel)
+		perc.sort()
+
+		mins[c] = perc.percentile(xmath.ClampF64(0, 0.5, t.LowClip))
+		maxs[c] = perc.percentile(1 - xmath.ClampF64(0, 0.5, t.HighClip))
+		if maxs[c] <= mins[c] {
+			maxs[c] = mins[c] + 1
+		}
+	}
+
+	completed := t.executor.TilesR(d, func(x1, y1, x2, y2 int) {
+		for y := y1; y < y2; y++ {
+			for x := x1; x < x2; x++ {
+				i := (y-d.Min.Y)*width + x - d.Min.X
+
+				img.SetRGB(x, y, hdrcolor.RGB{
+					R: (retinex[0][i] - mins[0]) / (maxs[0] - mins[0]),
+					G: (retinex[1][i] - mins[1]) / (maxs[1] - mins[1]),
+					B: (retinex[2][i] - mins[2]) / (maxs[2] - mins[2]),
+				})
+			}
+		}
+	})
+
+	<-completed
+}
@@ -0,0 +1,468 @@
+package tmo
+
+import (
+	"math"
+
+	"github.com/mdouchement/hdr"
+)
+
+// Built-in TMOs registration.
+func init() {
+	Register(&Registration{
+		Name: "linear",
+		Doc:  "Linear mapping between the minimum and the maximum of each channel.",
+		New: func(m hdr.Image, _ map[string]float64) ToneMappingOperator {
+			return NewLinear(m)
+		},
+	})
+
+	Register(&Registration{
+		Name: "logarithmic",
+		Doc:  "Logarithmic mapping between the minimum and the maximum of each channel.",
+		New: func(m hdr.Image, _ map[string]float64) ToneMappingOperator {
+			return NewLogarithmic(m)
+		},
+	})
+
+	Register(&Registration{
+		Name: "drago03",
+		Doc:  "Adaptive logarithmic mapping (Drago 2003).",
+		Parameters: []Parameter{
+			{Name: "bias", Type: ParameterFloat, Min: 0, Max: 1, Step: 0.01, Default: 0.5,
+				Doc: "Contrast of the dark areas."},
+		},
+		New: func(m hdr.Image, params map[string]float64) ToneMappingOperator {
+			return NewDrago03(m, params["bias"])
+		},
+		Tune: func(t ToneMappingOperator, params map[string]float64) {
+			t.(*Drago03).Bias = params["bias"]
+		},
+	})
+
+	Register(&Registration{
+		Name: "durand",
+		Doc:  "Bilateral filtering base/detail decomposition (Durand 2002).",
+		Parameters: []Parameter{
+			{Name: "contrast", Type: ParameterFloat, Min: 2, Max: 100, Step: 0.5, Default: 5,
+				Doc: "Target contrast of the base layer."},
+		},
+		New: func(m hdr.Image, params map[string]float64) ToneMappingOperator {
+			return NewDurand(m, params["contrast"])
+		},
+		Tune: func(t ToneMappingOperator, params map[string]float64) {
+			t.(*Durand).Contrast = params["contrast"]
+		},
+	})
+
+	Register(&Registration{
+		Name: "icam06",
+		Doc:  "Image color appearance model (Kuang 2006).",
+		Parameters: []Parameter{
+			{Name: "contrast", Type: ParameterFloat, Min: 0.6, Max: 0.85, Step: 0.01, Default: 0.7,
+				Doc: "Exponent of the base layer compression."},
+			{Name: "minClipping", Type: ParameterFloat, Min: 0, Max: 1, Step: 0.001, Default: 0.01,
+				Doc: "Black percentile."},
+			{Name: "maxClipping", Type: ParameterFloat, Min: 0, Max: 1, Step: 0.001, Default: 0.99,
+				Doc: "White percentile."},
+		},
+		New: func(m hdr.Image, params map[string]float64) ToneMappingOperator {
+			return NewICam06(m, params["contrast"], params["minClipping"], params["maxClipping"])
+		},
+		Tune: func(t ToneMappingOperator, params map[string]float64) {
+			tt := t.(*ICam06)
+			tt.Contrast = params["contrast"]
+			tt.MinClipping, tt.MaxClipping = params["minClipping"], params["maxClipping"]
+		},
+	})
+
+	Register(&Registration{
+		Name: "reinhard05",
+		Doc:  "Photoreceptor physiology based mapping (Reinhard 2005).",
+		Parameters: []Parameter{
+			{Name: "brightness", Type: ParameterFloat, Min: -20, Max: 20, Step: 0.1, Default: -5,
+				Doc: "Overall intensity."},
+			{Name: "chromatic", Type: ParameterFloat, Min: 0, Max: 1, Step: 0.01, Default: 0.89,
+				Doc: "Chromatic adaptation (0 adapts on the luminance, 1 on each channel)."},
+			{Name: "light", Type: ParameterFloat, Min: 0, Max: 1, Step: 0.01, Default: 0.89,
+				Doc: "Light adaptation (0 adapts on the pixel, 1 on the average)."},
+		},
+		New: func(m hdr.Image, params map[string]float64) ToneMappingOperator {
+			return NewReinhard05(m, params["brightness"], params["chromatic"], params["light"])
+		},
+		Tune: func(t ToneMappingOperator, params map[string]float64) {
+			tt := t.(*Reinhard05)
+			tt.Brightness = params["brightness"]
+			tt.Chromatic = params["chromatic"]
+			tt.Light = params["light"]
+		},
+	})
+
+	Register(&Registration{
+		Name: "custom-reinhard05",
+		Doc:  "Reinhard 2005 variant with a wider brightness range.",
+		Parameters: []Parameter{
+			{Name: "brightness", Type: ParameterFloat, Min: -50, Max: 50, Step: 1, Default: 0,
+				Doc: "Overall intensity."},
+			{Name: "chromatic", Type: ParameterFloat, Min: 0, Max: 1, Step: 0.01, Default: 0,
+				Doc: "Chromatic adaptation (0 adapts on the luminance, 1 on each channel)."},
+			{Name: "light", Type: ParameterFloat, Min: 0, Max: 1, Step: 0.01, Default: 0.1,
+				Doc: "Light adaptation (0 adapts on the pixel, 1 on the average)."},
+		},
+		New: func(m hdr.Image, params map[string]float64) ToneMappingOperator {
+			return NewCustomReinhard05(m, params["brightness"], params["chromatic"], params["light"])
+		},
+	})
+
+	Register(&Registration{
+		Name: "reinhard02",
+		Doc:  "Photographic tone reproduction (Reinhard 2002).",
+		Parameters: []Parameter{
+			{Name: "key", Type: ParameterFloat, Min: 0, Max: 1, Step: 0.01, Default: 0.18,
+				Doc: "Key value of the scene, 0 estimates it from the log-average luminance."},
+			{Name: "white", Type: ParameterFloat, Min: 0, Max: 100, Step: 0.1, Default: 0,
+				Doc: "Smallest scaled luminance mapped to pure white, 0 uses the maximum luminance."},
+			{Name: "local", Type: ParameterBool, Min: 0, Max: 1, Step: 1, Default: 0,
+				Doc: "Dodging-and-burning local operator."},
+			{Name: "phi", Type: ParameterFloat, Min: 1, Max: 20, Step: 0.1, Default: 8,
+				Doc: "Sharpening of the local operator."},
+			{Name: "epsilon", Type: ParameterFloat, Min: 0.01, Max: 0.5, Step: 0.01, Default: 0.05,
+				Doc: "Scale selection threshold of the local operator."},
+		},
+		New: func(m hdr.Image, params map[string]float64) ToneMappingOperator {
+			t := NewReinhard02(m, params["key"], params["white"], params["local"] != 0)
+			t.Phi = params["phi"]
+			t.Epsilon = params["epsilon"]
+			return t
+		},
+		Tune: func(t ToneMappingOperator, params map[string]float64) {
+			tt := t.(*Reinhard02)
+			tt.Key, tt.White, tt.Local = params["key"], params["white"], params["local"] != 0
+			tt.Phi, tt.Epsilon = params["phi"], params["epsilon"]
+		},
+	})
+
+	Register(&Registration{
+		Name: "fattal02",
+		Doc:  "Gradient domain compression (Fattal 2002).",
+		Parameters: append([]Parameter{
+			{Name: "alpha", Type: ParameterFloat, Min: 0.01, Max: 1, Step: 0.01, Default: 0.1,
+				Doc: "Ratio of the average gradient magnitude where gradients are left unchanged."},
+			{Name: "beta", Type: ParameterFloat, Min: 0.7, Max: 0.95, Step: 0.01, Default: 0.85,
+				Doc: "Compression of the large gradients (lower compresses more)."},
+			{Name: "saturation", Type: ParameterFloat, Min: 0.4, Max: 1.2, Step: 0.01, Default: 0.8,
+				Doc: "Color saturation."},
+		}, clippingParameters()...),
+		New: func(m hdr.Image, params map[string]float64) ToneMappingOperator {
+			t := NewFattal02(m, params["alpha"], params["beta"], params["saturation"])
+			t.MinClipping, t.MaxClipping = params["minClipping"], params["maxClipping"]
+			return t
+		},
+	})
+
+	Register(&Registration{
+		Name: "mantiuk06",
+		Doc:  "Perceptual framework for contrast processing (Mantiuk 2006).",
+		Parameters: append([]Parameter{
+			{Name: "mode", Type: ParameterEnum, Min: 0, Max: 1, Step: 1, Default: 0,
+				Values: []string{"contrast-mapping", "contrast-equalization"},
+				Doc:    "Contrast processing."},
+			{Name: "contrastFactor", Type: ParameterFloat, Min: 0.01, Max: 1, Step: 0.01, Default: 0.1,
+				Doc: "Contrast compression (lower compresses more)."},
+			{Name: "saturation", Type: ParameterFloat, Min: 0, Max: 2, Step: 0.01, Default: 0.8,
+				Doc: "Color saturation."},
+		}, clippingParameters()...),
+		New: func(m hdr.Image, params map[string]float64) ToneMappingOperator {
+			t := NewMantiuk06(m, Mantiuk06Mode(params["mode"]), params["contrastFactor"], params["saturation"])
+			t.MinClipping, t.MaxClipping = params["minClipping"], params["maxClipping"]
+			return t
+		},
+	})
+
+	Register(&Registration{
+		Name: "local-laplacian",
+		Doc:  "Local Laplacian filters (Paris 2011).",
+		Parameters: append([]Parameter{
+			{Name: "detail", Type: ParameterFloat, Min: 0.01, Max: 2, Step: 0.01, Default: 0.5,
+				Doc: "Details enhancement (lower than 1) or smoothing (greater than 1)."},
+			{Name: "rangeCompression", Type: ParameterFloat, Min: 0, Max: 1, Step: 0.01, Default: 0.2,
+				Doc: "Edges compression (lower compresses more)."},
+			{Name: "sigma", Type: ParameterFloat, Min: 0.1, Max: 3, Step: 0.01, Default: math.Log(2.5),
+				Doc: "Log-luminance threshold between details and edges."},
+			{Name: "saturation", Type: ParameterFloat, Min: 0, Max: 2, Step: 0.01, Default: 0.8,
+				Doc: "Color saturation."},
+		}, clippingParameters()...),
+		New: func(m hdr.Image, params map[string]float64) ToneMappingOperator {
+			t := NewLocalLaplacian(m, params["detail"], params["rangeCompression"], params["sigma"])
+			t.Saturation = params["saturation"]
+			t.MinClipping, t.MaxClipping = params["minClipping"], params["maxClipping"]
+			return t
+		},
+	})
+
+	Register(&Registration{
+		Name: "ward97",
+		Doc:  "Histogram adjustment with human visibility (Ward Larson 1997).",
+		Parameters: append(displayParameters(),
+			Parameter{Name: "fieldOfView", Type: ParameterFloat, Min: 1, Max: 180, Step: 1, Default: 60,
+				Doc: "Horizontal angle covered by the image (degrees)."},
+			Parameter{Name: "humanContrast", Type: ParameterBool, Min: 0, Max: 1, Step: 1, Default: 0,
+				Doc: "Limits the contrast to the human visibility thresholds."},
+			Parameter{Name: "veilingGlare", Type: ParameterBool, Min: 0, Max: 1, Step: 1, Default: 0,
+				Doc: "Simulates the light scattered in the eye."},
+			Parameter{Name: "colorSensitivity", Type: ParameterBool, Min: 0, Max: 1, Step: 1, Default: 0,
+				Doc: "Simulates the color sensitivity loss in dark conditions."},
+			Parameter{Name: "acuity", Type: ParameterBool, Min: 0, Max: 1, Step: 1, Default: 0,
+				Doc: "Simulates the reduced visual acuity in dark conditions."},
+		),
+		New: func(m hdr.Image, params map[string]float64) ToneMappingOperator {
+			t := NewWard97(m, displayFrom(params), params["fieldOfView"])
+			t.HumanContrast = params["humanContrast"] != 0
+			t.VeilingGlare = params["veilingGlare"] != 0
+			t.ColorSensitivity = params["colorSensitivity"] != 0
+			t.Acuity = params["acuity"] != 0
+			return t
+		},
+	})
+
+	Register(&Registration{
+		Name:       "tumblin-rushmeier93",
+		Doc:        "Brightness preserving mapping (Tumblin and Rushmeier 1993).",
+		Parameters: displayParameters(),
+		New: func(m hdr.Image, params map[string]float64) ToneMappingOperator {
+			return NewTumblinRushmeier93(m, displayFrom(params))
+		},
+	})
+
+	Register(&Registration{
+		Name:       "ward94",
+		Doc:        "Contrast based scale factor (Ward 1994).",
+		Parameters: displayParameters(),
+		New: func(m hdr.Image, params map[string]float64) ToneMappingOperator {
+			return NewWard94(m, displayFrom(params))
+		},
+	})
+
+	Register(&Registration{
+		Name: "ferwerda96",
+		Doc:  "Visual adaptation model (Ferwerda 1996).",
+		Parameters: append(displayParameters(),
+			Parameter{Name: "adaptationLuminance", Type: ParameterFloat, Min: 0, Max: 100000, Step: 0.1, Default: 0,
+				Doc: "World adaptation luminance (cd/m²), 0 uses the half of the maximum luminance."},
+		),
+		New: func(m hdr.Image, params map[string]float64) ToneMappingOperator {
+			return NewFerwerda96(m, displayFrom(params), params["adaptationLuminance"])
+		},
+	})
+
+	Register(&Registration{
+		Name: "schlick94",
+		Doc:  "Rational mapping (Schlick 1994).",
+		Parameters: append(displayParameters(),
+			Parameter{Name: "p", Type: ParameterFloat, Min: 0, Max: 1000, Step: 1, Default: 0,
+				Doc: "Rational mapping parameter, 0 estimates it from the darkest luminance."},
+		),
+		New: func(m hdr.Image, params map[string]float64) ToneMappingOperator {
+			return NewSchlick94(m, displayFrom(params), params["p"])
+		},
+	})
+
+	Register(&Registration{
+		Name: "ashikhmin02",
+		Doc:  "Local adaptation with perceptual capacity (Ashikhmin 2002).",
+		Parameters: append(displayParameters(),
+			Parameter{Name: "threshold", Type: ParameterFloat, Min: 0.1, Max: 1, Step: 0.01, Default: 0.5,
+				Doc: "Local contrast limit of the adaptation scale selection."},
+			Parameter{Name: "detail", Type: ParameterBool, Min: 0, Max: 1, Step: 1, Default: 1,
+				Doc: "Reintroduces the details lost by the local adaptation compression."},
+		),
+		New: func(m hdr.Image, params map[string]float64) ToneMappingOperator {
+			return NewAshikhmin02(m, displayFrom(params), params["threshold"], params["detail"] != 0)
+		},
+	})
+
+	Register(&Registration{
+		Name: "msrcr",
+		Doc:  "Multi-scale Retinex with color restoration (Jobson 1997).",
+		Parameters: []Parameter{
+			{Name: "smallScale", Type: ParameterInt, Min: 1, Max: 1000, Step: 1, Default: 15,
+				Doc: "Radius of the small Gaussian surround (pixels)."},
+			{Name: "mediumScale", Type: ParameterInt, Min: 1, Max: 1000, Step: 1, Default: 80,
+				Doc: "Radius of the medium Gaussian surround (pixels)."},
+			{Name: "largeScale", Type: ParameterInt, Min: 1, Max: 1000, Step: 1, Default: 250,
+				Doc: "Radius of the large Gaussian surround (pixels)."},
+			{Name: "colorRestoration", Type: ParameterBool, Min: 0, Max: 1, Step: 1, Default: 1,
+				Doc: "Color restoration (MSRCR), otherwise it is a plain MSR."},
+			{Name: "alpha", Type: ParameterFloat, Min: 1, Max: 500, Step: 1, Default: 125,
+				Doc: "Strength of the color restoration non-linearity."},
+			{Name: "beta", Type: ParameterFloat, Min: 1, Max: 100, Step: 1, Default: 46,
+				Doc: "Color restoration gain."},
+			{Name: "lowClip", Type: ParameterFloat, Min: 0, Max: 0.5, Step: 0.001, Default: 0.01,
+				Doc: "Ratio of clipped dark pixels."},
+			{Name: "highClip", Type: ParameterFloat, Min: 0, Max: 0.5, Step: 0.001, Default: 0.01,
+				Doc: "Ratio of clipped bright pixels."},
+		},
+		New: func(m hdr.Image, params map[string]float64) ToneMappingOperator {
+			scales := []int{int(params["smallScale"]), int(params["mediumScale"]), int(params["largeScale"])}
+			t := NewMSRCR(m, scales, nil, params["colorRestoration"] != 0)
+			t.Alpha, t.Beta = params["alpha"], params["beta"]
+			t.LowClip, t.HighClip = params["lowClip"], params["highClip"]
+			return t
+		},
+	})
+
+	Register(&Registration{
+		Name: "aces",
+		Doc:  "Academy Color Encoding System reference rendering and output transforms.",
+		Parameters: []Parameter{
+			{Name: "exposure", Type: ParameterFloat, Min: -10, Max: 10, Step: 0.1, Default: 0,
+				Doc: "Exposure (stops)."},
+			{Name: "output", Type: ParameterEnum, Min: 0, Max: 3, Step: 1, Default: 0,
+				Values: []string{"srgb-100nits", "rec709-100nits", "p3d65-48nits", "rec2020-1000nits-pq"},
+				Doc:    "Output Device Transform."},
+		},
+		New: func(m hdr.Image, params map[string]float64) ToneMappingOperator {
+			return NewACES(m, params["exposure"], ACESOutput(params["output"]))
+		},
+	})
+
+	Register(&Registration{
+		Name: "filmic",
+		Doc:  "Filmic curves used by game engines.",
+		Parameters: []Parameter{
+			{Name: "curve", Type: ParameterEnum, Min: 0, Max: 4, Step: 1, Default: 0,
+				Values: []string{"hable", "lottes", "uchimura", "agx", "agx-punchy"},
+				Doc:    "Filmic curve."},
+			{Name: "mode", Type: ParameterEnum, Min: 0, Max: 1, Step: 1, Default: 0,
+				Values: []string{"per-channel", "luminance"},
+				Doc:    "Curve application (luminance preserves the hue)."},
+			{Name: "exposure", Type: ParameterFloat, Min: -10, Max: 10, Step: 0.1, Default: 0,
+				Doc: "Exposure (stops)."},
+			{Name: "whitePoint", Type: ParameterFloat, Min: 0, Max: 100, Step: 0.1, Default: 0,
+				Doc: "Scene-referred value mapped to display white, 0 uses the curve default."},
+			{Name: "hableA", Type: ParameterFloat, Min: 0, Max: 1, Step: 0.01, Default: 0.15,
+				Doc: "Shoulder strength (Hable curve only)."},
+			{Name: "hableB", Type: ParameterFloat, Min: 0, Max: 1, Step: 0.01, Default: 0.5,
+				Doc: "Linear strength (Hable curve only)."},
+			{Name: "hableC", Type: ParameterFloat, Min: 0, Max: 1, Step: 0.01, Default: 0.1,
+				Doc: "Linear angle (Hable curve only)."},
+			{Name: "hableD", Type: ParameterFloat, Min: 0, Max: 1, Step: 0.01, Default: 0.2,
+				Doc: "Toe strength (Hable curve only)."},
+			{Name: "hableE", Type: ParameterFloat, Min: 0, Max: 1, Step: 0.01, Default: 0.02,
+				Doc: "Toe numerator (Hable curve only)."},
+			{Name: "hableF", Type: ParameterFloat, Min: 0.01, Max: 1, Step: 0.01, Default: 0.3,
+				Doc: "Toe denominator (Hable curve only)."},
+			{Name: "lottesContrast", Type: ParameterFloat, Min: 1, Max: 2, Step: 0.01, Default: 1.6,
+				Doc: "Contrast (Lottes curve only)."},
+			{Name: "lottesShoulder", Type: ParameterFloat, Min: 0.9, Max: 1, Step: 0.001, Default: 0.977,
+				Doc: "Shoulder (Lottes curve only)."},
+			{Name: "lottesHDRMax", Type: ParameterFloat, Min: 1, Max: 64, Step: 0.1, Default: 8,
+				Doc: "Scene-referred value mapped to 1 (Lottes curve only)."},
+			{Name: "lottesMidIn", Type: ParameterFloat, Min: 0.01, Max: 0.9, Step: 0.01, Default: 0.18,
+				Doc: "Scene-referred middle grey (Lottes curve only)."},
+			{Name: "lottesMidOut", Type: ParameterFloat, Min: 0.01, Max: 0.9, Step: 0.001, Default: 0.267,
+				Doc: "Display-referred middle grey (Lottes curve only)."},
+			{Name: "uchimuraP", Type: ParameterFloat, Min: 0.5, Max: 10, Step: 0.01, Default: 1,
+				Doc: "Display maximum brightness (Uchimura curve only)."},
+			{Name: "uchimuraA", Type: ParameterFloat, Min: 0.1, Max: 5, Step: 0.01, Default: 1,
+				Doc: "Contrast of the linear section (Uchimura curve only)."},
+			{Name: "uchimuraM", Type: ParameterFloat, Min: 0.01, Max: 0.45, Step: 0.01, Default: 0.22,
+				Doc: "Beginning of the linear section (Uchimura curve only)."},
+			{Name: "uchimuraL", Type: ParameterFloat, Min: 0, Max: 0.99, Step: 0.01, Default: 0.4,
+				Doc: "Length of the linear section (Uchimura curve only)."},
+			{Name: "uchimuraC", Type: ParameterFloat, Min: 1, Max: 3, Step: 0.01, Default: 1.33,
+				Doc: "Black tightness (Uchimura curve only)."},
+			{Name: "uchimuraB", Type: ParameterFloat, Min: 0, Max: 1, Step: 0.01, Default: 0,
+				Doc: "Pedestal (Uchimura curve only)."},
+			{Name: "agxSlope", Type: ParameterFloat, Min: 0, Max: 4, Step: 0.01, Default: 1,
+				Doc: "Slope multiplied with the AgX look slope (AgX curves only)."},
+			{Name: "agxOffset", Type: ParameterFloat, Min: -1, Max: 1, Step: 0.01, Default: 0,
+				Doc: "Offset added to the AgX look offset (AgX curves only)."},
+			{Name: "agxPower", Type: ParameterFloat, Min: 0.1, Max: 4, Step: 0.01, Default: 1,
+				Doc: "Power multiplied with the AgX look power (AgX curves only)."},
+			{Name: "agxSaturation", Type: ParameterFloat, Min: 0, Max: 4, Step: 0.01, Default: 1,
+				Doc: "Saturation multiplied with the AgX look saturation (AgX curves only)."},
+		},
+		New: func(m hdr.Image, params map[string]float64) ToneMappingOperator {
+			var curve FilmicCurve
+			switch params["curve"] {
+			case 1:
+				curve = NewLottesCurve(params["lottesContrast"], params["lottesShoulder"], params["lottesHDRMax"],
+					params["lottesMidIn"], params["lottesMidOut"])
+			case 2:
+				curve = uchimuraCurveFrom(params)
+			case 3:
+				curve = NewAgXCurve(agxLookFrom(AgXLookBase, params))
+			case 4:
+				curve = NewAgXCurve(agxLookFrom(AgXLookPunchy, params))
+			default:
+				curve = hableCurveFrom(params)
+			}
+
+			white := params["whitePoint"]
+			if white == 0 {
+				white = curve.DefaultWhitePoint()
+			}
+			return NewFilmic(m, curve, FilmicMode(params["mode"]), params["exposure"], white)
+		},
+	})
+}
+
+// hableCurveFrom returns the Hable curve of the hable parameters.
+func hableCurveFrom(params map[string]float64) *HableCurve {
+	return &HableCurve{
+		ShoulderStrength: params["hableA"],
+		LinearStrength:   params["hableB"],
+		LinearAngle:      params["hableC"],
+		ToeStrength:      params["hableD"],
+		ToeNumerator:     params["hableE"],
+		ToeDenominator:   params["hableF"],
+	}
+}
+
+// uchimuraCurveFrom returns the Uchimura curve of the uchimura parameters.
+func uchimuraCurveFrom(params map[string]float64) *UchimuraCurve {
+	return &UchimuraCurve{
+		MaxBrightness: params["uchimuraP"],
+		Contrast:      params["uchimuraA"],
+		LinearStart:   params["uchimuraM"],
+		LinearLength:  params["uchimuraL"],
+		Black:         params["uchimuraC"],
+		Pedestal:      params["uchimuraB"],
+	}
+}
+
+// agxLookFrom returns the given look adjusted by the agx parameters.
+func agxLookFrom(look AgXLook, params map[string]float64) AgXLook {
+	for i := range look.Slope {
+		look.Slope[i] *= params["agxSlope"]
+		look.Power[i] *= params["agxPower"]
+	}
+	look.Offset += params["agxOffset"]
+	look.Saturation *= params["agxSaturation"]
+
+	return look
+}
+
+// displayParameters returns the parameters of the Display.
+func displayParameters() []Parameter {
+	return []Parameter{
+		{Name: "ldmax", Type: ParameterFloat, Min: 1, Max: 10000, Step: 1, Default: 100,
+			Doc: "Maximum display luminance (cd/m²)."},
+		{Name: "cmax", Type: ParameterFloat, Min: 1, Max: 100000, Step: 1, Default: 100,
+			Doc: "Maximum display contrast."},
+	}
+}
+
+func displayFrom(params map[string]float64) Display {
+	return NewDisplay(params["ldmax"], params["cmax"])
+}
+
+// clippingParameters returns the black and white percentiles parameters.
+func clippingParameters() []Parameter {
+	return []Parameter{
+		{Name: "minClipping", Type: ParameterFloat, Min: 0, Max: 1, Step: 0.001, Default: 0.001,
+			Doc: "Black percentile."},
+		{Name: "maxClipping", Type: ParameterFloat, Min: 0, Max: 1, Step: 0.001, Default: 0.995,
+			Doc: "White percentile."},
+	}
+}
@@ -0,0 +1,164 @@
+package tmo
+
+import (
+	"image"
+	"image/color"
+	"math"
+
+	"github.com/mdouchement/hdr"
+	"github.com/mdouchement/hdr/parallel"
+	"github.com/mdouchement/hdr/xmath"
+)
+
+// An HDRToneMappingOperator is a ToneMappingOperator which exposes its tone curve
+// separately from the display encoding.
+// It allows to chain a TMO with color grading, PQ encoding or dithering.
+type HDRToneMappingOperator interface {
+	ToneMappingOperator
+	// PerformHDR runs the TMO mapping and returns display-referred linear values
+	// where 1 is the display white (values are not clipped).
+	PerformHDR() hdr.Image
+}
+
+// Encoding defines the transfer function applied by an Output.
+type Encoding int
+
+const (
+	// EncodingLinear keeps the values linear.
+	EncodingLinear Encoding = iota
+	// EncodingGamma applies a pure power function (Output.Gamma).
+	EncodingGamma
+	// EncodingSRGB applies the sRGB OETF (IEC 61966-2-1).
+	EncodingSRGB
+	// EncodingPQ applies the SMPTE ST 2084 inverse EOTF with Output.PeakLuminance as display white.
+	EncodingPQ
+)
+
+// An Output is the display encoding stage shared by the TMOs.
+// It converts display-referred linear values to LDR values: saturation, transfer function,
+// clipping and bit depth.
+type Output struct {
+	Encoding Encoding
+	// Gamma is the exponent of EncodingGamma.
+	Gamma float64
+	// PeakLuminance is the display white luminance (cd/m²) of EncodingPQ.
+	PeakLuminance float64
+	// Saturation is included in [0, 2] with 0.01 increment step (1 keeps the colors unchanged).
+	Saturation float64
+	// BitDepth is included in [1, 16], the encoded values are quantized on 2^BitDepth levels.
+	BitDepth int
+	// InversePixelMapping stretches the values with LinearInversePixelMapping
+	// to have slightly more solid black and solid white.
+	InversePixelMapping bool
+	// Truncate quantizes the 16-bit values by truncation instead of rounding to the nearest value
+	// (the historical behavior of the Linear and Logarithmic TMOs).
+	Truncate bool
+	// Executor runs the encoding (optional).
+	Executor *parallel.Executor
+}
+
+// NewOutput returns a new 16-bit Output with the given encoding.
+func NewOutput(encoding Encoding) Output {
+	return Output{
+		Encoding:            encoding,
+		Gamma:               2.2,
+		PeakLuminance:       100,
+		Saturation:          1,
+		BitDepth:            16,
+		InversePixelMapping: true,
+	}
+}
+
+// NewGammaOutput returns a new 16-bit Output with a pure power function.
+func NewGammaOutput(gamma float64) Output {
+	o := NewOutput(EncodingGamma)
+	o.Gamma = gamma
+	return o
+}
+
+// Encode converts the display-referred linear image to an LDR image.
+func (o Output) Encode(m hdr.Image) *image.RGBA64 {
+	img := image.NewRGBA64(m.Bounds())
+
+	completed := o.Executor.TilesR(m.Bounds(), func(x1, y1, x2, y2 int) {
+		row := make([]float64, 3*(x2-x1))
+		for y := y1; y < y2; y++ {
+			hdr.ReadRow(m, row, x1, x2, y, hdr.RGBSpace)
+			for x, i := x1, 0; x < x2; x, i = x+1, i+3 {
+				r, g, b := o.saturate(row[i+0], row[i+1], row[i+2])
+
+				img.SetRGBA64(x, y, color.RGBA64{
+					R: o.EncodeChannel(r),
+					G: o.EncodeChannel(g),
+					B: o.EncodeChannel(b),
+					A: RangeMax,
+				})
+			}
+		}
+	})
+
+	<-completed
+
+	return img
+}
+
+// EncodeChannel converts a display-referred linear value to a 16-bit LDR value.
+func (o Output) EncodeChannel(channel float64) uint16 {
+	channel = xmath.ClampF64(0, 1, o.Transfer(channel))
+
+	if o.BitDepth > 0 && o.BitDepth < 16 {
+		levels := math.Exp2(float64(o.BitDepth)) - 1
+		channel = math.Round(channel*levels) / levels
+	}
+
+	if o.InversePixelMapping {
+		channel = LinearInversePixelMapping(channel, LumPixFloor, LumSize)
+		return uint16(LDRClamp(channel))
+	}
+	if o.Truncate {
+		return uint16(LDRClamp(channel * RangeMax))
+	}
+	return uint16(LDRClamp(math.Round(channel * RangeMax)))
+}
+
+// Transfer applies the transfer function on a display-referred linear value.
+func (o Output) Transfer(channel float64) float64 {
+	if math.IsNaN(channel) {
+		return 0
+	}
+
+	switch o.Encoding {
+	case EncodingGamma:
+		return math.Pow(math.Max(channel, 0), 1/o.Gamma)
+	case EncodingSRGB:
+		return srgbOETF(math.Max(channel, 0))
+	case EncodingPQ:
+		return st2084InverseEOTF(channel * o.PeakLuminance)
+	default:
+		return channel
+	}
+}
+
+// InverseTransfer converts an encoded value back to a display-referred linear value.
+func (o Output) InverseTransfer(channel float64) float64 {
+	switch o.Encoding {
+	case EncodingGamma:
+		return math.Pow(math.Max(channel, 0), o.Gamma)
+	case EncodingSRGB:
+		return srgbEOTF(math.Max(channel, 0))
+	case EncodingPQ:
+		return st2084EOTF(channel) / o.PeakLuminance
+	default:
+		return channel
+	}
+}
+
+func (o Output) saturate(r, g, b float64) (float64, float64, float64) {
+	if o.Saturation == 1 {
+		return r, g, b
+	}
+
+	lum := 0.2126*r + 0.7152*g + 0.0722*b
+	s := xmath.ClampF64(0, 2, o.Saturation)
+	return lum + s*(r-lum), lum + s*(g-lum), lum + s*(b-lum)
+}
@@ -0,0 +1,293 @@
+package tmo
+
+import (
+	"image"
+	"math"
+	"sort"
+
+	"github.com/mdouchement/hdr"
+	"github.com/mdouchement/hdr/xmath"
+)
+
+// A Dithering is the method used to hide the banding of the 8-bit quantization.
+type Dithering int
+
+const (
+	// DitheringNone rounds the values to the nearest level.
+	DitheringNone Dithering = iota
+	// DitheringBayer is an ordered dithering with an 8x8 Bayer matrix.
+	DitheringBayer
+	// DitheringBlueNoise is an ordered dithering with a 64x64 blue noise mask.
+	DitheringBlueNoise
+	// DitheringFloydSteinberg is the Floyd-Steinberg error diffusion.
+	DitheringFloydSteinberg
+	// DitheringSierra is the (three rows) Sierra error diffusion.
+	DitheringSierra
+)
+
+const (
+	quantizerLevels    = 256
+	diffusionStripSize = 64 // Rows converted at once by the error diffusion
+)
+
+// A Quantizer converts display-referred linear images (see HDRToneMappingOperator) to 8-bit images.
+// The values are encoded with the Output transfer function and saturation,
+// the Output BitDepth and InversePixelMapping are not used. The Output Executor runs the quantization.
+//
+// The error diffusion is sequential: its cost is mostly the conversion of the pixels which runs in parallel.
+type Quantizer struct {
+	Output    Output
+	Dithering Dithering
+	// Linear computes the quantization in linear light instead of the encoded space.
+	// It preserves the average luminance of the dithered areas.
+	Linear bool
+}
+
+// NewDefaultQuantizer instanciates a new sRGB Quantizer with a blue noise dithering.
+func NewDefaultQuantizer() *Quantizer {
+	return NewQuantizer(NewOutput(EncodingSRGB), DitheringBlueNoise, false)
+}
+
+// NewQuantizer instanciates a new Quantizer.
+func NewQuantizer(output Output, dithering Dithering, linear bool) *Quantizer {
+	return &Quantizer{
+		Output:    output,
+		Dithering: dithering,
+		Linear:    linear,
+	}
+}
+
+// RGBA quantizes the given display-referred linear image.
+func (q *Quantizer) RGBA(m hdr.Image) *image.RGBA {
+	img := image.NewRGBA(m.Bounds())
+	q.quantize(m, img.Pix, img.PixOffset)
+
+	return img
+}
+
+// NRGBA quantizes the given display-referred linear image.
+func (q *Quantizer) NRGBA(m hdr.Image) *image.NRGBA {
+	img := image.NewNRGBA(m.Bounds())
+	q.quantize(m, img.Pix, img.PixOffset)
+
+	return img
+}
+
+func (q *Quantizer) quantize(m hdr.Image, pix []uint8, offset func(x, y int) int) {
+	levels := q.levels()
+
+	switch q.Dithering {
+	case DitheringFloydSteinberg:
+		q.diffuse(m, pix, offset, levels, floydSteinberg)
+	case DitheringSierra:
+		q.diffuse(m, pix, offset, levels, sierra)
+	default:
+		q.ordered(m, pix, offset, levels)
+	}
+}
+
+// ordered quantizes the values with a position dependent threshold.
+func (q *Quantizer) ordered(m hdr.Image, pix []uint8, offset func(x, y int) int, levels quantizationLevels) {
+	threshold := func(x, y int) float64 {
+		return 0.5
+	}
+
+	switch q.Dithering {
+	case DitheringBayer:
+		threshold = func(x, y int) float64 {
+			return bayer8[y&7][x&7]
+		}
+	case DitheringBlueNoise:
+		mask := blueNoise()
+		threshold = func(x, y int) float64 {
+			return mask[(y&(blueNoiseSize-1))*blueNoiseSize+x&(blueNoiseSize-1)]
+		}
+	}
+
+	completed := q.Output.Executor.TilesR(m.Bounds(), func(x1, y1, x2, y2 int) {
+		for y := y1; y < y2; y++ {
+			for x := x1; x < x2; x++ {
+				channels := q.channels(m, x, y)
+				t := threshold(x, y)
+
+				i := offset(x, y)
+				for c, v := range channels {
+					pix[i+c] = levels.quantize(v, t)
+				}
+				pix[i+3] = 0xFF
+			}
+		}
+	})
+
+	<-completed
+}
+
+// diffuse quantizes the values and spreads the quantization error to the unprocessed neighbors.
+// The rows are processed in serpentine order over the whole image, only the conversion
+// to the quantization space runs in parallel (by strips of diffusionStripSize rows).
+func (q *Quantizer) diffuse(m hdr.Image, pix []uint8, offset func(x, y int) int, levels quantizationLevels, kernel []diffusionWeight) {
+	var rows int
+	for _, w := range kernel {
+		if w.dy >= rows {
+			rows = w.dy + 1
+		}
+	}
+
+	d := m.Bounds()
+	width := d.Dx()
+	errs := make([][]float64, rows) // Ring of the pending errors
+	for i := range errs {
+		errs[i] = make([]float64, 3*width)
+	}
+	values := make([]float64, 3*width*diffusionStripSize)
+
+	for sy := d.Min.Y; sy < d.Max.Y; sy += diffusionStripSize {
+		ey := sy + diffusionStripSize
+		if ey > d.Max.Y {
+			ey = d.Max.Y
+		}
+
+		completed := q.Output.Executor.Lines(ey-sy, func(i1, i2 int) {
+			for i := i1; i < i2; i++ {
+				row := values[3*width*i : 3*width*(i+1)]
+				for x := d.Min.X; x < d.Max.X; x++ {
+					channels := q.channels(m, x, sy+i)
+					copy(row[3*(x-d.Min.X):], channels[:])
+				}
+			}
+		})
+		<-completed
+
+		if q.Output.Executor.Err() != nil {
+			return
+		}
+
+		for y := sy; y < ey; y++ {
+			row := values[3*width*(y-sy) : 3*width*(y-sy+1)]
+			current := errs[(y-d.Min.Y)%rows]
+			direction := 1
+			if (y-d.Min.Y)%2 == 1 {
+				direction = -1
+			}
+
+			for n := 0; n < width; n++ {
+				x := d.Min.X + n
+				if direction < 0 {
+					x = d.Max.X - 1 - n
+				}
+
+				i := offset(x, y)
+				for c := 0; c < 3; c++ {
+					v := row[3*(x-d.Min.X)+c] + current[3*(x-d.Min.X)+c]
+
+					level := levels.quantize(v, 0.5)
+					pix[i+c] = level
+					e := v - levels[level]
+
+					for _, w := range kernel {
+						xx := x + direction*w.dx
+						if xx < d.Min.X || xx >= d.Max.X || y+w.dy >= d.Max.Y {
+							continue
+						}
+						errs[(y-d.Min.Y+w.dy)%rows][3*(xx-d.Min.X)+c] += e * w.weight
+					}
+				}
+				pix[i+3] = 0xFF
+			}
+
+			for i := range current {
+				current[i] = 0
+			}
+		}
+	}
+}
+
+// channels returns the values of the pixel in the quantization space.
+// They are clipped to the range of the levels so the error diffusion never spreads the clipping error.
+func (q *Quantizer) channels(m hdr.Image, x, y int) [3]float64 {
+	r, g, b, _ := m.HDRAt(x, y).HDRRGBA()
+	r, g, b = q.Output.saturate(r, g, b)
+
+	channels := [3]float64{r, g, b}
+	for c, v := range channels {
+		if math.IsNaN(v) {
+			v = 0
+		}
+
+		if q.Linear {
+			channels[c] = xmath.ClampF64(0, q.Output.InverseTransfer(1), v)
+			continue
+		}
+		channels[c] = xmath.ClampF64(0, 1, q.Output.Transfer(v))
+	}
+
+	return channels
+}
+
+// levels returns the value of each 8-bit level in the quantization space.
+func (q *Quantizer) levels() quantizationLevels {
+	var levels quantizationLevels
+	for i := range levels {
+		levels[i] = float64(i) / (quantizerLevels - 1)
+		if q.Linear {
+			levels[i] = q.Output.InverseTransfer(levels[i])
+		}
+	}
+
+	return levels
+}
+
+// A quantizationLevels holds the increasing values of each level.
+type quantizationLevels [quantizerLevels]float64
+
+// quantize returns the level of v, the upper level is picked when the position of v
+// between its surrounding levels is greater than threshold.
+func (l *quantizationLevels) quantize(v, threshold float64) uint8 {
+	i := sort.SearchFloat64s(l[:], v) // Smallest level greater than or equal to v
+	if i == 0 {
+		return 0
+	}
+	if i == len(l) {
+		return quantizerLevels - 1
+	}
+
+	if (v-l[i-1])/(l[i]-l[i-1]) > threshold {
+		return uint8(i)
+	}
+	return uint8(i - 1)
+}
+
+// A diffusionWeight is the part of the quantization error spread to the neighbor (dx, dy).
+type diffusionWeight struct {
+	dx, dy int
+	weight float64
+}
+
+var (
+	floydSteinberg = []diffusionWeight{
+		{1, 0, 7.0 / 16},
+		{-1, 1, 3.0 / 16}, {0, 1, 5.0 / 16}, {1, 1, 1.0 / 16},
+	}
+
+	sierra = []diffusionWeight{
+		{1, 0, 5.0 / 32}, {2, 0, 3.0 / 32},
+		{-2, 1, 2.0 / 32}, {-1, 1, 4.0 / 32}, {0, 1, 5.0 / 32}, {1, 1, 4.0 / 32}, {2, 1, 2.0 / 32},
+		{-1, 2, 2.0 / 32}, {0, 2, 3.0 / 32}, {1, 2, 2.0 / 32},
+	}
+)
+
+// bayer8 is the 8x8 Bayer matrix normalized to ]0, 1[.
+var bayer8 = func() (m [8][8]float64) {
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			// Reversed bit interleaving of x^y and y
+			var v int
+			for b := uint(0); b < 3; b++ {
+				v = v<<1 | ((x^y)>>b)&1
+				v = v<<1 | (y>>b)&1
+			}
+			m[y][x] = (float64(v) + 0.5) / 64
+		}
+	}
+	return
+}()
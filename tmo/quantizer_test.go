@@ -0,0 +1,133 @@
+package tmo
+
+import (
+	"image"
+	"math"
+	"sort"
+	"testing"
+
+	"github.com/mdouchement/hdr"
+	"github.com/mdouchement/hdr/hdrcolor"
+	"github.com/mdouchement/hdr/parallel"
+)
+
+var ditherings = map[string]Dithering{
+	"none":           DitheringNone,
+	"bayer":          DitheringBayer,
+	"blueNoise":      DitheringBlueNoise,
+	"floydSteinberg": DitheringFloydSteinberg,
+	"sierra":         DitheringSierra,
+}
+
+func TestOrderedThresholds(t *testing.T) {
+	var bayer []float64
+	for _, row := range bayer8 {
+		bayer = append(bayer, row[:]...)
+	}
+
+	// Each threshold rank is used once so every level is equally likely
+	for name, mask := range map[string][]float64{"bayer": bayer, "blueNoise": blueNoise()} {
+		thresholds := append([]float64(nil), mask...)
+		sort.Float64s(thresholds)
+
+		for i, v := range thresholds {
+			if expected := (float64(i) + 0.5) / float64(len(thresholds)); v != expected {
+				t.Errorf("%s: threshold %d: got %v, want %v", name, i, v, expected)
+				break
+			}
+		}
+	}
+}
+
+func TestQuantizerRange(t *testing.T) {
+	inputs := []float64{math.NaN(), math.Inf(-1), -1, 0, 1, 2, math.Inf(1)}
+	expected := []uint8{0, 0, 0, 0, 255, 255, 255}
+
+	// Followed by all the exact levels which must not be dithered
+	for i := 0; i < quantizerLevels; i++ {
+		inputs = append(inputs, float64(i)/(quantizerLevels-1))
+		expected = append(expected, uint8(i))
+	}
+
+	m := hdr.NewRGB64(image.Rect(0, 0, 16, len(inputs)))
+	for y, v := range inputs {
+		for x := 0; x < 16; x++ {
+			m.Set(x, y, hdrcolor.RGB{R: v, G: v, B: v})
+		}
+	}
+
+	for name, dithering := range ditherings {
+		for _, linear := range []bool{false, true} {
+			img := NewQuantizer(NewOutput(EncodingLinear), dithering, linear).NRGBA(m)
+
+			for y, v := range expected {
+				for x := 0; x < 16; x++ {
+					c := img.NRGBAAt(x, y)
+					if c.R != v || c.G != v || c.B != v || c.A != 0xFF {
+						t.Fatalf("%s (linear %v): input %v: got %v, want %d", name, linear, inputs[y], c, v)
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestQuantizerDiffusion(t *testing.T) {
+	// Taller than a few diffusion strips
+	m := gradient(image.Rect(3, 5, 103, 5+3*diffusionStripSize+17))
+	o := NewOutput(EncodingSRGB)
+	o.Saturation = 0.8
+
+	for name, kernel := range map[string][]diffusionWeight{"floydSteinberg": floydSteinberg, "sierra": sierra} {
+		q := NewQuantizer(o, ditherings[name], false)
+		expected := serialDiffusion(q, m, kernel)
+
+		for _, workers := range []int{1, 4} {
+			q.Output.Executor = &parallel.Executor{Workers: workers}
+			actual := q.RGBA(m)
+
+			for i, v := range expected.Pix {
+				if actual.Pix[i] != v {
+					p := i / 4
+					t.Fatalf("%s, %d workers: pixel (%d, %d): got %d, want %d", name, workers, p%m.Bounds().Dx(), p/m.Bounds().Dx(), actual.Pix[i], v)
+				}
+			}
+		}
+	}
+}
+
+// serialDiffusion is a straightforward serpentine error diffusion over the whole image.
+func serialDiffusion(q *Quantizer, m hdr.Image, kernel []diffusionWeight) *image.RGBA {
+	d := m.Bounds()
+	img := image.NewRGBA(d)
+	levels := q.levels()
+
+	errs := make([][3]float64, d.Dx()*d.Dy())
+	for y := 0; y < d.Dy(); y++ {
+		direction := 1 - 2*(y%2)
+		for n := 0; n < d.Dx(); n++ {
+			x := n
+			if direction < 0 {
+				x = d.Dx() - 1 - n
+			}
+
+			channels := q.channels(m, d.Min.X+x, d.Min.Y+y)
+			i := img.PixOffset(d.Min.X+x, d.Min.Y+y)
+			for c := range channels {
+				v := channels[c] + errs[y*d.Dx()+x][c]
+				level := levels.quantize(v, 0.5)
+				img.Pix[i+c] = level
+
+				for _, w := range kernel {
+					xx, yy := x+direction*w.dx, y+w.dy
+					if xx >= 0 && xx < d.Dx() && yy < d.Dy() {
+						errs[yy*d.Dx()+xx][c] += (v - levels[level]) * w.weight
+					}
+				}
+			}
+			img.Pix[i+3] = 0xFF
+		}
+	}
+
+	return img
+}
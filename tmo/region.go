@@ -0,0 +1,90 @@
+package tmo
+
+import (
+	"image"
+	"image/draw"
+	"math"
+
+	"github.com/mdouchement/hdr"
+	"github.com/mdouchement/hdr/filter"
+	"github.com/mdouchement/hdr/parallel"
+)
+
+const (
+	regionProxySize  = 512 // Largest side (pixels) of the proxy used for the global analysis
+	regionSigmaRange = 0.4 // Range sigma (log10 luminance) of the base layers upsampling
+)
+
+// A RegionOperator renders a region of interest of its HDR image (e.g. the viewport of an image viewer).
+// The global analysis (statistics, base layers) is computed once on a downscaled proxy of the whole image
+// and only the requested region is processed at the requested scale, so panning and zooming stay interactive.
+//
+// RenderRegion is not safe for concurrent use.
+type RegionOperator interface {
+	ToneMappingOperator
+	// RenderRegion tone maps the region r (in HDR image coordinates) resampled at scale (output pixels per HDR pixel)
+	// into dst, starting at dst.Bounds().Min.
+	RenderRegion(dst draw.Image, r image.Rectangle, scale float64)
+}
+
+// regionProxy returns the downscaled proxy of m and its scale.
+func regionProxy(e *parallel.Executor, m hdr.Image) (*hdr.RGB64, float64) {
+	return filter.ProxyWithExecutor(e, m, regionProxySize)
+}
+
+// regionTransform maps the pixels of the region r of m resampled at scale to the proxy coordinates.
+func regionTransform(m hdr.Image, r image.Rectangle, scale, proxyScale float64) func(x, y int) (float64, float64) {
+	r = r.Intersect(m.Bounds()).Sub(m.Bounds().Min)
+
+	return func(x, y int) (float64, float64) {
+		fx := float64(r.Min.X) + (float64(x)+0.5)/scale
+		fy := float64(r.Min.Y) + (float64(y)+0.5)/scale
+		return fx*proxyScale - 0.5, fy*proxyScale - 0.5
+	}
+}
+
+// upsampleLayer upsamples the proxy layer to the region r of m resampled at scale (joint bilateral upsampling).
+// When neither the proxy nor the region is resampled, the layer is cropped instead of blurred by the upsampling.
+func upsampleLayer(e *parallel.Executor, m, layer hdr.Image, lowGuide, guide *filter.Plane, r image.Rectangle, scale, proxyScale, sigmaRange float64) hdr.Image {
+	if scale != 1 || proxyScale != 1 {
+		return filter.JointBilateralUpsampleWithExecutor(e, layer, lowGuide, guide, regionTransform(m, r, scale, proxyScale), sigmaRange)
+	}
+
+	r = r.Intersect(m.Bounds()).Sub(m.Bounds().Min).Add(layer.Bounds().Min)
+	space := hdr.ModelSpace(layer.ColorModel())
+	dst := hdr.NewPlanar(image.Rect(0, 0, r.Dx(), r.Dy()), space)
+
+	completed := e.Lines(r.Dy(), func(y1, y2 int) {
+		row := make([]float64, 3*r.Dx())
+		for y := y1; y < y2; y++ {
+			hdr.ReadRow(layer, row, r.Min.X, r.Max.X, r.Min.Y+y, space)
+			dst.WriteRow(row, 0, r.Dx(), y, space)
+		}
+	})
+	<-completed
+
+	return dst
+}
+
+// log10Luminance returns the log10 luminance of m, the plane has a zero origin.
+func log10Luminance(e *parallel.Executor, m hdr.Image) *filter.Plane {
+	d := m.Bounds()
+	p := filter.NewPlane(d.Dx(), d.Dy())
+
+	completed := e.TilesR(d, func(x1, y1, x2, y2 int) {
+		for y := y1; y < y2; y++ {
+			for x := x1; x < x2; x++ {
+				_, Y, _, _ := m.HDRAt(x, y).HDRXYZA()
+				p.Set(x-d.Min.X, y-d.Min.Y, math.Log10(math.Max(Y, 0.0001)))
+			}
+		}
+	})
+	<-completed
+
+	return p
+}
+
+// drawRegion draws the rendered region m into dst, starting at dst.Bounds().Min.
+func drawRegion(dst draw.Image, m image.Image) {
+	draw.Draw(dst, dst.Bounds(), m, m.Bounds().Min, draw.Src)
+}
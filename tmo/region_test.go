@@ -0,0 +1,66 @@
+package tmo
+
+import (
+	"image"
+	"testing"
+)
+
+func TestRenderRegion(t *testing.T) {
+	// Smaller than the proxy so the analysis runs at full resolution
+	m := gradient(image.Rect(7, 3, 127, 83))
+
+	for _, name := range Registered() {
+		r, _ := Lookup(name)
+		if _, ok := r.New(m, r.Defaults()).(RegionOperator); !ok {
+			continue
+		}
+
+		t.Run(name, func(t *testing.T) {
+			expected := r.New(m, r.Defaults()).Perform()
+
+			tmo := r.New(m, r.Defaults()).(RegionOperator)
+			for _, region := range []image.Rectangle{m.Bounds(), image.Rect(20, 10, 60, 50), image.Rect(100, 70, 140, 90)} {
+				region = region.Intersect(m.Bounds())
+
+				// The destination origin is not the region one
+				actual := image.NewRGBA64(image.Rect(-5, 2, region.Dx()-5, region.Dy()+2))
+				tmo.RenderRegion(actual, region, 1)
+
+				for y := 0; y < region.Dy(); y++ {
+					for x := 0; x < region.Dx(); x++ {
+						r1, g1, b1, _ := expected.At(region.Min.X+x, region.Min.Y+y).RGBA()
+						r2, g2, b2, _ := actual.At(actual.Rect.Min.X+x, actual.Rect.Min.Y+y).RGBA()
+
+						// The region is read from a float64 copy of the image, rounding may differ by one
+						if diff(r1, r2) > 1 || diff(g1, g2) > 1 || diff(b1, b2) > 1 {
+							t.Fatalf("region %v: pixel (%d, %d): got %v, want %v", region, region.Min.X+x, region.Min.Y+y, []uint32{r2, g2, b2}, []uint32{r1, g1, b1})
+						}
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestRenderRegionScale(t *testing.T) {
+	m := gradient(image.Rect(0, 0, 64, 48))
+	region := image.Rect(10, 10, 30, 25)
+
+	for _, name := range Registered() {
+		r, _ := Lookup(name)
+		tmo, ok := r.New(m, r.Defaults()).(RegionOperator)
+		if !ok {
+			continue
+		}
+
+		// Every pixel of a region zoomed in is rendered
+		dst := image.NewRGBA64(image.Rect(0, 0, 3*region.Dx(), 3*region.Dy()))
+		tmo.RenderRegion(dst, region, 3)
+
+		for i := 6; i < len(dst.Pix); i += 8 {
+			if dst.Pix[i] != 0xFF || dst.Pix[i+1] != 0xFF {
+				t.Fatalf("%s: pixel %d is not rendered", name, i/8)
+			}
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package tmo
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+
+	"github.com/mdouchement/hdr"
+	"github.com/mdouchement/hdr/xmath"
+)
+
+// A ParameterType defines how a parameter value is interpreted.
+type ParameterType int
+
+const (
+	// ParameterFloat is a real value.
+	ParameterFloat ParameterType = iota
+	// ParameterInt is an integer value.
+	ParameterInt
+	// ParameterBool is a boolean value (0 is false, 1 is true).
+	ParameterBool
+	// ParameterEnum is the index of a value in Parameter.Values.
+	ParameterEnum
+)
+
+// A Parameter describes a TMO parameter.
+type Parameter struct {
+	Name    string
+	Type    ParameterType
+	Min     float64
+	Max     float64
+	Step    float64
+	Default float64
+	// Values are the names of the ParameterEnum values.
+	Values []string
+	Doc    string
+}
+
+// Clamp returns the given value clamped to the parameter range.
+// NaN values are replaced by the default value.
+func (p Parameter) Clamp(v float64) float64 {
+	if math.IsNaN(v) {
+		return p.Default
+	}
+
+	if p.Type != ParameterFloat {
+		v = math.Round(v)
+	}
+	return xmath.ClampF64(p.Min, p.Max, v)
+}
+
+// A Registration describes a registered TMO and how to build it.
+type Registration struct {
+	Name       string
+	Doc        string
+	Parameters []Parameter
+	// New instanciates the TMO with the given parameters.
+	// All the parameters are set and clamped to their range (Build and Session clamp them once with Parameter.Clamp).
+	New func(m hdr.Image, params map[string]float64) ToneMappingOperator
+	// Tune updates the parameters of a TMO built by New (optional).
+	// It is defined by the TMOs which reuse their analysis passes between Perform calls (see Session).
+	// The parameters are clamped as for New.
+	Tune func(t ToneMappingOperator, params map[string]float64)
+}
+
+// Parameter returns the parameter with the given name.
+func (r *Registration) Parameter(name string) (Parameter, bool) {
+	for _, p := range r.Parameters {
+		if p.Name == name {
+			return p, true
+		}
+	}
+	return Parameter{}, false
+}
+
+// Defaults returns the default value of each parameter.
+func (r *Registration) Defaults() map[string]float64 {
+	params := make(map[string]float64, len(r.Parameters))
+	for _, p := range r.Parameters {
+		params[p.Name] = p.Default
+	}
+	return params
+}
+
+// Build instanciates the TMO with the given parameters.
+// The missing parameters use their default value.
+func (r *Registration) Build(m hdr.Image, params map[string]float64) (ToneMappingOperator, error) {
+	values, err := r.values(params)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.New(m, values), nil
+}
+
+// values returns all the parameters clamped to their range, the missing ones use their default value.
+func (r *Registration) values(params map[string]float64) (map[string]float64, error) {
+	values := r.Defaults()
+	for name, v := range params {
+		p, ok := r.Parameter(name)
+		if !ok {
+			return nil, fmt.Errorf("tmo: %s has no parameter %q", r.Name, name)
+		}
+		values[name] = p.Clamp(v)
+	}
+
+	return values, nil
+}
+
+var registry = struct {
+	sync.RWMutex
+	registrations map[string]*Registration
+}{
+	registrations: map[string]*Registration{},
+}
+
+// Register makes a TMO available by the provided name.
+// If Register is called twice with the same name or if New is nil, it panics.
+func Register(r *Registration) {
+	registry.Lock()
+	defer registry.Unlock()
+
+	if r == nil || r.New == nil {
+		panic("tmo: Register TMO is nil")
+	}
+	if _, dup := registry.registrations[r.Name]; dup {
+		panic("tmo: Register called twice for TMO " + r.Name)
+	}
+	registry.registrations[r.Name] = r
+}
+
+// Lookup returns the TMO registered with the given name.
+func Lookup(name string) (*Registration, bool) {
+	registry.RLock()
+	defer registry.RUnlock()
+
+	r, ok := registry.registrations[name]
+	return r, ok
+}
+
+// Registered returns the sorted names of the registered TMOs.
+func Registered() []string {
+	registry.RLock()
+	defer registry.RUnlock()
+
+	names := make([]string, 0, len(registry.registrations))
+	for name := range registry.registrations {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
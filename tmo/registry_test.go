@@ -0,0 +1,82 @@
+package tmo
+
+import (
+	"image"
+	"math"
+	"testing"
+)
+
+func TestRegistrations(t *testing.T) {
+	for _, name := range Registered() {
+		r, ok := Lookup(name)
+		if !ok || r.Name != name {
+			t.Fatalf("%s: lookup failed", name)
+		}
+
+		names := map[string]bool{}
+		for _, p := range r.Parameters {
+			if names[p.Name] {
+				t.Errorf("%s: duplicated parameter %s", name, p.Name)
+			}
+			names[p.Name] = true
+
+			if !(p.Min <= p.Default && p.Default <= p.Max) {
+				t.Errorf("%s: %s: default %v is not in [%v, %v]", name, p.Name, p.Default, p.Min, p.Max)
+			}
+			if !(p.Step > 0) {
+				t.Errorf("%s: %s: got step %v, want a positive step", name, p.Name, p.Step)
+			}
+			if p.Type != ParameterFloat && p.Default != math.Round(p.Default) {
+				t.Errorf("%s: %s: got default %v, want an integer", name, p.Name, p.Default)
+			}
+			if p.Type == ParameterEnum && (p.Min != 0 || int(p.Max)+1 != len(p.Values)) {
+				t.Errorf("%s: %s: got range [%v, %v] for %d values", name, p.Name, p.Min, p.Max, len(p.Values))
+			}
+			if p.Doc == "" {
+				t.Errorf("%s: %s: missing doc", name, p.Name)
+			}
+		}
+	}
+}
+
+func TestParameterClamp(t *testing.T) {
+	f := Parameter{Type: ParameterFloat, Min: -1, Max: 2, Default: 0.5}
+	i := Parameter{Type: ParameterInt, Min: 1, Max: 10, Default: 4}
+
+	tests := []struct {
+		p        Parameter
+		v        float64
+		expected float64
+	}{
+		{f, 1.25, 1.25},
+		{f, -3, -1},
+		{f, math.Inf(1), 2},
+		{f, math.NaN(), 0.5},
+		{i, 2.6, 3},
+		{i, 42, 10},
+		{i, math.NaN(), 4},
+	}
+
+	for _, test := range tests {
+		if actual := test.p.Clamp(test.v); actual != test.expected {
+			t.Errorf("%v clamped to [%v, %v]: got %v, want %v", test.v, test.p.Min, test.p.Max, actual, test.expected)
+		}
+	}
+}
+
+func TestRegistrationBuild(t *testing.T) {
+	m := gradient(image.Rect(0, 0, 8, 8))
+	r, _ := Lookup("drago03")
+
+	tmo, err := r.Build(m, map[string]float64{"bias": 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bias := tmo.(*Drago03).Bias; bias != 1 {
+		t.Errorf("got bias %v, want it clamped to 1", bias)
+	}
+
+	if _, err := r.Build(m, map[string]float64{"unknown": 1}); err == nil {
+		t.Error("unknown parameter: got no error")
+	}
+}
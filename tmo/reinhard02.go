@@ -0,0 +1,294 @@
+package tmo
+
+import (
+	"image"
+	"image/draw"
+	"math"
+	"sync"
+
+	"github.com/mdouchement/hdr"
+	"github.com/mdouchement/hdr/filter"
+	"github.com/mdouchement/hdr/hdrcolor"
+	"github.com/mdouchement/hdr/parallel"
+	"github.com/mdouchement/hdr/xmath"
+)
+
+const (
+	reinhard02Gamma  = 2.2
+	reinhard02Scales = 8   // Number of center-surround scales
+	reinhard02Ratio  = 1.6 // Ratio between two successive scales
+)
+
+// A Reinhard02 is a TMO implementation based on Erik Reinhard's 2002 white paper.
+// It provides the global operator and the local dodging-and-burning operator.
+//
+// Reference:
+// Photographic Tone Reproduction for Digital Images.
+// E. Reinhard, M. Stark, P. Shirley and J. Ferwerda.
+// In ACM Transactions on Graphics, 2002.
+//
+// Parameter Estimation for Photographic Tone Reproduction.
+// E. Reinhard.
+// In Journal of Graphics Tools, 2003.
+type Reinhard02 struct {
+	HDRImage hdr.Image
+	// Key is included in [0, 1] with 0.01 increment step.
+	// 0 estimates the key value from the log-average luminance.
+	Key float64
+	// White is the smallest scaled luminance mapped to pure white (burn-out).
+	// 0 uses the maximum scaled luminance of the image.
+	White float64
+	// Local enables the dodging-and-burning operator.
+	Local bool
+	// Phi is the sharpening parameter of the local operator.
+	Phi float64
+	// Epsilon is the scale selection threshold of the local operator.
+	Epsilon float64
+	lumOnce sync.Once
+	minLum  float64
+	maxLum  float64
+	logAvg  float64
+	// Region rendering
+	proxyOnce       sync.Once
+	proxyStatistics Statistics
+	executable
+}
+
+// NewDefaultReinhard02 instanciates a new Reinhard02 TMO with default parameters.
+func NewDefaultReinhard02(m hdr.Image) *Reinhard02 {
+	return NewReinhard02(m, 0.18, 0, false)
+}
+
+// NewReinhard02 instanciates a new Reinhard02 TMO.
+func NewReinhard02(m hdr.Image, key, white float64, local bool) *Reinhard02 {
+	return &Reinhard02{
+		HDRImage: m,
+		Key:      key,
+		White:    math.Max(0, white),
+		Local:    local,
+		Phi:      8,
+		Epsilon:  0.05,
+		minLum:   math.Inf(1),
+		maxLum:   math.Inf(-1),
+	}
+}
+
+// Perform runs the TMO mapping.
+func (t *Reinhard02) Perform() image.Image {
+	return t.encode(NewGammaOutput(reinhard02Gamma), t.PerformHDR())
+}
+
+// PerformHDR runs the TMO mapping and returns display-referred linear values.
+func (t *Reinhard02) PerformHDR() hdr.Image {
+	img := hdr.NewRGB64(t.HDRImage.Bounds())
+
+	t.lumOnce.Do(t.luminance) // First pass
+
+	key := t.key()
+	scale := key / t.logAvg
+
+	white := t.White
+	if white <= 0 {
+		white = t.maxLum * scale
+	}
+
+	var adaptation hdr.Image
+	if t.Local {
+		adaptation = t.adaptation(key, scale) // Second pass
+	}
+
+	t.tonemap(img, scale, white, adaptation) // Third pass
+
+	return img
+}
+
+// Statistics computes (once) and returns the adaptation statistics of the HDR image.
+func (t *Reinhard02) Statistics() Statistics {
+	t.lumOnce.Do(t.luminance)
+	return Statistics{
+		MinLum: t.minLum,
+		MaxLum: t.maxLum,
+		LogAvg: t.logAvg,
+	}
+}
+
+// SetStatistics overrides the adaptation statistics used by Perform.
+func (t *Reinhard02) SetStatistics(s Statistics) {
+	t.lumOnce.Do(func() {})
+	t.minLum = s.MinLum
+	t.maxLum = s.MaxLum
+	t.logAvg = s.LogAvg
+}
+
+// RenderRegion implements RegionOperator, the statistics are computed on a proxy of the whole image.
+func (t *Reinhard02) RenderRegion(dst draw.Image, r image.Rectangle, scale float64) {
+	t.proxyOnce.Do(func() {
+		proxy, _ := regionProxy(t.executor, t.HDRImage)
+		t.proxyStatistics = NewReinhard02(proxy, t.Key, t.White, false).Statistics()
+	})
+
+	region := NewReinhard02(filter.ResampleWithExecutor(t.executor, t.HDRImage, r, scale), t.Key, t.White, t.Local)
+	region.executor = t.executor
+	region.Phi = t.Phi
+	region.Epsilon = t.Epsilon
+	region.SetStatistics(t.proxyStatistics)
+	drawRegion(dst, region.Perform())
+}
+
+func (t *Reinhard02) luminance() {
+	qsImg := quickSampling(t.HDRImage)
+
+	partials := parallel.Partials(t.executor, qsImg.Bounds(), func(x1, y1, x2, y2 int) Statistics {
+		s := Statistics{
+			MinLum: math.Inf(1),
+			MaxLum: math.Inf(-1),
+		}
+		var logSum xmath.KahanSum
+
+		row := make([]float64, 3*(x2-x1))
+		for y := y1; y < y2; y++ {
+			hdr.ReadRow(qsImg, row, x1, x2, y, hdr.XYZSpace)
+			for i := 0; i < len(row); i += 3 {
+				lum := row[i+1]
+
+				s.MinLum = math.Min(s.MinLum, lum)
+				s.MaxLum = math.Max(s.MaxLum, lum)
+				logSum.Add(math.Log((2.3e-5) + lum))
+			}
+		}
+
+		s.LogAvg = logSum.Value() // Sum of the tile
+		return s
+	})
+
+	logSums := make([]float64, len(partials))
+	for i, s := range partials {
+		t.minLum = math.Min(t.minLum, s.MinLum)
+		t.maxLum = math.Max(t.maxLum, s.MaxLum)
+		logSums[i] = s.LogAvg
+	}
+
+	t.logAvg = math.Exp(xmath.PairwiseSum(logSums) / float64(qsImg.Size()))
+}
+
+// key returns the key value or estimates it from the log-average luminance (Reinhard 2003).
+func (t *Reinhard02) key() float64 {
+	if t.Key > 0 {
+		return t.Key
+	}
+
+	l1 := math.Log2(math.Max(t.minLum, 2.3e-5))
+	l2 := math.Log2(t.maxLum)
+	lav := math.Log2(t.logAvg)
+	if l2 <= l1 {
+		return 0.18
+	}
+
+	return 0.18 * math.Pow(4, (2*lav-l1-l2)/(l2-l1))
+}
+
+// adaptation returns the local adaptation luminance V1(x, y, sm) for each pixel
+// where sm is the largest scale with a center-surround difference lower than Epsilon.
+func (t *Reinhard02) adaptation(key, scale float64) hdr.Image {
+	d := t.HDRImage.Bounds()
+	lum := hdr.NewRGB64(d)
+	adaptation := hdr.NewRGB64(d)
+	done := make([]bool, d.Dx()*d.Dy())
+
+	completed := t.executor.TilesR(d, func(x1, y1, x2, y2 int) {
+		row := make([]float64, 3*(x2-x1))
+		for y := y1; y < y2; y++ {
+			hdr.ReadRow(t.HDRImage, row, x1, x2, y, hdr.XYZSpace)
+			for x, i := x1, 0; x < x2; x, i = x+1, i+3 {
+				Y := row[i+1] * scale
+				lum.SetRGB(x, y, hdrcolor.RGB{R: Y, G: Y, B: Y})
+			}
+		}
+	})
+	<-completed
+
+	s := 1.0
+	v1 := filter.FastGaussianWithExecutor(t.executor, lum, 1)
+	for i := 0; i < reinhard02Scales; i++ {
+		v2 := filter.FastGaussianWithExecutor(t.executor, lum, int(math.Round(s*reinhard02Ratio)))
+		threshold := math.Pow(2, t.Phi) * key / (s * s)
+
+		completed = t.executor.TilesR(d, func(x1, y1, x2, y2 int) {
+			for y := y1; y < y2; y++ {
+				for x := x1; x < x2; x++ {
+					n := (y-d.Min.Y)*d.Dx() + x - d.Min.X
+					if done[n] {
+						continue
+					}
+
+					c1, _, _, _ := v1.HDRAt(x, y).HDRRGBA()
+					c2, _, _, _ := v2.HDRAt(x, y).HDRRGBA()
+
+					if i == 0 {
+						adaptation.SetRGB(x, y, hdrcolor.RGB{R: c1, G: c1, B: c1})
+					}
+
+					if math.Abs((c1-c2)/(threshold+c1)) >= t.Epsilon {
+						done[n] = true
+						continue
+					}
+					adaptation.SetRGB(x, y, hdrcolor.RGB{R: c1, G: c1, B: c1})
+				}
+			}
+		})
+		<-completed
+
+		v1 = v2
+		s *= reinhard02Ratio
+	}
+
+	return adaptation
+}
+
+func (t *Reinhard02) tonemap(img *hdr.RGB64, scale, white float64, adaptation hdr.Image) {
+	white2 := white * white
+
+	completed := t.executor.TilesR(t.HDRImage.Bounds(), func(x1, y1, x2, y2 int) {
+		rgb := make([]float64, 3*(x2-x1))
+		xyz := make([]float64, 3*(x2-x1))
+		var local []float64
+		if adaptation != nil {
+			local = make([]float64, 3*(x2-x1))
+		}
+
+		for y := y1; y < y2; y++ {
+			hdr.ReadRow(t.HDRImage, rgb, x1, x2, y, hdr.RGBSpace)
+			hdr.ReadRow(t.HDRImage, xyz, x1, x2, y, hdr.XYZSpace)
+			if adaptation != nil {
+				hdr.ReadRow(adaptation, local, x1, x2, y, hdr.RGBSpace)
+			}
+
+			for x, i := x1, 0; x < x2; x, i = x+1, i+3 {
+				lum := xyz[i+1]
+
+				if lum <= 0 {
+					img.SetRGB(x, y, hdrcolor.RGB{})
+					continue
+				}
+
+				lm := lum * scale
+				ld := lm * (1 + lm/white2) // Burn-out
+				if adaptation != nil {
+					ld /= 1 + local[i] // Dodging-and-burning
+				} else {
+					ld /= 1 + lm
+				}
+
+				ratio := ld / lum
+
+				img.SetRGB(x, y, hdrcolor.RGB{
+					R: rgb[i+0] * ratio,
+					G: rgb[i+1] * ratio,
+					B: rgb[i+2] * ratio,
+				})
+			}
+		}
+	})
+
+	<-completed
+}
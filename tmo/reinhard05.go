@@ -2,14 +2,15 @@ package tmo
 
 import (
 	"image"
-	"image/color"
+	"image/draw"
 	"math"
 	"sync"
 
 	"github.com/mdouchement/hdr"
 	"github.com/mdouchement/hdr/filter"
-	"github.com/mdouchement/hdr/xmath"
+	"github.com/mdouchement/hdr/hdrcolor"
 	"github.com/mdouchement/hdr/parallel"
+	"github.com/mdouchement/hdr/xmath"
 )
 
 const (
@@ -39,6 +40,10 @@ type Reinhard05 struct {
 	k        float64
 	m        float64
 	f        float64
+	// proxy holds the statistics and the normalization range of RenderRegion.
+	proxyOnce sync.Once
+	proxy     *Reinhard05
+	executable
 }
 
 // NewDefaultReinhard05 instanciates a new Reinhard05 TMO with default parameters.
@@ -61,10 +66,17 @@ func NewReinhard05(m hdr.Image, brightness, chromatic, light float64) *Reinhard0
 
 // Perform runs the TMO mapping.
 func (t *Reinhard05) Perform() image.Image {
-	img := image.NewRGBA64(t.HDRImage.Bounds())
+	return t.encode(NewGammaOutput(reinhardGamma), t.PerformHDR())
+}
+
+// PerformHDR runs the TMO mapping and returns display-referred linear values.
+func (t *Reinhard05) PerformHDR() hdr.Image {
+	img := hdr.NewRGB64(t.HDRImage.Bounds())
 
 	t.lumOnce.Do(t.luminance) // First pass
 
+	t.f = math.Exp(-t.Brightness) // Image brightness
+
 	minSample, maxSample := t.tonemap() // Second pass
 
 	t.normalize(img, minSample, maxSample) // Third pass
@@ -73,48 +85,51 @@ func (t *Reinhard05) Perform() image.Image {
 }
 
 func (t *Reinhard05) luminance() {
-	reinhardCh := make(chan *Reinhard05)
-	qsImg := filter.NewQuickSampling(t.HDRImage, 0.6)
+	qsImg := quickSampling(t.HDRImage)
 
-	completed := parallel.TilesR(qsImg.Bounds(), func(x1, y1, x2, y2 int) {
-		tt := NewDefaultReinhard05(nil)
+	// Per tile statistics: min & max luminances, world luminance, channel averages and luminance average.
+	partials := parallel.Partials(t.executor, qsImg.Bounds(), func(x1, y1, x2, y2 int) [7]float64 {
+		var worldLum, r, g, b, lav xmath.KahanSum
+		minLum, maxLum := math.Inf(1), math.Inf(-1)
 
+		rgb := make([]float64, 3*(x2-x1))
+		xyz := make([]float64, 3*(x2-x1))
 		for y := y1; y < y2; y++ {
-			for x := x1; x < x2; x++ {
-				pixel := qsImg.HDRAt(x, y)
-
-				_, lum, _, _ := pixel.HDRXYZA()
-				tt.minLum = math.Min(tt.minLum, lum)
-				tt.maxLum = math.Max(tt.maxLum, lum)
-				tt.worldLum += math.Log((2.3e-5) + lum)
-
-				r, g, b, _ := pixel.HDRRGBA()
-				tt.cav[0] += r
-				tt.cav[1] += g
-				tt.cav[2] += b
-				tt.lav += lum
+			hdr.ReadRow(qsImg, rgb, x1, x2, y, hdr.RGBSpace)
+			hdr.ReadRow(qsImg, xyz, x1, x2, y, hdr.XYZSpace)
+			for i := 0; i < len(rgb); i += 3 {
+				lum := xyz[i+1]
+				minLum = math.Min(minLum, lum)
+				maxLum = math.Max(maxLum, lum)
+				worldLum.Add(math.Log((2.3e-5) + lum))
+
+				r.Add(rgb[i+0])
+				g.Add(rgb[i+1])
+				b.Add(rgb[i+2])
+				lav.Add(lum)
 			}
 		}
 
-		reinhardCh <- tt
+		return [7]float64{minLum, maxLum, worldLum.Value(), r.Value(), g.Value(), b.Value(), lav.Value()}
 	})
 
-	for {
-		select {
-		case <-completed:
-			goto NEXT
-		case tt := <-reinhardCh:
-			t.minLum = math.Min(t.minLum, tt.minLum)
-			t.maxLum = math.Max(t.maxLum, tt.maxLum)
-			t.worldLum += tt.worldLum
-
-			t.cav[0] += tt.cav[0]
-			t.cav[1] += tt.cav[1]
-			t.cav[2] += tt.cav[2]
-			t.lav += tt.lav
+	sums := make([][]float64, 5)
+	for i := range sums {
+		sums[i] = make([]float64, len(partials))
+	}
+	for i, partial := range partials {
+		t.minLum = math.Min(t.minLum, partial[0])
+		t.maxLum = math.Max(t.maxLum, partial[1])
+		for j := range sums {
+			sums[j][i] = partial[2+j]
 		}
 	}
-NEXT:
+
+	t.worldLum = xmath.PairwiseSum(sums[0])
+	t.cav[0] = xmath.PairwiseSum(sums[1])
+	t.cav[1] = xmath.PairwiseSum(sums[2])
+	t.cav[2] = xmath.PairwiseSum(sums[3])
+	t.lav = xmath.PairwiseSum(sums[4])
 
 	size := float64(qsImg.Size())
 	t.worldLum /= size
@@ -126,12 +141,62 @@ NEXT:
 	t.minLum = math.Log(t.minLum)
 	t.maxLum = math.Log(t.maxLum)
 
+	t.contrast()
+}
+
+// contrast computes the image key and contrast from the luminance statistics.
+func (t *Reinhard05) contrast() {
 	// Image key
 	t.k = (t.maxLum - t.worldLum) / (t.maxLum - t.minLum)
 	// Image contrast based on key value
 	t.m = (0.3 + (0.7 * math.Pow(t.k, 1.4)))
-	// Image brightness
-	t.f = math.Exp(-t.Brightness)
+}
+
+// Statistics computes (once) and returns the adaptation statistics of the HDR image.
+func (t *Reinhard05) Statistics() Statistics {
+	t.lumOnce.Do(t.luminance)
+	return Statistics{
+		MinLum: math.Exp(t.minLum),
+		MaxLum: math.Exp(t.maxLum),
+		LogAvg: math.Exp(t.worldLum),
+		AvgLum: t.lav,
+		AvgRGB: [3]float64{t.cav[0], t.cav[1], t.cav[2]},
+	}
+}
+
+// SetStatistics overrides the adaptation statistics used by Perform.
+func (t *Reinhard05) SetStatistics(s Statistics) {
+	t.lumOnce.Do(func() {})
+	t.minLum = math.Log(s.MinLum)
+	t.maxLum = math.Log(s.MaxLum)
+	t.worldLum = math.Log(s.LogAvg)
+	t.lav = s.AvgLum
+	copy(t.cav, s.AvgRGB[:])
+	t.contrast()
+}
+
+// RenderRegion implements RegionOperator, the statistics and the normalization range are computed on a proxy of the whole image.
+func (t *Reinhard05) RenderRegion(dst draw.Image, r image.Rectangle, scale float64) {
+	t.proxyOnce.Do(func() {
+		proxy, _ := regionProxy(t.executor, t.HDRImage)
+		t.proxy = NewReinhard05(proxy, t.Brightness, t.Chromatic, t.Light)
+	})
+
+	// The normalization range depends on the parameters
+	t.proxy.executor = t.executor
+	t.proxy.Brightness, t.proxy.Chromatic, t.proxy.Light = t.Brightness, t.Chromatic, t.Light
+	t.proxy.lumOnce.Do(t.proxy.luminance)
+	t.proxy.f = math.Exp(-t.proxy.Brightness)
+	minSample, maxSample := t.proxy.tonemap()
+
+	region := NewReinhard05(filter.ResampleWithExecutor(t.executor, t.HDRImage, r, scale), t.Brightness, t.Chromatic, t.Light)
+	region.executor = t.executor
+	region.SetStatistics(t.proxy.Statistics())
+	region.f = math.Exp(-region.Brightness)
+
+	img := hdr.NewRGB64(region.HDRImage.Bounds())
+	region.normalize(img, minSample, maxSample)
+	drawRegion(dst, region.encode(NewGammaOutput(reinhardGamma), img))
 }
 
 func (t *Reinhard05) tonemap() (minSample, maxSample float64) {
@@ -140,17 +205,20 @@ func (t *Reinhard05) tonemap() (minSample, maxSample float64) {
 	minCh := make(chan float64)
 	maxCh := make(chan float64)
 
-	qsImg := filter.NewQuickSampling(t.HDRImage, 0.6)
+	qsImg := quickSampling(t.HDRImage)
 
-	completed := parallel.TilesR(qsImg.Bounds(), func(x1, y1, x2, y2 int) {
+	completed := t.executor.TilesR(qsImg.Bounds(), func(x1, y1, x2, y2 int) {
 		min := 1.0
 		max := 0.0
 
+		rgb := make([]float64, 3*(x2-x1))
+		xyz := make([]float64, 3*(x2-x1))
 		for y := y1; y < y2; y++ {
-			for x := x1; x < x2; x++ {
-				pixel := qsImg.HDRAt(x, y)
-				r, g, b, _ := pixel.HDRRGBA()
-				_, lum, _, _ := pixel.HDRXYZA()
+			hdr.ReadRow(qsImg, rgb, x1, x2, y, hdr.RGBSpace)
+			hdr.ReadRow(qsImg, xyz, x1, x2, y, hdr.XYZSpace)
+			for i := 0; i < len(rgb); i += 3 {
+				r, g, b := rgb[i+0], rgb[i+1], rgb[i+2]
+				lum := xyz[i+1]
 
 				var sample float64
 
@@ -202,19 +270,21 @@ func (t *Reinhard05) sampling(sample, lum float64, c int) float64 {
 	return sample
 }
 
-func (t *Reinhard05) normalize(img *image.RGBA64, minSample, maxSample float64) {
-	completed := parallel.TilesR(t.HDRImage.Bounds(), func(x1, y1, x2, y2 int) {
+func (t *Reinhard05) normalize(img *hdr.RGB64, minSample, maxSample float64) {
+	completed := t.executor.TilesR(t.HDRImage.Bounds(), func(x1, y1, x2, y2 int) {
+		rgb := make([]float64, 3*(x2-x1))
+		xyz := make([]float64, 3*(x2-x1))
 		for y := y1; y < y2; y++ {
-			for x := x1; x < x2; x++ {
-				pixel := t.HDRImage.HDRAt(x, y)
-				r, g, b, _ := pixel.HDRRGBA()
-				_, lum, _, _ := pixel.HDRXYZA()
+			hdr.ReadRow(t.HDRImage, rgb, x1, x2, y, hdr.RGBSpace)
+			hdr.ReadRow(t.HDRImage, xyz, x1, x2, y, hdr.XYZSpace)
+			for x, i := x1, 0; x < x2; x, i = x+1, i+3 {
+				r, g, b := rgb[i+0], rgb[i+1], rgb[i+2]
+				lum := xyz[i+1]
 
-				img.SetRGBA64(x, y, color.RGBA64{
+				img.SetRGB(x, y, hdrcolor.RGB{
 					R: t.nrmz(t.sampling(r, lum, 0), minSample, maxSample),
 					G: t.nrmz(t.sampling(g, lum, 1), minSample, maxSample),
 					B: t.nrmz(t.sampling(b, lum, 2), minSample, maxSample),
-					A: RangeMax,
 				})
 			}
 		}
@@ -224,20 +294,6 @@ func (t *Reinhard05) normalize(img *image.RGBA64, minSample, maxSample float64)
 }
 
 // normalize one channel
-func (t *Reinhard05) nrmz(channel, minSample, maxSample float64) uint16 {
-	// Normalize intensities
-	channel = (channel - minSample) / (maxSample - minSample)
-
-	// Gamma correction
-	if channel > RangeMin {
-		channel = math.Pow(channel, 1/reinhardGamma)
-	}
-
-	// Inverse pixel mapping
-	channel = LinearInversePixelMapping(channel, LumPixFloor, LumSize)
-
-	// Clamp to solid black and solid white
-	channel = LDRClamp(channel)
-
-	return uint16(channel)
+func (t *Reinhard05) nrmz(channel, minSample, maxSample float64) float64 {
+	return (channel - minSample) / (maxSample - minSample)
 }
@@ -0,0 +1,94 @@
+package tmo
+
+import (
+	"image"
+	"math"
+
+	"github.com/mdouchement/hdr"
+	"github.com/mdouchement/hdr/hdrcolor"
+)
+
+// A Schlick94 is a rational mapping TMO implementation based on Christophe Schlick's 1994 white paper.
+//
+// Reference:
+// Quantization Techniques for Visualization of High Dynamic Range Pictures.
+// C. Schlick.
+// In Photorealistic Rendering Techniques (Eurographics Workshop on Rendering), 1994.
+type Schlick94 struct {
+	HDRImage hdr.Image
+	Display
+	// P is the rational mapping parameter (>= 1).
+	// 0 estimates it so the darkest luminance is mapped to the darkest display luminance.
+	P float64
+	executable
+}
+
+// NewDefaultSchlick94 instanciates a new Schlick94 TMO with default parameters.
+func NewDefaultSchlick94(m hdr.Image) *Schlick94 {
+	return NewSchlick94(m, NewDefaultDisplay(), 0)
+}
+
+// NewSchlick94 instanciates a new Schlick94 TMO.
+func NewSchlick94(m hdr.Image, display Display, p float64) *Schlick94 {
+	if p != 0 {
+		p = math.Max(1, p)
+	}
+
+	return &Schlick94{
+		HDRImage: m,
+		Display:  display,
+		P:        p,
+	}
+}
+
+// Perform runs the TMO mapping.
+func (t *Schlick94) Perform() image.Image {
+	return t.encode(NewGammaOutput(displayGamma), t.PerformHDR())
+}
+
+// PerformHDR runs the TMO mapping and returns display-referred linear values.
+func (t *Schlick94) PerformHDR() hdr.Image {
+	img := hdr.NewRGB64(t.HDRImage.Bounds())
+
+	wl := newWorldLuminance(t.executor, t.HDRImage)
+
+	p := t.P
+	if p == 0 {
+		// Solve F(Lmin / Lmax) = 1 / Cmax
+		x := wl.minLum / wl.maxLum
+		p = math.Max(1, (1-x)/(x*(t.Cmax-1)))
+		if math.IsNaN(p) || math.IsInf(p, 0) {
+			p = 1
+		}
+	}
+
+	completed := t.executor.TilesR(t.HDRImage.Bounds(), func(x1, y1, x2, y2 int) {
+		for y := y1; y < y2; y++ {
+			for x := x1; x < x2; x++ {
+				pixel := t.HDRImage.HDRAt(x, y)
+				_, lw, _, _ := pixel.HDRXYZA()
+
+				if lw <= 0 {
+					img.SetRGB(x, y, hdrcolor.RGB{})
+					continue
+				}
+
+				v := lw / wl.maxLum
+				ld := t.Ldmax * p * v / (p*v - v + 1)
+
+				r, g, b, _ := pixel.HDRRGBA()
+				s := ld / lw
+
+				img.SetRGB(x, y, hdrcolor.RGB{
+					R: r * s / t.Ldmax,
+					G: g * s / t.Ldmax,
+					B: b * s / t.Ldmax,
+				})
+			}
+		}
+	})
+
+	<-completed
+
+	return img
+}
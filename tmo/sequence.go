@@ -0,0 +1,229 @@
+package tmo
+
+import (
+	"image"
+	"image/color"
+	"math"
+
+	"github.com/mdouchement/hdr"
+	"github.com/mdouchement/hdr/parallel"
+	"github.com/mdouchement/hdr/xmath"
+)
+
+// Statistics holds the scene luminance statistics an operator adapts to.
+// The operators only fill the statistics they use.
+type Statistics struct {
+	MinLum float64
+	MaxLum float64
+	// LogAvg is the log-average (geometric mean) luminance.
+	LogAvg float64
+	// AvgLum is the arithmetic average luminance.
+	AvgLum float64
+	// AvgRGB is the arithmetic average of each RGB channel.
+	AvgRGB [3]float64
+}
+
+// An AdaptiveOperator is a ToneMappingOperator whose adaptation statistics can be overridden.
+// It allows a Sequence to smooth the statistics over time.
+//
+// It is implemented by Drago03, ICam06, Reinhard02 and Reinhard05.
+type AdaptiveOperator interface {
+	ToneMappingOperator
+	// Statistics computes (once) and returns the adaptation statistics of the HDR image.
+	Statistics() Statistics
+	// SetStatistics overrides the adaptation statistics used by Perform.
+	SetStatistics(Statistics)
+}
+
+// A Sequence tone maps a stream of HDR frames (timelapse, animation, video)
+// with temporally coherent adaptation to avoid flickering.
+//
+// The adaptation statistics of AdaptiveOperator are smoothed with a leaky integrator
+// and the brightness of all the operators can be stabilized with a brightness coherency
+// post-processing that keeps the ratio between the LDR and HDR keys (log-average luminances) steady.
+// The other operators (e.g. Linear, Logarithmic, Durand) only get the brightness coherency,
+// which is applied when Perform returns an *image.RGBA64 (all the built-in operators).
+//
+// Reference:
+// Time-Dependent Visual Adaptation for Fast Realistic Image Display.
+// S. N. Pattanaik, J. Tumblin, H. Yee and D. P. Greenberg.
+// In SIGGRAPH, 2000.
+//
+// Real Time Automated Tone Mapping System for HDR Video.
+// C. Kiser, E. Reinhard, M. Tocci and N. Tocci.
+// In IEEE International Conference on Image Processing, 2012.
+//
+// Temporal Coherency for Video Tone Mapping.
+// R. Boitard, K. Bouatouch, R. Cozot, D. Thoreau and A. Gruson.
+// In SPIE Applications of Digital Image Processing, 2012.
+type Sequence struct {
+	// Operator instanciates the operator used for a frame.
+	Operator func(m hdr.Image) ToneMappingOperator
+	// Smoothing is included in [0, 0.99] with 0.01 increment step.
+	// It is the weight of the past in the leaky integration (0.9 is about 10 frames), 0 disables the smoothing.
+	Smoothing float64
+	// BrightnessCoherency enables the brightness coherency post-processing.
+	BrightnessCoherency bool
+	// Zeta is included in [0, 1] with 0.01 increment step.
+	// It is the minimum scale ratio of the brightness coherency (prevents too dark frames).
+	Zeta float64
+	// Executor runs the frames mapping (optional).
+	// Perform stops emitting the frames once its context is done.
+	Executor *parallel.Executor
+	stats    *Statistics
+	ratio    *float64 // Smoothed log(LDR key / HDR key)
+}
+
+// NewSequence instanciates a new Sequence with default parameters.
+func NewSequence(operator func(m hdr.Image) ToneMappingOperator) *Sequence {
+	return &Sequence{
+		Operator:            operator,
+		Smoothing:           0.9,
+		BrightnessCoherency: true,
+		Zeta:                0.1,
+	}
+}
+
+// Reset forgets the past frames (e.g. on a scene cut).
+func (s *Sequence) Reset() {
+	s.stats = nil
+	s.ratio = nil
+}
+
+// Perform tone maps the frames until the channel is closed.
+// The LDR frames are emitted in the same order.
+//
+// The caller must read the LDR frames until the returned channel is closed or cancel the executor context.
+// Once the context is done, Perform stops reading the frames and closes the returned channel.
+func (s *Sequence) Perform(frames <-chan hdr.Image) <-chan image.Image {
+	out := make(chan image.Image)
+	done := s.Executor.Done()
+
+	go func() {
+		defer close(out)
+
+		for {
+			var m hdr.Image
+			select {
+			case <-done:
+				return
+			case frame, ok := <-frames:
+				if !ok {
+					return
+				}
+				m = frame
+			}
+
+			img := s.Next(m)
+			if s.Executor.Err() != nil {
+				return
+			}
+
+			select {
+			case <-done:
+				return
+			case out <- img:
+			}
+		}
+	}()
+
+	return out
+}
+
+// Next tone maps the next frame of the sequence.
+func (s *Sequence) Next(m hdr.Image) image.Image {
+	smoothing := xmath.ClampF64(0, 0.99, s.Smoothing)
+	t := s.Operator(m)
+	if et, ok := t.(ExecutableOperator); ok {
+		et.SetExecutor(s.Executor)
+	}
+
+	var key float64
+	if at, ok := t.(AdaptiveOperator); ok {
+		stats := at.Statistics()
+		if s.stats == nil {
+			s.stats = &stats
+		} else {
+			s.stats.MinLum = leaky(s.stats.MinLum, stats.MinLum, smoothing)
+			s.stats.MaxLum = leaky(s.stats.MaxLum, stats.MaxLum, smoothing)
+			s.stats.LogAvg = leaky(s.stats.LogAvg, stats.LogAvg, smoothing)
+			s.stats.AvgLum = leaky(s.stats.AvgLum, stats.AvgLum, smoothing)
+			for c := range s.stats.AvgRGB {
+				s.stats.AvgRGB[c] = leaky(s.stats.AvgRGB[c], stats.AvgRGB[c], smoothing)
+			}
+		}
+		at.SetStatistics(*s.stats)
+		key = stats.LogAvg
+	}
+
+	img := t.Perform()
+	if !s.BrightnessCoherency {
+		return img
+	}
+
+	if key <= 0 {
+		key = newWorldLuminance(s.Executor, m).logAvg
+	}
+	ldr, ok := img.(*image.RGBA64)
+	if !ok || key <= 0 {
+		return img
+	}
+
+	ratio := math.Log(ldrKey(s.Executor, ldr) / key)
+	if s.ratio == nil {
+		s.ratio = &ratio
+		return img
+	}
+	*s.ratio = smoothing*(*s.ratio) + (1-smoothing)*ratio
+
+	// Bring the current key ratio toward the smoothed one
+	scale := s.Zeta + (1-s.Zeta)*math.Exp(*s.ratio-ratio)
+	s.scale(ldr, scale)
+
+	return img
+}
+
+func (s *Sequence) scale(img *image.RGBA64, scale float64) {
+	completed := s.Executor.TilesR(img.Bounds(), func(x1, y1, x2, y2 int) {
+		for y := y1; y < y2; y++ {
+			for x := x1; x < x2; x++ {
+				c := img.RGBA64At(x, y)
+
+				img.SetRGBA64(x, y, color.RGBA64{
+					R: uint16(LDRClamp(float64(c.R) * scale)),
+					G: uint16(LDRClamp(float64(c.G) * scale)),
+					B: uint16(LDRClamp(float64(c.B) * scale)),
+					A: c.A,
+				})
+			}
+		}
+	})
+
+	<-completed
+}
+
+// leaky is a leaky integration in the log domain.
+func leaky(past, current, smoothing float64) float64 {
+	if past <= 0 || current <= 0 || math.IsInf(past, 0) || math.IsInf(current, 0) {
+		return current
+	}
+	return math.Exp(smoothing*math.Log(past) + (1-smoothing)*math.Log(current))
+}
+
+// ldrKey returns the log-average luminance of an LDR image in [0, 1].
+func ldrKey(e *parallel.Executor, img *image.RGBA64) float64 {
+	sum := parallel.Sum(e, img.Bounds(), func(x1, y1, x2, y2 int) float64 {
+		var sum xmath.KahanSum
+		for y := y1; y < y2; y++ {
+			for x := x1; x < x2; x++ {
+				c := img.RGBA64At(x, y)
+				lum := (0.2126*float64(c.R) + 0.7152*float64(c.G) + 0.0722*float64(c.B)) / RangeMax
+				sum.Add(math.Log(lum + 1e-4))
+			}
+		}
+		return sum.Value()
+	})
+
+	d := img.Bounds()
+	return math.Exp(sum / float64(d.Dx()*d.Dy()))
+}
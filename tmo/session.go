@@ -0,0 +1,117 @@
+package tmo
+
+import (
+	"fmt"
+	"image"
+	"sync"
+
+	"github.com/mdouchement/hdr"
+	"github.com/mdouchement/hdr/parallel"
+)
+
+// A Session tone maps the same HDR image several times with different parameters (e.g. interactive editing).
+// The parameter-independent analysis of the TMOs (statistics, base layers, white adaptation images)
+// is computed on the first Perform and reused by the next ones, so only the per-pixel stage is redone.
+// The TMOs without Registration.Tune are rebuilt on each Perform.
+//
+// A Session is safe for concurrent use, the Perform calls are serialized.
+type Session struct {
+	HDRImage  hdr.Image
+	mu        sync.Mutex
+	operators map[string]ToneMappingOperator
+}
+
+// NewSession instanciates a new Session for the given HDR image.
+func NewSession(m hdr.Image) *Session {
+	return &Session{
+		HDRImage:  m,
+		operators: map[string]ToneMappingOperator{},
+	}
+}
+
+// Perform runs the registered TMO name with the given parameters.
+func (s *Session) Perform(name string, params map[string]float64) (image.Image, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t, err := s.operator(name, params)
+	if err != nil {
+		return nil, err
+	}
+
+	return t.Perform(), nil
+}
+
+// PerformWithExecutor runs the registered TMO name with the given parameters on the executor.
+// When the executor context is done, the cached analysis of the TMO is dropped.
+func (s *Session) PerformWithExecutor(e *parallel.Executor, name string, params map[string]float64) (image.Image, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t, err := s.operator(name, params)
+	if err != nil {
+		return nil, err
+	}
+
+	img, err := PerformWithExecutor(e, t)
+	if et, ok := t.(ExecutableOperator); ok {
+		et.SetExecutor(nil)
+	}
+	if err != nil {
+		delete(s.operators, name) // The analysis may be incomplete
+		return nil, err
+	}
+	return img, nil
+}
+
+// PerformHDR runs the registered TMO name with the given parameters and returns display-referred linear values.
+func (s *Session) PerformHDR(name string, params map[string]float64) (hdr.Image, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t, err := s.operator(name, params)
+	if err != nil {
+		return nil, err
+	}
+
+	ht, ok := t.(HDRToneMappingOperator)
+	if !ok {
+		return nil, fmt.Errorf("tmo: %s does not return display-referred linear values", name)
+	}
+	return ht.PerformHDR(), nil
+}
+
+// Reset drops the cached analysis of all the TMOs.
+func (s *Session) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.operators = map[string]ToneMappingOperator{}
+}
+
+// operator returns the TMO tuned with the given parameters, the cached one is reused when it is tunable.
+func (s *Session) operator(name string, params map[string]float64) (ToneMappingOperator, error) {
+	r, ok := Lookup(name)
+	if !ok {
+		return nil, fmt.Errorf("tmo: unknown TMO %q", name)
+	}
+
+	values, err := r.values(params)
+	if err != nil {
+		return nil, err
+	}
+
+	if r.Tune == nil {
+		return r.New(s.HDRImage, values), nil
+	}
+
+	t, ok := s.operators[name]
+	if !ok {
+		t = r.New(s.HDRImage, values)
+		s.operators[name] = t
+		return t, nil
+	}
+
+	r.Tune(t, values)
+	return t, nil
+}
@@ -0,0 +1,77 @@
+package tmo
+
+import (
+	"image"
+	"math"
+	"testing"
+)
+
+// tunedParameters returns the parameters of r moved away from their defaults.
+func tunedParameters(r *Registration) map[string]float64 {
+	params := r.Defaults()
+	for _, p := range r.Parameters {
+		switch p.Type {
+		case ParameterBool, ParameterEnum:
+			params[p.Name] = p.Min + math.Mod(p.Default-p.Min+1, p.Max-p.Min+1)
+		default:
+			params[p.Name] = p.Clamp(p.Default + (p.Max-p.Default)/3)
+			if params[p.Name] == p.Default {
+				params[p.Name] = p.Clamp(p.Default - (p.Default-p.Min)/3)
+			}
+		}
+	}
+	return params
+}
+
+func TestSessionTune(t *testing.T) {
+	m := gradient(image.Rect(2, 3, 50, 43))
+
+	for _, name := range Registered() {
+		r, _ := Lookup(name)
+		if r.Tune == nil {
+			continue
+		}
+
+		t.Run(name, func(t *testing.T) {
+			s := NewSession(m)
+			if _, err := s.Perform(name, nil); err != nil {
+				t.Fatal(err)
+			}
+
+			// The second Perform reuses the analysis of the first one
+			params := tunedParameters(r)
+			actual, err := s.Perform(name, params)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			fresh, err := r.Build(m, params)
+			if err != nil {
+				t.Fatal(err)
+			}
+			expected := fresh.Perform()
+
+			d := expected.Bounds()
+			for y := d.Min.Y; y < d.Max.Y; y++ {
+				for x := d.Min.X; x < d.Max.X; x++ {
+					r1, g1, b1, _ := expected.At(x, y).RGBA()
+					r2, g2, b2, _ := actual.At(x, y).RGBA()
+					if r1 != r2 || g1 != g2 || b1 != b2 {
+						t.Fatalf("pixel (%d, %d): got %v, want %v", x, y, []uint32{r2, g2, b2}, []uint32{r1, g1, b1})
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestSessionErrors(t *testing.T) {
+	s := NewSession(gradient(image.Rect(0, 0, 4, 4)))
+
+	if _, err := s.Perform("unknown", nil); err == nil {
+		t.Error("unknown TMO: got no error")
+	}
+	if _, err := s.Perform("drago03", map[string]float64{"unknown": 1}); err == nil {
+		t.Error("unknown parameter: got no error")
+	}
+}
@@ -6,6 +6,10 @@ import (
 	"math"
 	"sort"
 
+	"github.com/mdouchement/hdr"
+	"github.com/mdouchement/hdr/filter"
+	"github.com/mdouchement/hdr/hdrcolor"
+	"github.com/mdouchement/hdr/parallel"
 	"github.com/mdouchement/hdr/xmath"
 )
 
@@ -118,7 +122,7 @@ func (p percentiles) sort() {
 func (p percentiles) percentile(clipping float64) float64 {
 	n := float64(len(p))
 	i := int(clipping * n)
-	return p[i]
+	return p[xmath.Clamp(0, len(p)-1, i)]
 }
 
 func (p percentiles) Len() int {
@@ -132,3 +136,81 @@ func (p percentiles) Less(i, j int) bool {
 func (p percentiles) Swap(i, j int) {
 	p[i], p[j] = p[j], p[i]
 }
+
+//-----------------//
+// Log-luminance   //
+//-----------------//
+
+// quickSampling returns the reduced image used by the statistics passes.
+func quickSampling(m hdr.Image) *filter.QuickSampling {
+	qs, err := filter.NewQuickSamplingWithSpace(m, hdr.ModelSpace(m.ColorModel()), 0.6)
+	if err != nil {
+		panic("tmo: " + err.Error())
+	}
+	return qs
+}
+
+// logLuminance returns the plane of log(Y) of m, indexed relative to the image origin.
+// log should handle the non-positive luminances.
+func logLuminance(e *parallel.Executor, m hdr.Image, log func(lum float64) float64) *filter.Plane {
+	d := m.Bounds()
+	L := filter.NewPlane(d.Dx(), d.Dy())
+
+	completed := e.TilesR(d, func(x1, y1, x2, y2 int) {
+		row := make([]float64, 3*(x2-x1))
+		for y := y1; y < y2; y++ {
+			hdr.ReadRow(m, row, x1, x2, y, hdr.XYZSpace)
+			for x, i := x1, 0; x < x2; x, i = x+1, i+3 {
+				L.Set(x-d.Min.X, y-d.Min.Y, log(row[i+1]))
+			}
+		}
+	})
+	<-completed
+
+	return L
+}
+
+// tonemapLuminance writes in img the colors of m with the reconstructed log-luminance I
+// (indexed relative to the image origin, exp is the inverse of the log).
+// The luminance is normalized between its minClipping and maxClipping percentiles
+// and the color ratios are raised to the saturation.
+func tonemapLuminance(e *parallel.Executor, img *hdr.RGB64, m hdr.Image, I []float64, exp func(float64) float64, saturation, minClipping, maxClipping float64) {
+	d := m.Bounds()
+	width := d.Dx()
+
+	// Normalization of the reconstructed luminance
+	perc := make(percentiles, len(I))
+	for i, v := range I {
+		I[i] = exp(v)
+		perc[i] = I[i]
+	}
+	perc.sort()
+	minLum := perc.percentile(minClipping)
+	maxLum := perc.percentile(maxClipping)
+
+	completed := e.TilesR(d, func(x1, y1, x2, y2 int) {
+		rgb := make([]float64, 3*(x2-x1))
+		xyz := make([]float64, 3*(x2-x1))
+		for y := y1; y < y2; y++ {
+			hdr.ReadRow(m, rgb, x1, x2, y, hdr.RGBSpace)
+			hdr.ReadRow(m, xyz, x1, x2, y, hdr.XYZSpace)
+			for x, i := x1, 0; x < x2; x, i = x+1, i+3 {
+				r, g, b, lum := rgb[i+0], rgb[i+1], rgb[i+2], xyz[i+1]
+
+				lout := xmath.ClampF64(0, 1, (I[(y-d.Min.Y)*width+x-d.Min.X]-minLum)/(maxLum-minLum))
+
+				if lum <= 0 {
+					r, g, b = lout, lout, lout
+				} else {
+					r = math.Pow(math.Max(r/lum, 0), saturation) * lout
+					g = math.Pow(math.Max(g/lum, 0), saturation) * lout
+					b = math.Pow(math.Max(b/lum, 0), saturation) * lout
+				}
+
+				img.SetRGB(x, y, hdrcolor.RGB{R: r, G: g, B: b})
+			}
+		}
+	})
+
+	<-completed
+}
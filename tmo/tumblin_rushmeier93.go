@@ -0,0 +1,90 @@
+package tmo
+
+import (
+	"image"
+	"math"
+
+	"github.com/mdouchement/hdr"
+	"github.com/mdouchement/hdr/hdrcolor"
+)
+
+// A TumblinRushmeier93 is a brightness preserving TMO implementation based on Jack Tumblin's 1993 white paper.
+// The world luminances are expected in cd/m².
+//
+// Reference:
+// Tone Reproduction for Realistic Images.
+// J. Tumblin and H. Rushmeier.
+// In IEEE Computer Graphics and Applications, 1993.
+type TumblinRushmeier93 struct {
+	HDRImage hdr.Image
+	Display
+	executable
+}
+
+// NewDefaultTumblinRushmeier93 instanciates a new TumblinRushmeier93 TMO with default parameters.
+func NewDefaultTumblinRushmeier93(m hdr.Image) *TumblinRushmeier93 {
+	return NewTumblinRushmeier93(m, NewDefaultDisplay())
+}
+
+// NewTumblinRushmeier93 instanciates a new TumblinRushmeier93 TMO.
+func NewTumblinRushmeier93(m hdr.Image, display Display) *TumblinRushmeier93 {
+	return &TumblinRushmeier93{
+		HDRImage: m,
+		Display:  display,
+	}
+}
+
+// Perform runs the TMO mapping.
+func (t *TumblinRushmeier93) Perform() image.Image {
+	return t.encode(NewGammaOutput(displayGamma), t.PerformHDR())
+}
+
+// PerformHDR runs the TMO mapping and returns display-referred linear values.
+func (t *TumblinRushmeier93) PerformHDR() hdr.Image {
+	img := hdr.NewRGB64(t.HDRImage.Bounds())
+
+	lwa := newWorldLuminance(t.executor, t.HDRImage).logAvg
+	lda := t.Ldmax / math.Sqrt(t.Cmax) // Display adaptation luminance
+
+	gw := stevensGamma(lwa)
+	gd := stevensGamma(lda)
+	ratio := gw / gd
+	m := math.Pow(math.Sqrt(t.Cmax), ratio-1)
+
+	completed := t.executor.TilesR(t.HDRImage.Bounds(), func(x1, y1, x2, y2 int) {
+		for y := y1; y < y2; y++ {
+			for x := x1; x < x2; x++ {
+				pixel := t.HDRImage.HDRAt(x, y)
+				_, lw, _, _ := pixel.HDRXYZA()
+
+				if lw <= 0 {
+					img.SetRGB(x, y, hdrcolor.RGB{})
+					continue
+				}
+
+				ld := lda * m * math.Pow(lw/lwa, ratio)
+
+				r, g, b, _ := pixel.HDRRGBA()
+				s := ld / lw
+
+				img.SetRGB(x, y, hdrcolor.RGB{
+					R: r * s / t.Ldmax,
+					G: g * s / t.Ldmax,
+					B: b * s / t.Ldmax,
+				})
+			}
+		}
+	})
+
+	<-completed
+
+	return img
+}
+
+// stevensGamma returns the Stevens' brightness exponent of the given adaptation luminance.
+func stevensGamma(la float64) float64 {
+	if la > 100 {
+		return 2.655
+	}
+	return 1.855 + 0.4*math.Log10(la+2.3e-5)
+}
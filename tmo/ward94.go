@@ -0,0 +1,68 @@
+package tmo
+
+import (
+	"image"
+	"math"
+
+	"github.com/mdouchement/hdr"
+	"github.com/mdouchement/hdr/hdrcolor"
+)
+
+// A Ward94 is a contrast based scale factor TMO implementation based on Greg Ward's 1994 white paper.
+// The world luminances are expected in cd/m².
+//
+// Reference:
+// A Contrast-Based Scalefactor for Luminance Display.
+// G. Ward.
+// In Graphics Gems IV, 1994.
+type Ward94 struct {
+	HDRImage hdr.Image
+	Display
+	executable
+}
+
+// NewDefaultWard94 instanciates a new Ward94 TMO with default parameters.
+func NewDefaultWard94(m hdr.Image) *Ward94 {
+	return NewWard94(m, NewDefaultDisplay())
+}
+
+// NewWard94 instanciates a new Ward94 TMO.
+func NewWard94(m hdr.Image, display Display) *Ward94 {
+	return &Ward94{
+		HDRImage: m,
+		Display:  display,
+	}
+}
+
+// Perform runs the TMO mapping.
+func (t *Ward94) Perform() image.Image {
+	return t.encode(NewGammaOutput(displayGamma), t.PerformHDR())
+}
+
+// PerformHDR runs the TMO mapping and returns display-referred linear values.
+func (t *Ward94) PerformHDR() hdr.Image {
+	img := hdr.NewRGB64(t.HDRImage.Bounds())
+
+	lwa := newWorldLuminance(t.executor, t.HDRImage).logAvg
+
+	// Scale factor matching the just noticeable differences of the world and the display
+	sf := math.Pow((1.219+math.Pow(t.Ldmax/2, 0.4))/(1.219+math.Pow(lwa, 0.4)), 2.5)
+
+	completed := t.executor.TilesR(t.HDRImage.Bounds(), func(x1, y1, x2, y2 int) {
+		for y := y1; y < y2; y++ {
+			for x := x1; x < x2; x++ {
+				r, g, b, _ := t.HDRImage.HDRAt(x, y).HDRRGBA()
+
+				img.SetRGB(x, y, hdrcolor.RGB{
+					R: r * sf / t.Ldmax,
+					G: g * sf / t.Ldmax,
+					B: b * sf / t.Ldmax,
+				})
+			}
+		}
+	})
+
+	<-completed
+
+	return img
+}
@@ -0,0 +1,350 @@
+package tmo
+
+import (
+	"image"
+	"math"
+
+	"github.com/mdouchement/hdr"
+	"github.com/mdouchement/hdr/filter"
+	"github.com/mdouchement/hdr/hdrcolor"
+	"github.com/mdouchement/hdr/xmath"
+)
+
+const (
+	ward97Gamma     = 2.2
+	ward97Bins      = 100
+	ward97Tolerance = 0.025 // Ratio of trimmed counts that stops the ceiling iterations
+	ward97MinLum    = 1e-4  // cd/m²
+)
+
+// A Ward97 is a histogram adjustment TMO implementation based on Greg Ward Larson's 1997 white paper.
+// The world luminances are expected in cd/m².
+//
+// Reference:
+// A Visibility Matching Tone Reproduction Operator for High Dynamic Range Scenes.
+// G. Ward Larson, H. Rushmeier and C. Piatko.
+// In IEEE Transactions on Visualization and Computer Graphics, 1997.
+type Ward97 struct {
+	HDRImage hdr.Image
+	Display
+	// FieldOfView is the horizontal angle covered by the image (degrees).
+	// It defines the foveal resolution (1 pixel per degree).
+	FieldOfView float64
+	// HumanContrast limits the contrast to the human visibility thresholds instead of the linear ceiling.
+	HumanContrast bool
+	// VeilingGlare simulates the light scattered in the eye.
+	VeilingGlare bool
+	// ColorSensitivity simulates the color sensitivity loss in scotopic and mesopic conditions.
+	ColorSensitivity bool
+	// Acuity simulates the reduced visual acuity in dark conditions.
+	Acuity bool
+	executable
+}
+
+// NewDefaultWard97 instanciates a new Ward97 TMO with default parameters.
+func NewDefaultWard97(m hdr.Image) *Ward97 {
+	return NewWard97(m, NewDefaultDisplay(), 60)
+}
+
+// NewWard97 instanciates a new Ward97 TMO.
+func NewWard97(m hdr.Image, display Display, fov float64) *Ward97 {
+	return &Ward97{
+		HDRImage:    m,
+		Display:     display,
+		FieldOfView: fov,
+	}
+}
+
+// Perform runs the TMO mapping.
+func (t *Ward97) Perform() image.Image {
+	return t.encode(NewGammaOutput(ward97Gamma), t.PerformHDR())
+}
+
+// PerformHDR runs the TMO mapping and returns display-referred linear values.
+func (t *Ward97) PerformHDR() hdr.Image {
+	d := t.HDRImage.Bounds()
+	img := hdr.NewRGB64(d)
+
+	lum := t.luminance()
+	fovea := t.fovea(lum)
+
+	if t.VeilingGlare {
+		veil := t.veil(fovea)
+		full := veil.Resize(lum.Width, lum.Height)
+		for i := range lum.Pix {
+			lum.Pix[i] = (1-0.087)*lum.Pix[i] + full.Pix[i]
+		}
+		for i := range fovea.Pix {
+			fovea.Pix[i] = (1-0.087)*fovea.Pix[i] + veil.Pix[i]
+		}
+	}
+
+	if t.Acuity {
+		lum = t.acuity(lum, fovea)
+	}
+
+	bmin, bmax, cumulative := t.histogram(fovea)
+
+	t.tonemap(img, lum, bmin, bmax, cumulative)
+
+	return img
+}
+
+func (t *Ward97) luminance() *filter.Plane {
+	d := t.HDRImage.Bounds()
+	lum := filter.NewPlane(d.Dx(), d.Dy())
+
+	completed := t.executor.TilesR(d, func(x1, y1, x2, y2 int) {
+		for y := y1; y < y2; y++ {
+			for x := x1; x < x2; x++ {
+				_, Y, _, _ := t.HDRImage.HDRAt(x, y).HDRXYZA()
+				lum.Set(x-d.Min.X, y-d.Min.Y, math.Max(Y, 0))
+			}
+		}
+	})
+	<-completed
+
+	return lum
+}
+
+// fovea returns the luminance averaged at the foveal resolution (1 pixel per degree).
+func (t *Ward97) fovea(lum *filter.Plane) *filter.Plane {
+	fw := xmath.Clamp(1, lum.Width, int(math.Round(t.FieldOfView)))
+	fh := xmath.Clamp(1, lum.Height, int(math.Round(t.FieldOfView*float64(lum.Height)/float64(lum.Width))))
+
+	fovea := filter.NewPlane(fw, fh)
+	counts := make([]float64, fw*fh)
+	for y := 0; y < lum.Height; y++ {
+		for x := 0; x < lum.Width; x++ {
+			i := (y*fh/lum.Height)*fw + x*fw/lum.Width
+			fovea.Pix[i] += lum.Pix[y*lum.Width+x]
+			counts[i]++
+		}
+	}
+	for i := range fovea.Pix {
+		fovea.Pix[i] /= counts[i]
+	}
+
+	return fovea
+}
+
+// veil returns the veiling luminance of each foveal sample.
+func (t *Ward97) veil(fovea *filter.Plane) *filter.Plane {
+	veil := filter.NewPlane(fovea.Width, fovea.Height)
+	degree := t.FieldOfView / float64(fovea.Width)
+
+	for y := 0; y < fovea.Height; y++ {
+		for x := 0; x < fovea.Width; x++ {
+			var v float64
+			for j := 0; j < fovea.Height; j++ {
+				for i := 0; i < fovea.Width; i++ {
+					if i == x && j == y {
+						continue
+					}
+
+					theta := math.Hypot(float64(i-x), float64(j-y)) * degree
+					v += fovea.At(i, j) * math.Cos(theta*math.Pi/180) / (theta * theta)
+				}
+			}
+			veil.Set(x, y, 0.087*v*degree*degree)
+		}
+	}
+
+	return veil
+}
+
+// acuity blurs the luminance according to the highest resolvable spatial frequency
+// of the local adaptation luminance (Shaler's acuity data).
+func (t *Ward97) acuity(lum, fovea *filter.Plane) *filter.Plane {
+	adaptation := fovea.Resize(lum.Width, lum.Height)
+	pixelsPerDegree := float64(lum.Width) / t.FieldOfView
+
+	img := hdr.NewRGB64(image.Rect(0, 0, lum.Width, lum.Height))
+	for y := 0; y < lum.Height; y++ {
+		for x := 0; x < lum.Width; x++ {
+			v := lum.At(x, y)
+			img.SetRGB(x, y, hdrcolor.RGB{R: v, G: v, B: v})
+		}
+	}
+
+	// Blurred versions with increasing radii
+	radii := []int{0}
+	blurred := []hdr.Image{img}
+	for r := 1; r <= lum.Width/2 && r <= 64; r *= 2 {
+		radii = append(radii, r)
+		blurred = append(blurred, filter.FastGaussianWithExecutor(t.executor, img, r))
+	}
+
+	dst := filter.NewPlane(lum.Width, lum.Height)
+	for y := 0; y < lum.Height; y++ {
+		for x := 0; x < lum.Width; x++ {
+			la := math.Max(adaptation.At(x, y), ward97MinLum)
+			cpd := 17.25*math.Atan(1.4*math.Log10(la)+0.35) + 25.72 // Cycles per degree
+			radius := pixelsPerDegree / (2 * math.Max(cpd, 1))
+
+			// Interpolation between the two nearest blurred versions
+			k := 0
+			for k < len(radii)-2 && float64(radii[k+1]) < radius {
+				k++
+			}
+			if len(radii) == 1 {
+				dst.Set(x, y, lum.At(x, y))
+				continue
+			}
+			a := xmath.ClampF64(0, 1, (radius-float64(radii[k]))/float64(radii[k+1]-radii[k]))
+			v1, _, _, _ := blurred[k].HDRAt(x, y).HDRRGBA()
+			v2, _, _, _ := blurred[k+1].HDRAt(x, y).HDRRGBA()
+			dst.Set(x, y, (1-a)*v1+a*v2)
+		}
+	}
+
+	return dst
+}
+
+// histogram returns the log-luminance range and the cumulative distribution of the adjusted histogram.
+func (t *Ward97) histogram(fovea *filter.Plane) (bmin, bmax float64, cumulative []float64) {
+	bmin, bmax = math.Inf(1), math.Inf(-1)
+	for _, v := range fovea.Pix {
+		b := math.Log(math.Max(v, ward97MinLum))
+		bmin = math.Min(bmin, b)
+		bmax = math.Max(bmax, b)
+	}
+	if bmax-bmin < 1e-6 {
+		bmax = bmin + 1e-6
+	}
+	db := (bmax - bmin) / ward97Bins
+
+	histogram := make([]float64, ward97Bins)
+	for _, v := range fovea.Pix {
+		b := math.Log(math.Max(v, ward97MinLum))
+		histogram[xmath.Clamp(0, ward97Bins-1, int((b-bmin)/db))]++
+	}
+
+	ldmin := t.Ldmin()
+	bde := math.Log(t.Ldmax) - math.Log(ldmin)
+
+	cumulative = make([]float64, ward97Bins+1)
+	for {
+		var total float64
+		for _, f := range histogram {
+			total += f
+		}
+		if total < ward97Tolerance*float64(len(fovea.Pix)) {
+			// The histogram cannot be adjusted, fallback to a log-linear mapping
+			for i := range histogram {
+				histogram[i] = 1
+			}
+			break
+		}
+
+		t.cumulate(histogram, cumulative)
+
+		var trimmings float64
+		for i, f := range histogram {
+			var ceiling float64
+			if t.HumanContrast {
+				lw := math.Exp(bmin + (float64(i)+0.5)*db)
+				ld := math.Exp(math.Log(ldmin) + bde*0.5*(cumulative[i]+cumulative[i+1]))
+				ceiling = tvi(ld) / tvi(lw) * total * db * lw / (bde * ld)
+			} else {
+				ceiling = total * db / bde
+			}
+
+			if f > ceiling {
+				trimmings += f - ceiling
+				histogram[i] = ceiling
+			}
+		}
+
+		if trimmings <= ward97Tolerance*total {
+			break
+		}
+	}
+
+	t.cumulate(histogram, cumulative)
+
+	return
+}
+
+func (t *Ward97) cumulate(histogram, cumulative []float64) {
+	var total float64
+	for _, f := range histogram {
+		total += f
+	}
+
+	cumulative[0] = 0
+	for i, f := range histogram {
+		cumulative[i+1] = cumulative[i] + f/total
+	}
+}
+
+func (t *Ward97) tonemap(img *hdr.RGB64, lum *filter.Plane, bmin, bmax float64, cumulative []float64) {
+	d := t.HDRImage.Bounds()
+	ldmin := t.Ldmin()
+	bde := math.Log(t.Ldmax) - math.Log(ldmin)
+	db := (bmax - bmin) / ward97Bins
+
+	completed := t.executor.TilesR(d, func(x1, y1, x2, y2 int) {
+		for y := y1; y < y2; y++ {
+			for x := x1; x < x2; x++ {
+				pixel := t.HDRImage.HDRAt(x, y)
+				lw := lum.At(x-d.Min.X, y-d.Min.Y)
+
+				// Cumulative distribution P(log(Lw))
+				pos := xmath.ClampF64(0, ward97Bins, (math.Log(math.Max(lw, ward97MinLum))-bmin)/db)
+				i := xmath.Clamp(0, ward97Bins-1, int(pos))
+				a := pos - float64(i)
+				p := (1-a)*cumulative[i] + a*cumulative[i+1]
+
+				ld := math.Exp(math.Log(ldmin) + bde*p)
+				ld = (ld - ldmin) / (t.Ldmax - ldmin)
+
+				X, Y, Z, _ := pixel.HDRXYZA()
+				r, g, b, _ := pixel.HDRRGBA()
+
+				if Y <= 0 {
+					img.SetRGB(x, y, hdrcolor.RGB{})
+					continue
+				}
+
+				ratio := ld / Y
+				r, g, b = r*ratio, g*ratio, b*ratio
+
+				if t.ColorSensitivity && X > 0 {
+					// Mesopic blend between the photopic color and the scotopic grey
+					k := xmath.ClampF64(0, 1, (math.Log10(Y)+2.25)/3)
+					scotopic := Y * (1.33*(1+(Y+Z)/X) - 1.68)
+					grey := math.Max(scotopic, 0) * ratio
+					r = k*r + (1-k)*grey
+					g = k*g + (1-k)*grey
+					b = k*b + (1-k)*grey
+				}
+
+				img.SetRGB(x, y, hdrcolor.RGB{R: r, G: g, B: b})
+			}
+		}
+	})
+
+	<-completed
+}
+
+// tvi returns the threshold versus intensity ΔLt(La) of the human visual system (Ferwerda's data).
+func tvi(la float64) float64 {
+	l := math.Log10(math.Max(la, 1e-8))
+
+	var t float64
+	switch {
+	case l < -3.94:
+		t = -2.86
+	case l < -1.44:
+		t = math.Pow(0.405*l+1.6, 2.18) - 2.86
+	case l < -0.0184:
+		t = l - 0.395
+	case l < 1.9:
+		t = math.Pow(0.249*l+0.65, 2.7) - 0.72
+	default:
+		t = l - 1.255
+	}
+
+	return math.Pow(10, t)
+}
@@ -1,6 +1,10 @@
 package hdr
 
-import "github.com/mdouchement/hdr/hdrcolor"
+import (
+	"image/color"
+
+	"github.com/mdouchement/hdr/hdrcolor"
+)
 
 //===============//
 // LMS           //
@@ -17,6 +21,11 @@ func NewLMSCAT02w(m Image) *LMSCAT02w {
 	return &LMSCAT02w{Image: m}
 }
 
+// ColorModel returns hdrcolor.RAWModel, the channels are L, M, S.
+func (p *LMSCAT02w) ColorModel() color.Model {
+	return hdrcolor.RAWModel
+}
+
 // HDRAt returns the pixel in LMS-space using CIE CAT02 matrix.
 func (p *LMSCAT02w) HDRAt(x, y int) hdrcolor.Color {
 	X, Y, Z, _ := p.Image.HDRAt(x, y).HDRXYZA()
@@ -24,6 +33,16 @@ func (p *LMSCAT02w) HDRAt(x, y int) hdrcolor.Color {
 	return hdrcolor.RAW{P1: L, P2: M, P3: S}
 }
 
+// ReadRow implements RowReader, the pixels are in LMS-space whatever the given color space.
+// As for HDRAt, whose hdrcolor.RAW colors alias their channels in HDRRGBA and HDRXYZA,
+// the channels are never converted: RAWSpace is the only meaningful color space.
+func (p *LMSCAT02w) ReadRow(dst []float64, x1, x2, y int, _ ColorSpace) {
+	ReadRow(p.Image, dst, x1, x2, y, XYZSpace)
+	for i := 0; i < 3*(x2-x1); i += 3 {
+		dst[i+0], dst[i+1], dst[i+2] = hdrcolor.XyzToLmsMcat02(dst[i+0], dst[i+1], dst[i+2])
+	}
+}
+
 // A LMSHPEw wrapper hollows to get pixels in LMS-space using Hunt-Pointer-Estevez matrix.
 // https://en.wikipedia.org/wiki/LMS_color_space
 type LMSHPEw struct {
@@ -35,9 +54,24 @@ func NewLMSHPEw(m Image) *LMSHPEw {
 	return &LMSHPEw{Image: m}
 }
 
+// ColorModel returns hdrcolor.RAWModel, the channels are L, M, S.
+func (p *LMSHPEw) ColorModel() color.Model {
+	return hdrcolor.RAWModel
+}
+
 // HDRAt returns the pixel in LMS-space using Hunt-Pointer-Estevez matrix.
 func (p *LMSHPEw) HDRAt(x, y int) hdrcolor.Color {
 	X, Y, Z, _ := p.Image.HDRAt(x, y).HDRXYZA()
 	L, M, S := hdrcolor.XyzToLmsMhpe(X, Y, Z)
 	return hdrcolor.RAW{P1: L, P2: M, P3: S}
 }
+
+// ReadRow implements RowReader, the pixels are in LMS-space whatever the given color space.
+// As for HDRAt, whose hdrcolor.RAW colors alias their channels in HDRRGBA and HDRXYZA,
+// the channels are never converted: RAWSpace is the only meaningful color space.
+func (p *LMSHPEw) ReadRow(dst []float64, x1, x2, y int, _ ColorSpace) {
+	ReadRow(p.Image, dst, x1, x2, y, XYZSpace)
+	for i := 0; i < 3*(x2-x1); i += 3 {
+		dst[i+0], dst[i+1], dst[i+2] = hdrcolor.XyzToLmsMhpe(dst[i+0], dst[i+1], dst[i+2])
+	}
+}
@@ -0,0 +1,44 @@
+package xmath
+
+import "math"
+
+const pairwiseBlockSize = 128 // Values summed sequentially by PairwiseSum
+
+// A KahanSum is a compensated (Kahan-Babuska-Neumaier) summation.
+// Its rounding error does not grow with the number of added values.
+// The zero value is an empty sum.
+type KahanSum struct {
+	sum          float64
+	compensation float64
+}
+
+// Add adds v to the sum.
+func (s *KahanSum) Add(v float64) {
+	t := s.sum + v
+	if math.Abs(s.sum) >= math.Abs(v) {
+		s.compensation += (s.sum - t) + v // Low-order digits of v are lost
+	} else {
+		s.compensation += (v - t) + s.sum // Low-order digits of sum are lost
+	}
+	s.sum = t
+}
+
+// Value returns the compensated sum.
+func (s KahanSum) Value() float64 {
+	return s.sum + s.compensation
+}
+
+// PairwiseSum returns the sum of the values computed by pairwise (cascade) summation.
+// The rounding error grows in O(log n) and the result only depends on the values order.
+func PairwiseSum(values []float64) float64 {
+	if len(values) <= pairwiseBlockSize {
+		var sum float64
+		for _, v := range values {
+			sum += v
+		}
+		return sum
+	}
+
+	m := len(values) / 2
+	return PairwiseSum(values[:m]) + PairwiseSum(values[m:])
+}
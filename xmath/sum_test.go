@@ -0,0 +1,57 @@
+package xmath
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestKahanSum(t *testing.T) {
+	tests := []struct {
+		values   []float64
+		expected float64
+	}{
+		{nil, 0},
+		{[]float64{1, 1e100, 1, -1e100}, 2},
+		{[]float64{1e100, 1, -1e100, 1}, 2},
+		{[]float64{1e16, 1, -1e16}, 1},
+	}
+
+	for _, test := range tests {
+		var s KahanSum
+		for _, v := range test.values {
+			s.Add(v)
+		}
+		if s.Value() != test.expected {
+			t.Errorf("%v: got %v, want %v", test.values, s.Value(), test.expected)
+		}
+	}
+}
+
+func TestKahanSumError(t *testing.T) {
+	// A naive summation of 0.1 loses about 1e-10 after 1e6 additions
+	var s KahanSum
+	for i := 0; i < 1000000; i++ {
+		s.Add(0.1)
+	}
+	if math.Abs(s.Value()-100000) > 1e-9 {
+		t.Errorf("got %v, want 100000", s.Value())
+	}
+}
+
+func TestPairwiseSum(t *testing.T) {
+	rnd := rand.New(rand.NewSource(44))
+
+	for _, n := range []int{0, 1, pairwiseBlockSize, pairwiseBlockSize + 1, 10000} {
+		values := make([]float64, n)
+		var s KahanSum
+		for i := range values {
+			values[i] = rnd.Float64()
+			s.Add(values[i])
+		}
+
+		if actual := PairwiseSum(values); math.Abs(actual-s.Value()) > 1e-12*float64(n) {
+			t.Errorf("%d values: got %v, want %v", n, actual, s.Value())
+		}
+	}
+}